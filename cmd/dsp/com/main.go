@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/tonygilkerson/mbx-iot/internal/dashboard"
 	"github.com/tonygilkerson/mbx-iot/internal/dsp"
 	"github.com/tonygilkerson/mbx-iot/internal/road"
 	"github.com/tonygilkerson/mbx-iot/internal/umsg"
@@ -26,6 +27,11 @@ const (
 	TXRX_LOOP_TICKER_DURATION_SECONDS = 10
 )
 
+// forward returns true for the keys passed on to the epaper, the dashboard tiles and the time
+func forward(key string) bool {
+	return key == iot.GatewayTime || dashboard.Uses(dsp.Tiles, key)
+}
+
 /////////////////////////////////////////////////////////////////////////////
 //			Main
 /////////////////////////////////////////////////////////////////////////////
@@ -172,7 +178,7 @@ func rxQConsumer(rxQ *chan string, mb *umsg.MsgBroker) {
 			// Send stats to display over UART
 			//
 			// if msgKey == string(umsg.MSG_STATUS) {  DEVTODO - what up with this?
-			if msgKey == string(iot.GatewayHeartbeat) || msgKey == string(iot.MbxDoorOpened) {
+			if forward(msgKey) {
 				publishStatusToUart(mb, msgKey, msgValue)
 			}
 
@@ -7,7 +7,10 @@ import (
 	"runtime"
 	"time"
 
+	"github.com/tonygilkerson/mbx-iot/internal/clock"
+	"github.com/tonygilkerson/mbx-iot/internal/dashboard"
 	"github.com/tonygilkerson/mbx-iot/internal/dsp"
+	"github.com/tonygilkerson/mbx-iot/internal/input"
 	"github.com/tonygilkerson/mbx-iot/internal/umsg"
 	"github.com/tonygilkerson/mbx-iot/pkg/iot"
 	"tinygo.org/x/drivers/waveshare-epd/epd4in2"
@@ -20,6 +23,11 @@ const (
 	HEARTBEAT_MOD = 15 // Update screen every 15 min to update age
 )
 
+var buttonInputConfig = input.Config{
+	Debounce: time.Millisecond * 30,
+	Poll:     time.Millisecond * 10,
+}
+
 var display epd4in2.Device
 
 func main() {
@@ -72,29 +80,33 @@ func main() {
 	//
 	// Buttons
 	//
-	mbxDoorOpenedAckBtnCh := make(chan string, 1)
+	mbxDoorOpenedAckBtnEdges := make(chan string, 1)
 	mbxDoorOpenedAckBtn.Configure(machine.PinConfig{Mode: machine.PinInputPulldown})
-	mbxDoorOpenedAckBtn.SetInterrupt(machine.PinRising, func(p machine.Pin) {
+	mbxDoorOpenedAckBtn.SetInterrupt(machine.PinToggle, func(p machine.Pin) {
 		// Use non-blocking send so if the channel buffer is full,
 		// the value will get dropped instead of crashing the system
 		select {
-		case mbxDoorOpenedAckBtnCh <- "rise":
+		case mbxDoorOpenedAckBtnEdges <- "edge":
 		default:
 		}
 
 	})
+	mbxDoorOpenedAckBtnCh := make(chan input.Event, 1)
+	go input.New(buttonInputConfig, time.Now()).Run(mbxDoorOpenedAckBtn.Get, mbxDoorOpenedAckBtnEdges, mbxDoorOpenedAckBtnCh)
 
-	requestBtnCh := make(chan string, 1)
+	requestBtnEdges := make(chan string, 1)
 	requestBtn.Configure(machine.PinConfig{Mode: machine.PinInputPulldown})
-	requestBtn.SetInterrupt(machine.PinRising, func(p machine.Pin) {
+	requestBtn.SetInterrupt(machine.PinToggle, func(p machine.Pin) {
 		// Use non-blocking send so if the channel buffer is full,
 		// the value will get dropped instead of crashing the system
 		select {
-		case requestBtnCh <- "rise":
+		case requestBtnEdges <- "edge":
 		default:
 		}
 
 	})
+	requestBtnCh := make(chan input.Event, 1)
+	go input.New(buttonInputConfig, time.Now()).Run(requestBtn.Get, requestBtnEdges, requestBtnCh)
 
 	//
 	// UARTs
@@ -146,7 +158,9 @@ func main() {
 	log.Println("dsp.epaper.main: new epd4in2")
 	display = epd4in2.New(machine.SPI0, cs, dc, rst, busy)
 	display.Configure(epd4in2.Config{})
-	content := dsp.NewContent()
+	// The clock is set when the gateway broadcasts the time
+	clk := clock.NewSynced(clock.System{})
+	board := dashboard.New(dsp.Tiles, clk.Now())
 
 	//
 	//  Main loop
@@ -159,25 +173,29 @@ func main() {
 	var count float64
 
 	for {
-		// Refresh age each cycle
-		content.UpdateAge()
 		count += 1
 
 		// Wait for button or timeout
-		log.Printf("dsp.epaper.main: wait on a button to be pushed or a timeout, IsDirty: %v", content.IsDirty() )
+		log.Printf("dsp.epaper.main: wait on a button to be pushed or a timeout, IsDirty: %v", board.IsDirty() )
 
 		select {
 
-		case <-requestBtnCh:
-			log.Println("dsp.epaper.main: requestBtn Hit!!!!")
-			dsp.NeoBlink(neo)
-			displayNeedsRefreshed = true
+		case e := <-requestBtnCh:
+			// Only act on the press, not the release
+			displayNeedsRefreshed = false
+			if e.Kind == input.Opened {
+				log.Println("dsp.epaper.main: requestBtn Hit!!!!")
+				dsp.NeoBlink(neo)
+				displayNeedsRefreshed = true
+			}
 
-		case <-mbxDoorOpenedAckBtnCh:
-			log.Println("dsp.epaper.main: mbxDoorOpenedAckBtn Hit!!!!")
-			content.SetIsDirty(false)
-			content.SetYouGotMailIndicator("waiting...")
-			displayNeedsRefreshed = true
+		case e := <-mbxDoorOpenedAckBtnCh:
+			displayNeedsRefreshed = false
+			if e.Kind == input.Opened {
+				log.Println("dsp.epaper.main: mbxDoorOpenedAckBtn Hit!!!!")
+				board.Ack(clk.Now())
+				displayNeedsRefreshed = true
+			}
 
 		case <-boom.C:
 			log.Printf("dsp.epaper.main:  Boom! heartbeat timeout\n")
@@ -190,12 +208,12 @@ func main() {
 		//
 		log.Println("dsp.epaper.main: Read all messages on the buffer")
 		mb.UartReader()
-		consumeAllStatusFromChToUpdateContent(statusCh, content)
+		consumeAllStatusFromChToUpdateDashboard(statusCh, board, clk)
 
 		//
 		// Is the content dirty?
 		//
-		if content.IsDirty() {
+		if board.IsDirty() {
 			// Get someone's attention
 			log.Println("dsp.epaper.main: Nightrider")
 			dsp.NeoNightrider(neo)
@@ -219,9 +237,9 @@ func main() {
 		// Display Content
 		//
 		if displayNeedsRefreshed {
-			log.Println("dsp.epaper.main: DisplayContent()")
+			log.Println("dsp.epaper.main: DisplayDashboard()")
 			dsp.ClearDisplay(&display)
-			content.DisplayContent(&display)
+			dsp.DisplayDashboard(&display, board, clk.Now())
 		}
 
 		log.Println("dsp.epaper.main: Gosched()")
@@ -236,8 +254,8 @@ func main() {
 //
 ///////////////////////////////////////////////////////////////////////////////
 
-// consumeAllStatusFromChToUpdateContent
-func consumeAllStatusFromChToUpdateContent(statusCh chan umsg.StatusMsg, content *dsp.Content) {
+// consumeAllStatusFromChToUpdateDashboard
+func consumeAllStatusFromChToUpdateDashboard(statusCh chan umsg.StatusMsg, board *dashboard.Dashboard, clk *clock.Synced) {
 
 	var msg umsg.StatusMsg
 
@@ -247,21 +265,22 @@ func consumeAllStatusFromChToUpdateContent(statusCh chan umsg.StatusMsg, content
 	for len(statusCh) > 0 {
 
 		msg = <-statusCh
-		log.Printf("dsp.epaper.consumeAllStatusFromChToUpdateContent: msg: [%v]\n", msg)
+		log.Printf("dsp.epaper.consumeAllStatusFromChToUpdateDashboard: msg: [%v]\n", msg)
+
+		// The time sets the clock, everything else goes to the dashboard tiles
+		if msg.Key == iot.GatewayTime {
+			log.Printf("dsp.epaper.consumeAllStatusFromChToUpdateDashboard: set clock to %v", msg.Value)
+			jump, err := clk.SetFromMessage(msg.Value, nil)
+			if err != nil {
+				log.Printf("dsp.epaper.consumeAllStatusFromChToUpdateDashboard: bad time [%v]: %v", msg.Value, err)
+				continue
+			}
+			board.ClockJumped(jump)
+			continue
+		}
 
-		//
-		// Update display content depending on the type of status received
-		//DEVTODO make this more general
-		//        maybe this should be in a different function
-		switch msg.Key {
-		case iot.GatewayHeartbeat:
-			log.Printf("dsp.epaper.consumeAllStatusFromChToUpdateContent: call SetGatewayHeartbeat()")
-			content.SetGatewayHeartbeatStatus(msg.Value)
-		case iot.MbxDoorOpened:
-			log.Printf("dsp.epaper.consumeAllStatusFromChToUpdateContent: call SetMbxDoorOpened()")
-			content.SetMbxDoorOpenedStatus(msg.Value)
-		default:
-			log.Printf("dsp.epaper.consumeAllStatusFromChToUpdateContent: Not interested in this content: %v", msg)
+		if !board.Update(msg.Key, msg.Value, clk.Now()) {
+			log.Printf("dsp.epaper.consumeAllStatusFromChToUpdateDashboard: Not interested in this content: %v", msg)
 		}
 	}
 }
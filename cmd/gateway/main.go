@@ -1,15 +1,17 @@
 package main
 
 import (
-	"fmt"
 	"log"
 	"machine"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/tonygilkerson/mbx-iot/internal/clock"
 	"github.com/tonygilkerson/mbx-iot/internal/dsp"
+	"github.com/tonygilkerson/mbx-iot/internal/gateway"
 	"github.com/tonygilkerson/mbx-iot/internal/road"
 	"github.com/tonygilkerson/mbx-iot/internal/umsg"
 	"github.com/tonygilkerson/mbx-iot/pkg/iot"
@@ -18,8 +20,62 @@ import (
 
 const (
 	HEARTBEAT_DURATION_SECONDS = 10
+
+	// A node is marked offline after it misses this many heartbeats in a row
+	NODE_MISSED_INTERVALS = 3
+
+	// Expected heartbeat interval of each node, keep in sync with the node's HEARTBEAT_DURATION_SECONDS
+	MBX_HEARTBEAT_SECONDS  = 300
+	SOIL_HEARTBEAT_SECONDS = 600
+	DSP_HEARTBEAT_SECONDS  = 15
+	MED_HEARTBEAT_SECONDS  = 300
+
+	// Number of events kept per node in the event history
+	HISTORY_SIZE = 64
+
+	// Max number of history events sent in reply to a query received over the air.
+	// The radio splits a batch that does not fit in one packet across cycles, this keeps the reply from holding up other traffic.
+	HISTORY_RADIO_LIMIT = 4
+
+	// Raise a low battery alert below this percent, clear it once the battery is back above the clear percent
+	LOW_BATTERY_PERCENT       = 20
+	LOW_BATTERY_CLEAR_PERCENT = 30
+
+	// Downlink commands are resent until acked, the soil node only listens for part of each cycle
+	COMMAND_RETRY_SECONDS = 15
+	COMMAND_MAX_ATTEMPTS  = 40
 )
 
+// broadcastRules is the allow-list of status keys sent over the air to the display.
+// Keys that are not listed stay in the gateway and are only written to the serial port.
+var broadcastRules = []gateway.Rule{
+	{Key: iot.GatewayHeartbeat, Policy: gateway.OnInterval, Interval: time.Minute},
+	{Key: iot.GatewayTime, Policy: gateway.OnInterval, Interval: time.Minute * 10},
+	{Key: iot.MbxDoorOpened, Policy: gateway.OnChange, Interval: time.Minute * 15},
+	{Key: iot.GatewayMailToday, Policy: gateway.OnChange, Interval: time.Minute * 15},
+	{Key: iot.GatewayNodesOffline, Policy: gateway.OnChange, Interval: time.Minute * 15},
+	{Key: iot.MbxBatteryPercent, Policy: gateway.OnChange, Interval: time.Minute * 15},
+	{Key: iot.SoilMoisture, Policy: gateway.OnChange, Interval: time.Minute * 15, Sensors: true},
+	{Key: iot.MedsLastDoseHours, Policy: gateway.OnChange, Interval: time.Minute * 15},
+	{Key: iot.MedsOverdue, Policy: gateway.OnChange, Interval: time.Minute * 15},
+}
+
+// historyKeys are the message keys recorded in the event history and the node each belongs to
+var historyKeys = map[string]string{
+	iot.MbxDoorOpened:       iot.NodeMbx,
+	iot.MbxDoorClosed:       iot.NodeMbx,
+	iot.MbxDoorLeftOpen:     iot.NodeMbx,
+	iot.MbxMuleAlarm:        iot.NodeMbx,
+	iot.MbxChargerState:     iot.NodeMbx,
+	iot.MbxChargerHarvest:   iot.NodeMbx,
+	iot.SoilSensorError:     iot.NodeSoil,
+	iot.SoilWateringStarted: iot.NodeSoil,
+	iot.SoilWateringStopped: iot.NodeSoil,
+	iot.SoilCommandAck:      iot.NodeSoil,
+	iot.MedsTaken:           iot.NodeMed,
+	iot.MedsAdjusted:        iot.NodeMed,
+}
+
 /////////////////////////////////////////////////////////////////////////////
 //			Main
 /////////////////////////////////////////////////////////////////////////////
@@ -53,8 +109,8 @@ func main() {
 	// setup Uart
 	//
 	log.Println("Configure UART")
-	uart := machine.UART0
-	uart.Configure(machine.UARTConfig{BaudRate: 115200, TX: uartTx, RX: uartRx})
+	machine.UART0.Configure(machine.UARTConfig{BaudRate: 115200, TX: uartTx, RX: uartRx})
+	uart := &serial{uart: machine.UART0}
 
 	//
 	// 	Setup Lora
@@ -83,13 +139,35 @@ func main() {
 		1, 
 		road.TxRx)
 
-	// Create status map
-	statusMap := make(map[string]string)
+	// Create status map, it is written by writeToSerial and broadcast from the main loop
+	status := gateway.NewStatus()
+
+	//
+	// Node liveness
+	//
+	liveness := gateway.NewLiveness(NODE_MISSED_INTERVALS)
+	liveness.Register(iot.NodeMbx, iot.MbxRoadMainLoopHeartbeat, time.Second*MBX_HEARTBEAT_SECONDS, time.Now())
+	liveness.Register(iot.NodeSoil, iot.SoilMainLoopHeartbeat, time.Second*SOIL_HEARTBEAT_SECONDS, time.Now())
+	liveness.Register(iot.NodeDsp, iot.DspMainLoopHeartbeat, time.Second*DSP_HEARTBEAT_SECONDS, time.Now())
+	liveness.Register(iot.NodeMed, iot.MedMainLoopHeartbeat, time.Second*MED_HEARTBEAT_SECONDS, time.Now())
+	status.Set(iot.GatewayNodesOffline, "")
+
+	broadcaster := gateway.NewBroadcaster(broadcastRules)
+	history := gateway.NewHistory(HISTORY_SIZE)
+	batteryAlert := &gateway.LowAlert{Low: LOW_BATTERY_PERCENT, Clear: LOW_BATTERY_CLEAR_PERCENT}
+	doses := &gateway.DoseTracker{}
+
+	// Seed the command ids so a restarted gateway does not reuse an id the node has already seen
+	firstCommandID, _ := machine.GetRNG()
+	outbox := gateway.NewOutbox(time.Second*COMMAND_RETRY_SECONDS, COMMAND_MAX_ATTEMPTS, firstCommandID)
+
+	// The host sets the time over serial, it is broadcast to the nodes once it is known
+	clk := clock.NewSynced(clock.System{})
 
 	// Launch go routines
 	log.Println("Launch go routines")
-	go writeToSerial(&rxQ, uart, statusMap)
-	go readFromSerial(&txQ, uart)
+	go writeToSerial(&rxQ, &txQ, uart, status, liveness, history, batteryAlert, outbox, doses, clk)
+	go readFromSerial(&txQ, uart, history, outbox, clk)
 	go radio.LoraRxTxRunner()
 
 	// Main loop
@@ -102,10 +180,24 @@ func main() {
 
 		log.Printf("------------------mbx-iot gateway MainLoopHeartbeat-------------------- %v", count)
 		count += 1
-		statusMap[iot.GatewayHeartbeat] = strconv.Itoa(count)
+		status.Set(iot.GatewayHeartbeat, strconv.Itoa(count))
+		if clk.IsSet() {
+			status.Set(iot.GatewayTime, clock.FormatUnix(clk.Now()))
+		}
+		status.Set(iot.MedsLastDoseHours, doses.LastDoseHours(time.Now()))
+		status.Set(iot.MedsOverdue, doses.OverdueMinutes())
+		status.Set(iot.GatewayMailToday, strconv.Itoa(history.Count(iot.NodeMbx, iot.MbxDoorOpened, time.Now().Add(-time.Hour*24))))
+
+		// Look for nodes that have gone quiet
+		for _, t := range liveness.Check(time.Now()) {
+			publishTransition(t, &txQ, uart, status, liveness, history)
+		}
 
 		// Send out status on each heartbeat
-		publishStatus(statusMap, txQ)
+		publishStatus(broadcaster, status, txQ)
+
+		// Send the downlink commands that are waiting for an ack
+		publishCommands(outbox, &txQ, uart, history)
 
 		dsp.RunLight(led, 2)
 		runtime.Gosched()
@@ -119,16 +211,60 @@ func main() {
 //
 ///////////////////////////////////////////////////////////////////////////////
 
-func publishStatus(statusMap map[string]string, txQ chan string) {
+// publishStatus puts the status that is due according to the broadcast rules on the txQ
+func publishStatus(broadcaster *gateway.Broadcaster, status *gateway.Status, txQ chan string) {
+
+	for _, msg := range broadcaster.Messages(status.Snapshot(), time.Now()) {
+		txQ <- msg
+	}
+
+}
+
+// publishTransition sends a node liveness transition to the serial port and over the air
+// and updates the list of offline nodes in the status map. It is called from both the main loop and writeToSerial.
+func publishTransition(t gateway.Transition, txQ *chan string, uart *serial, status *gateway.Status, liveness *gateway.Liveness, history *gateway.History) {
 
-	// DEVTODO - Add a filter and only publish certain status
-	for k, v := range statusMap {
-		txQ <- fmt.Sprintf("%s:%s", k, v)
+	if t.Online {
+		history.Record(t.Node, iot.NodeOnline, t.Node, time.Now())
+	} else {
+		history.Record(t.Node, iot.NodeOffline, t.Node, time.Now())
 	}
-	
+
+	publishAlert(t.Message(), txQ, uart)
+
+	status.Set(iot.GatewayNodesOffline, strings.Join(liveness.Offline(), ","))
+
 }
 
-func writeToSerial(rxQ *chan string, uart *machine.UART, statusMap map[string]string) {
+// publishCommands sends the downlink commands that are due and reports the ones that were never acked
+func publishCommands(outbox *gateway.Outbox, txQ *chan string, uart *serial, history *gateway.History) {
+
+	send, expired := outbox.Due(time.Now())
+
+	for _, msg := range send {
+		log.Printf("gateway.publishCommands: send [%v]", msg)
+		*txQ <- msg
+	}
+
+	for _, c := range expired {
+		msg := iot.GatewayCommandExpired + ":" + c.ID + "," + c.Key + "," + c.Value
+		log.Printf("gateway.publishCommands: no ack after %v attempts [%v]", c.Attempts, msg)
+		history.Record(iot.NodeSoil, iot.GatewayCommandExpired, c.ID, time.Now())
+		uart.Write(msg)
+	}
+
+}
+
+// publishAlert sends an alert message to the serial port and over the air
+func publishAlert(msg string, txQ *chan string, uart *serial) {
+
+	log.Printf("gateway.publishAlert: [%v]", msg)
+	uart.Write(msg)
+	*txQ <- msg
+
+}
+
+func writeToSerial(rxQ *chan string, txQ *chan string, uart *serial, status *gateway.Status, liveness *gateway.Liveness, history *gateway.History, batteryAlert *gateway.LowAlert, outbox *gateway.Outbox, doses *gateway.DoseTracker, clk *clock.Synced) {
 	var msgBatch string
 	var count int
 
@@ -143,7 +279,7 @@ func writeToSerial(rxQ *chan string, uart *machine.UART, statusMap map[string]st
 		messages := road.SplitMessageBatch(msgBatch)
 		for _, msg := range messages {
 			log.Printf("gateway.writeToSerial: Write to serial: [%v]", msg)
-			uart.Write(append([]byte(msg), umsg.TOKEN_PIPE))
+			uart.Write(msg)
 
 				//
 				// Each message is a key:values pair
@@ -159,22 +295,98 @@ func writeToSerial(rxQ *chan string, uart *machine.UART, statusMap map[string]st
 					msgValue = parts[1]
 				}
 
+			if node, found := historyKeys[msgKey]; found {
+				history.Record(node, msgKey, msgValue, time.Now())
+			}
+
 			switch  {
 			case msgKey == iot.MbxDoorOpened:
-				c,_ := strconv.Atoi(statusMap[iot.MbxDoorOpened])
-				c += 1
+				c := status.Increment(msgKey)
 				log.Printf("gateway.writeToSerial: increment MbxDoorOpened count to [%v]", c)
-				statusMap[msgKey] = strconv.Itoa(c)
 
 			case msgKey == iot.MbxMuleAlarm:
-				c,_ := strconv.Atoi(statusMap[iot.MbxMuleAlarm])
-				c += 1
+				c := status.Increment(msgKey)
 				log.Printf("gateway.writeToSerial: increment MbxMuleAlarm count to [%v]", c)
-				statusMap[msgKey] = strconv.Itoa(c)
 			
 			case msgKey == iot.MbxTemperature:
 				log.Printf("gateway.writeToSerial: set MbxTemperature status to [%v]", msgValue)
-				statusMap[msgKey] = msgValue
+				status.Set(msgKey, msgValue)
+
+			case msgKey == iot.MbxChargerState, msgKey == iot.MbxChargerHarvest, msgKey == iot.SoilSensorError:
+				log.Printf("gateway.writeToSerial: set %v status to [%v]", msgKey, msgValue)
+				status.Set(msgKey, msgValue)
+
+			case msgKey == iot.SoilValveOnSeconds, msgKey == iot.SoilValveCycles, msgKey == iot.SoilValveLastOpened:
+				status.Set(msgKey, msgValue)
+
+			case msgKey == iot.SoilMoisture, strings.HasPrefix(msgKey, iot.SoilMoisture+"-"):
+				// A probe at another address sends its own key, for example SoilMoisture-0x37
+				status.Set(msgKey, msgValue)
+
+			case msgKey == iot.MbxBatteryVoltage, msgKey == iot.MbxBatteryRuntime:
+				status.Set(msgKey, msgValue)
+
+			case msgKey == iot.MbxBatteryPercent:
+				log.Printf("gateway.writeToSerial: set MbxBatteryPercent status to [%v]", msgValue)
+				status.Set(msgKey, msgValue)
+
+				percent, err := strconv.ParseFloat(msgValue, 64)
+				if err != nil {
+					log.Printf("gateway.writeToSerial: bad battery percent [%v]", msgValue)
+					break
+				}
+
+				if low, changed := batteryAlert.Update(percent); changed {
+					alert := iot.BatteryOk
+					if low {
+						alert = iot.BatteryLow
+					}
+					history.Record(iot.NodeMbx, alert, msgValue, time.Now())
+					publishAlert(alert+":"+iot.NodeMbx, txQ, uart)
+				}
+
+			case msgKey == iot.MedsTaken:
+				if err := doses.Taken(msgValue, time.Now()); err != nil {
+					log.Printf("gateway.writeToSerial: bad %v [%v]", msgKey, msgValue)
+				}
+
+			case msgKey == iot.MedsAdjusted, msgKey == iot.MedsLastDose:
+				if err := doses.Dose(msgValue, time.Now()); err != nil {
+					log.Printf("gateway.writeToSerial: bad %v [%v]", msgKey, msgValue)
+				}
+
+			case msgKey == iot.MedsOverdue:
+				// Sent with every heartbeat, only the start and end of an overdue dose go in the history
+				changed, err := doses.Overdue(msgValue)
+				if err != nil {
+					log.Printf("gateway.writeToSerial: bad %v [%v]", msgKey, msgValue)
+					break
+				}
+				if changed {
+					history.Record(iot.NodeMed, msgKey, msgValue, time.Now())
+				}
+
+			case msgKey == iot.MbxRoadMainLoopHeartbeat, msgKey == iot.SoilMainLoopHeartbeat, msgKey == iot.DspMainLoopHeartbeat, msgKey == iot.MedMainLoopHeartbeat:
+				if t, ok := liveness.Heard(msgKey, time.Now()); ok {
+					publishTransition(t, txQ, uart, status, liveness, history)
+				}
+
+				// The med node only listens for a moment after its heartbeat, reply with the time so it can set its RTC
+				if msgKey == iot.MedMainLoopHeartbeat && clk.IsSet() {
+					*txQ <- iot.GatewayTime + ":" + clock.FormatUnix(clk.Now())
+				}
+
+			case msgKey == iot.SoilCommandAck:
+				if c, ok := outbox.Ack(msgValue); ok {
+					log.Printf("gateway.writeToSerial: command [%v] acked after %v attempts", c.Message(), c.Attempts)
+				}
+
+			case msgKey == iot.GatewayHistoryQuery:
+				// Reply over the air, keep it short, the radio sends what does not fit in the next packet
+				query := strings.Split(msgValue, ",")[0] + "," + strconv.Itoa(HISTORY_RADIO_LIMIT)
+				for _, reply := range history.Query(query, time.Now()) {
+					*txQ <- reply
+				}
 			}
 			
 		}
@@ -190,7 +402,13 @@ func writeToSerial(rxQ *chan string, uart *machine.UART, statusMap map[string]st
 //                currently this is used for testing. The cluster exposed a REST endpoint that can be
 //                post a message that is subsequently read and then transmitted here
 //
-func readFromSerial(txQ *chan string, uart *machine.UART) {
+//                A GatewayHistoryQuery message is answered by the gateway on the serial port and is not transmitted
+//
+//                A SoilCommand message is queued in the outbox, it is transmitted from the main loop until the soil node acks it
+//
+//                A GatewayTime message sets the gateway clock, the main loop broadcasts the time from then on
+//
+func readFromSerial(txQ *chan string, uart *serial, history *gateway.History, outbox *gateway.Outbox, clk *clock.Synced) {
 	data := make([]byte, 250)
 
 	ticker := time.NewTicker(time.Second * 1)
@@ -210,9 +428,46 @@ func readFromSerial(txQ *chan string, uart *machine.UART) {
 		n, err := uart.Read(data)
 		if err != nil {
 			log.Printf("Serial read error [%v]", err)
-		} else {
-			log.Printf("Put on txQ [%v]", string(data[:n]))
-			*txQ <- string(data[:n])
+			continue
+		}
+
+		var forward []string
+		for _, msg := range road.SplitMessageBatch(string(data[:n])) {
+			msg = strings.TrimSpace(msg)
+
+			if strings.HasPrefix(msg, iot.GatewayHistoryQuery+":") {
+				log.Printf("gateway.readFromSerial: answer history query [%v]", msg)
+				for _, reply := range history.Query(strings.TrimPrefix(msg, iot.GatewayHistoryQuery+":"), time.Now()) {
+					uart.Write(reply)
+				}
+				continue
+			}
+
+			if strings.HasPrefix(msg, iot.GatewayTime+":") {
+				if _, err := clk.SetFromMessage(strings.TrimPrefix(msg, iot.GatewayTime+":"), nil); err != nil {
+					log.Printf("gateway.readFromSerial: bad time [%v]: %v", msg, err)
+					continue
+				}
+				log.Printf("gateway.readFromSerial: clock set to %v", clk.Now())
+				continue
+			}
+
+			if strings.HasPrefix(msg, iot.SoilCommand+":") {
+				command := strings.TrimPrefix(msg, iot.SoilCommand+":")
+				id := outbox.Queue(iot.SoilCommand, command, time.Now())
+				log.Printf("gateway.readFromSerial: queued command [%v] as id %v", msg, id)
+				uart.Write(iot.GatewayCommandQueued + ":" + id + "," + command)
+				continue
+			}
+
+			if len(msg) > 0 {
+				forward = append(forward, msg)
+			}
+		}
+
+		if len(forward) > 0 {
+			log.Printf("Put on txQ [%v]", strings.Join(forward, "|"))
+			*txQ <- strings.Join(forward, "|")
 		}
 
 		runtime.Gosched()
@@ -220,3 +475,27 @@ func readFromSerial(txQ *chan string, uart *machine.UART) {
 
 }
 
+// serial is the UART to the host. The main loop, writeToSerial and readFromSerial all write to it,
+// the lock keeps each message whole.
+type serial struct {
+	mu   sync.Mutex
+	uart *machine.UART
+}
+
+// Write sends a message followed by the message separator
+func (s *serial) Write(msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.uart.Write(append([]byte(msg), umsg.TOKEN_PIPE))
+}
+
+// Buffered returns the number of bytes waiting to be read
+func (s *serial) Buffered() int {
+	return s.uart.Buffered()
+}
+
+// Read reads the bytes waiting, reads only happen in readFromSerial so they are not locked
+func (s *serial) Read(data []byte) (n int, err error) {
+	return s.uart.Read(data)
+}
@@ -7,16 +7,49 @@ import (
 	"runtime"
 	"time"
 
-	"tinygo.org/x/drivers/sx127x"
+	"github.com/tonygilkerson/mbx-iot/internal/battery"
+	"github.com/tonygilkerson/mbx-iot/internal/charger"
+	"github.com/tonygilkerson/mbx-iot/internal/clock"
 	"github.com/tonygilkerson/mbx-iot/internal/dsp"
+	"github.com/tonygilkerson/mbx-iot/internal/input"
+	"github.com/tonygilkerson/mbx-iot/internal/power"
 	"github.com/tonygilkerson/mbx-iot/internal/road"
+	"github.com/tonygilkerson/mbx-iot/internal/sensor"
 	"github.com/tonygilkerson/mbx-iot/pkg/iot"
+	"tinygo.org/x/drivers/sx127x"
 )
 
 const (
 	HEARTBEAT_DURATION_SECONDS = 300
+	SENSOR_INTERVAL            = time.Second * HEARTBEAT_DURATION_SECONDS
+
+	// Scheduled tasks
+	TASK_HEARTBEAT = "heartbeat"
 )
 
+// The door sensor must be lit for a moment before we call it open so a bump
+// from a passing truck does not count as mail. If the door is still open after
+// a few minutes the mail man probably did not shut it.
+var mailInputConfig = input.Config{
+	Debounce:  time.Millisecond * 50,
+	Hold:      time.Millisecond * 500,
+	StuckOpen: time.Minute * 5,
+	Poll:      time.Millisecond * 50,
+}
+
+// The battery is measured through a divider of two 100k resistors so it stays under the 3.3v ADC reference.
+// Adjust Scale/Offset after comparing the reported voltage with a meter.
+var batteryConfig = battery.Config{
+	Divider: 2,
+	VRef:    3.3,
+	Scale:   1,
+	Offset:  0,
+}
+
+var muleInputConfig = input.Config{
+	Debounce: time.Millisecond * 50,
+	Poll:     time.Millisecond * 25,
+}
 
 /////////////////////////////////////////////////////////////////////////////
 //			Main
@@ -37,9 +70,10 @@ func main() {
 	var sck machine.Pin = machine.GP18 // machine.SPI0_SCK_PIN
 	var sdo machine.Pin = machine.GP19 // machine.SPI0_SDO_PIN
 	var rst machine.Pin = machine.GP20
-	var dio0 machine.Pin = machine.GP21 // (GP21--G0) Must be connected from pico to breakout for radio events IRQ to work
-	var dio1 machine.Pin = machine.GP22 // (GP22--G1)I don't now what this does but it seems to need to be connected
-	var led machine.Pin = machine.GPIO25 // GP25 machine.LED
+	var dio0 machine.Pin = machine.GP21    // (GP21--G0) Must be connected from pico to breakout for radio events IRQ to work
+	var dio1 machine.Pin = machine.GP22    // (GP22--G1)I don't now what this does but it seems to need to be connected
+	var led machine.Pin = machine.GPIO25   // GP25 machine.LED
+	var battPin machine.Pin = machine.ADC0 // GP26 battery voltage divider
 
 	//
 	// run light
@@ -54,7 +88,7 @@ func main() {
 	//
 	var loraRadio *sx127x.Device
 	txQ := make(chan string, 250) // I would hope the channel size would never be larger than ~4 so 250 is large
-	rxQ := make(chan string) // this app currently does not do anything with messages received
+	rxQ := make(chan string)      // this app currently does not do anything with messages received
 
 	radio := road.SetupLora(*machine.SPI0, en, rst, cs, dio0, dio1, sck, sdo, sdi, loraRadio, &txQ, &rxQ, 5_000, 10_000, 10, road.TxOnly)
 
@@ -72,19 +106,69 @@ func main() {
 	pgood.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
 	log.Printf("pgood status: %v\n", pgood.Get())
 
+	chargerTracker := charger.New(time.Now())
+	chargerTracker.Update(chg.Get(), pgood.Get(), time.Now())
+
+	chargerInterruptEvents := make(chan string, 1)
+	chargerEdge := func(p machine.Pin) {
+
+		// Use non-blocking send so if the channel buffer is full,
+		// the value will get dropped instead of crashing the system
+		select {
+		case chargerInterruptEvents <- "edge":
+		default:
+		}
+
+	}
+	chg.SetInterrupt(machine.PinToggle, chargerEdge)
+	pgood.SetInterrupt(machine.PinToggle, chargerEdge)
+
+	//
+	// Setup battery monitor
+	//
+	machine.InitADC()
+	battADC := machine.ADC{Pin: battPin}
+	battADC.Configure(machine.ADCConfig{})
+	batt := battery.New(battADC, batteryConfig)
+
+	//
+	// Setup sensors
+	//
+	sensors := sensor.NewScheduler(clock.System{}, &txQ)
+	register := func(s sensor.Sensor) {
+		if err := sensors.Register(s); err != nil {
+			log.Panicf("main: register sensor %v: %v", s.Name(), err)
+		}
+	}
+	register(sensor.New("onboard-temperature", iot.MbxTemperature, "F", SENSOR_INTERVAL, readTemperature))
+
+	// The voltage sensor takes the battery reading, the others report from that same reading
+	register(sensor.New("battery-voltage", iot.MbxBatteryVoltage, "V", SENSOR_INTERVAL, func() (float64, error) {
+		return batt.Read(time.Now()).Volts, nil
+	}))
+	register(sensor.New("battery-percent", iot.MbxBatteryPercent, "%", SENSOR_INTERVAL, func() (float64, error) {
+		return float64(batt.Last().Percent), nil
+	}))
+	register(sensor.New("battery-runtime", iot.MbxBatteryRuntime, "h", SENSOR_INTERVAL, func() (float64, error) {
+		if !batt.Last().RuntimeKnown {
+			return -1, nil
+		}
+		return batt.Last().Runtime.Hours(), nil
+	}))
+
 	//
 	// Setup Mule
 	//
-	muleInterruptEvents := make(chan string)
+	muleInterruptEvents := make(chan string, 1)
 	mulePin.Configure(machine.PinConfig{Mode: machine.PinInputPulldown})
 	log.Printf("mulePin status: %v\n", mulePin.Get())
 
-	mulePin.SetInterrupt(machine.PinRising, func(p machine.Pin) {
+	mulePin.SetInterrupt(machine.PinToggle, func(p machine.Pin) {
 
 		// Use non-blocking send so if the channel buffer is full,
 		// the value will get dropped instead of crashing the system
 		select {
-		case muleInterruptEvents <- "up":
+		case muleInterruptEvents <- "edge":
 		default:
 		}
 
@@ -93,54 +177,93 @@ func main() {
 	//
 	// Setup mail
 	//
-	mailInterruptEvents := make(chan string)
+	mailInterruptEvents := make(chan string, 1)
 	mailPin.Configure(machine.PinConfig{Mode: machine.PinInputPulldown})
-	log.Printf("mailPin status: %v\n", mulePin.Get())
+	log.Printf("mailPin status: %v\n", mailPin.Get())
 
-	mailPin.SetInterrupt(machine.PinRising, func(p machine.Pin) {
+	mailPin.SetInterrupt(machine.PinToggle, func(p machine.Pin) {
 
 		// Use non-blocking send so if the channel buffer is full,
 		// the value will get dropped instead of crashing the system
 		select {
-		case mailInterruptEvents <- "up":
+		case mailInterruptEvents <- "edge":
 		default:
 		}
 
 	})
 
+	mailDoor := input.New(mailInputConfig, time.Now())
+	mailEvents := make(chan input.Event, 5)
+	mule := input.New(muleInputConfig, time.Now())
+	muleEvents := make(chan input.Event, 5)
+
+	//
+	// Power management
+	//
+	// The node sleeps between heartbeats with the radio powered down.
+	// Door and mule events wake it so they are sent right away.
+	//
+	// While asleep the chip is in deep sleep with only the timer and GPIO clocks running,
+	// the USB serial stops too so expect the log to pause between heartbeats.
+	//
+	schedule := &power.Schedule{}
+	if err := schedule.Add(TASK_HEARTBEAT, time.Second*HEARTBEAT_DURATION_SECONDS, time.Now()); err != nil {
+		log.Panicf("main: heartbeat schedule: %v", err)
+	}
+
+	pm := power.NewManager(clock.System{}, schedule)
+	pm.AddSource(sensors)
+	pm.LowPower = power.RP2040{}
+	pm.OnSleep = radio.Sleep
+	pm.OnWake = func(reason power.WakeReason) {
+		log.Printf("Wake up, reason: %v", reason)
+		radio.Wake()
+	}
+
 	// Launch go routines
 
-	go mailMonitor(&mailInterruptEvents, &txQ)
-	go muleMonitor(&muleInterruptEvents, &txQ)
-	go radio.LoraRxTxRunner()
+	go mailDoor.Run(mailPin.Get, mailInterruptEvents, mailEvents)
+	go mule.Run(mulePin.Get, muleInterruptEvents, muleEvents)
+	go mailMonitor(mailEvents, &txQ, pm)
+	go muleMonitor(muleEvents, &txQ, pm)
+	go chargerMonitor(chargerTracker, chg, pgood, chargerInterruptEvents, &txQ, pm)
 
 	// Main loop
-	ticker := time.NewTicker(time.Second * HEARTBEAT_DURATION_SECONDS)
 	var count int
-	
-	for range ticker.C {
-		
-		log.Printf("------------------MainLoopHeartbeat-------------------- %v", count)
-		count += 1
-		log.Printf("mailPin status: %v\n", mailPin.Get())
-		log.Printf("mulePin status: %v\n", mulePin.Get())
 
-		//
-		// Send Heartbeat to Tx queue
-		//
-		txQ <- iot.MbxRoadMainLoopHeartbeat
+	for {
+
+		for _, task := range schedule.Due(time.Now()) {
+			switch task {
+			case TASK_HEARTBEAT:
+				log.Printf("------------------MainLoopHeartbeat-------------------- %v", count)
+				count += 1
+				log.Printf("mailPin status: %v\n", mailPin.Get())
+				log.Printf("mulePin status: %v\n", mulePin.Get())
+
+				//
+				// Send Heartbeat to Tx queue
+				//
+				txQ <- iot.MbxRoadMainLoopHeartbeat
+
+				//
+				// send the solar harvest summary
+				//
+				sendChargerHarvest(chargerTracker, &txQ)
+			}
+		}
 
 		//
-		// send charger status
+		// Send any sensor readings that are due to the Tx queue
 		//
-		sendChargerStatus(chg, pgood, &txQ)
+		sensors.Poll()
 
 		//
-		// Send Temperature to Tx queue
+		// Send whatever is queued while we are awake then go back to sleep
 		//
-		sendTemperature(&txQ)
-
+		radio.LoraRxTx()
 		runtime.Gosched()
+		pm.Sleep()
 	}
 
 }
@@ -151,59 +274,90 @@ func main() {
 //
 ///////////////////////////////////////////////////////////////////////////////
 
-func mailMonitor(ch *chan string, txQ *chan string) {
+func mailMonitor(events chan input.Event, txQ *chan string, pm *power.Manager) {
 
-	for range *ch {
-		log.Println("Mailbox light up")
-		*txQ <- iot.MbxDoorOpened
+	for e := range events {
+		log.Printf("Mailbox door %v after %v", e.Kind, e.Duration)
 
-		runtime.Gosched()
-		// Wait a long time to give mail man time to shut the door
-		time.Sleep(time.Second * 60)
-		log.Println("Mailbox light down")
+		switch e.Kind {
+		case input.Opened:
+			*txQ <- iot.MbxDoorOpened
+		case input.Closed:
+			*txQ <- fmt.Sprintf("%v:%v", iot.MbxDoorClosed, int(e.Duration.Seconds()))
+		case input.LeftOpen:
+			*txQ <- fmt.Sprintf("%v:%v", iot.MbxDoorLeftOpen, int(e.Duration.Seconds()))
+		}
 
+		// Wake the main loop so the event is sent now
+		pm.Wake("mail")
+		runtime.Gosched()
 	}
 
 }
 
-func muleMonitor(ch *chan string, txQ *chan string) {
+func muleMonitor(events chan input.Event, txQ *chan string, pm *power.Manager) {
 
-	for range *ch {
-		log.Println("Mule light up")
-		*txQ <- iot.MbxMuleAlarm
+	for e := range events {
+		if e.Kind == input.Opened {
+			log.Println("Mule light up")
+			*txQ <- iot.MbxMuleAlarm
+			pm.Wake("mule")
+		}
 
 		runtime.Gosched()
-		time.Sleep(time.Second * 4)
-		log.Println("Mule light down")
-
 	}
 }
 
-func sendTemperature(txQ *chan string) {
+// readTemperature reads the pico's onboard temperature sensor in fahrenheit
+func readTemperature() (float64, error) {
 
 	// F = ( (ReadTemperature /1000) * 9/5) + 32
-	fahrenheit := ((machine.ReadTemperature() / 1000) * 9 / 5) + 32
-	fmt.Printf("fahrenheit: %v\n", fahrenheit)
-	*txQ <- fmt.Sprintf("%v:%v",iot.MbxTemperature,fahrenheit)
+	fahrenheit := (float64(machine.ReadTemperature())/1000)*9/5 + 32
+	return fahrenheit, nil
 
 }
 
-func sendChargerStatus(chgPin machine.Pin, pgoodPin machine.Pin, txQ *chan string) {
+// chargerMonitor feeds the charger tracker on every edge of the CHG and PGOOD pins so a blinking CHG pin
+// is seen as a fault even while the node sleeps. Once the pin stops toggling the tracker is updated again
+// after the blink window so the fault clears. The state is sent as soon as it changes.
+func chargerMonitor(tracker *charger.Tracker, chgPin machine.Pin, pgoodPin machine.Pin, edges <-chan string, txQ *chan string, pm *power.Manager) {
+
+	reported := charger.Unknown
+
+	for {
+
+		state, _ := tracker.Update(chgPin.Get(), pgoodPin.Get(), time.Now())
+		if state != reported {
+			log.Printf("Charger state: %v was: %v", state, reported)
+			*txQ <- fmt.Sprintf("%v:%v", iot.MbxChargerState, state)
+			reported = state
+
+			// Wake the main loop so the state is sent now
+			pm.Wake("charger")
+		}
+
+		at, ok := tracker.RecheckAt()
+		if !ok {
+			<-edges
+			continue
+		}
 
-	if pgoodPin.Get() {
-		log.Println("Power source bad")
-		*txQ <- iot.MbxChargerPowerSourceBad
-	} else {
-		log.Println("Power source good")
-		*txQ <- iot.MbxChargerPowerSourceGood
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-edges:
+			timer.Stop()
+		case <-timer.C:
+		}
 	}
 
-	if chgPin.Get() {
-		log.Println("Charger off")
-		*txQ <- iot.MbxChargerChargeStatusOff
-	} else {
-		log.Println("Charger on")
-		*txQ <- iot.MbxChargerChargeStatusOn
+}
+
+// sendChargerHarvest sends the solar harvest summary once a day
+func sendChargerHarvest(tracker *charger.Tracker, txQ *chan string) {
+
+	if harvest, ok := tracker.DailyHarvest(time.Now()); ok {
+		log.Printf("Charger daily harvest: %v", harvest.Charging)
+		*txQ <- fmt.Sprintf("%v:%v", iot.MbxChargerHarvest, int(harvest.Charging.Minutes()))
 	}
 
 }
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"log"
+	"machine"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/tonygilkerson/mbx-iot/cmd/med/node"
+	"github.com/tonygilkerson/mbx-iot/internal/clock"
+	"github.com/tonygilkerson/mbx-iot/internal/med"
+	"github.com/tonygilkerson/mbx-iot/internal/road"
+	"github.com/tonygilkerson/mbx-iot/pkg/iot"
+	"tinygo.org/x/drivers/ds3231"
+	"tinygo.org/x/drivers/sx127x"
+)
+
+// The med node with LoRa, it is cmd/med set up by node but the dose events are sent to the gateway
+// so the display can show the last dose and raise the overdue alert in other rooms.
+// The radio is powered down except when there is something to send.
+func main() {
+	//
+	// Named PINs, the display and buttons are set up by node
+	//
+	var sdi machine.Pin = machine.GP16 // machine.SPI0_SDI_PIN
+	var sck machine.Pin = machine.GP18 // machine.SPI0_SCK_PIN
+	var sdo machine.Pin = machine.GP19 // machine.SPI0_SDO_PIN
+	var cs machine.Pin = machine.GP20
+	var rst machine.Pin = machine.GP21
+	var dio0 machine.Pin = machine.GP22 // Must be connected from pico to breakout for radio events IRQ to work
+	var dio1 machine.Pin = machine.GP26
+	var en machine.Pin = machine.GP27
+
+	const (
+		// How often the heartbeat and last dose are sent, keep in sync with MED_HEARTBEAT_SECONDS in the gateway
+		HEARTBEAT_DURATION_SECONDS = 300
+
+		// How often the txQ is checked, the radio is only woken when there is something to send
+		LORA_TX_SECONDS = 10
+
+		// Listen for the gateway time after a send when the clock was last set longer ago than this,
+		// the time is written to the RTC so the dose history can be trusted after a reboot
+		CLOCK_SYNC_HOURS = 24
+	)
+
+	n := node.Setup()
+
+	//
+	// 	Setup Lora
+	//
+	var loraRadio *sx127x.Device
+	txQ := make(chan string, 250) // I would hope the channel size would never be larger than ~4 so 250 is large
+	rxQ := make(chan string, 4)   // only the gateway time is used
+
+	radio := road.SetupLora(*machine.SPI0, en, rst, cs, dio0, dio1, sck, sdo, sdi, loraRadio, &txQ, &rxQ, 5_000, 10_000, LORA_TX_SECONDS, road.TxOnly)
+	radio.Sleep()
+
+	n.Tracker.PublishTo(&txQ)
+	go loraSender(&radio, &txQ, &rxQ, n.Clock, n.RTC, n.Tracker, time.Hour*CLOCK_SYNC_HOURS)
+	go heartbeat(n.Tracker, &txQ, time.Second*HEARTBEAT_DURATION_SECONDS)
+
+	n.Run()
+}
+
+/////////////////////////////////////////////////////////////////////////////
+// fn
+/////////////////////////////////////////////////////////////////////////////
+
+// loraSender wakes the radio when there is something on the txQ, sends it without listening and powers the
+// radio back down. When the clock was last set longer ago than syncEvery it listens after the send for the
+// time the gateway sends in reply to the heartbeat.
+func loraSender(radio *road.Radio, txQ *chan string, rxQ *chan string, clk *clock.Synced, rtc *ds3231.Device, medTracker *med.MedTracker, syncEvery time.Duration) {
+
+	ticker := time.NewTicker(time.Second * time.Duration(radio.TxRxLoopTickerSec))
+	for range ticker.C {
+		if len(*txQ) == 0 {
+			continue
+		}
+
+		radio.Wake()
+		for radio.Tx() {
+		}
+
+		if last, ok := clk.LastSync(); !ok || clk.Now().Sub(last) > syncEvery {
+			radio.Rx()
+			setClock(rxQ, clk, rtc, medTracker)
+		}
+
+		radio.Sleep()
+		runtime.Gosched()
+	}
+
+}
+
+// setClock sets the clock and the RTC from a gateway time on the rxQ
+func setClock(rxQ *chan string, clk *clock.Synced, rtc *ds3231.Device, medTracker *med.MedTracker) {
+
+	for {
+		var msgBatch string
+		select {
+		case msgBatch = <-*rxQ:
+		default:
+			return
+		}
+
+		for _, msg := range road.SplitMessageBatch(msgBatch) {
+			if !strings.HasPrefix(msg, iot.GatewayTime+":") {
+				continue
+			}
+
+			jump, err := clk.SetFromMessage(strings.TrimPrefix(msg, iot.GatewayTime+":"), rtc)
+			if err != nil {
+				log.Printf("setClock: [%v]: %v", msg, err)
+				continue
+			}
+			medTracker.ClockJumped(jump)
+			log.Printf("setClock: time is %v, moved %v", clk.Now(), jump)
+		}
+	}
+
+}
+
+// heartbeat sends the heartbeat, the last dose and the overdue state on each interval so the gateway
+// knows the node is alive and catches up after either side restarts
+func heartbeat(medTracker *med.MedTracker, txQ *chan string, interval time.Duration) {
+
+	var count int
+	for {
+		log.Printf("------------------MedMainLoopHeartbeat-------------------- %v", count)
+		count += 1
+
+		*txQ <- iot.MedMainLoopHeartbeat
+		*txQ <- medTracker.LastDoseMessage()
+		*txQ <- medTracker.OverdueMessage()
+
+		time.Sleep(interval)
+	}
+
+}
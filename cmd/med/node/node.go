@@ -0,0 +1,222 @@
+// Package node is the med node setup shared by cmd/med and cmd/med/lora.
+// It wires the buttons, buzzer, RTC, flash and display to the med tracker and runs the main loop,
+// the LoRa variant adds only the radio.
+package node
+
+import (
+	"image/color"
+	"log"
+	"machine"
+	"runtime"
+	"time"
+
+	"github.com/tonygilkerson/mbx-iot/internal/clock"
+	"github.com/tonygilkerson/mbx-iot/internal/dsp"
+	"github.com/tonygilkerson/mbx-iot/internal/med"
+	"github.com/tonygilkerson/mbx-iot/internal/med/dose"
+	"github.com/tonygilkerson/mbx-iot/internal/store"
+	"github.com/tonygilkerson/mbx-iot/internal/ui"
+	"tinygo.org/x/drivers/ds3231"
+	"tinygo.org/x/drivers/st7789"
+	"tinygo.org/x/drivers/tone"
+	"tinygo.org/x/tinyfont/freemono"
+)
+
+// Named PINs, see cmd/med/wiring.md
+var tookMedsButton machine.Pin = machine.GP2
+var sub1HrButton machine.Pin = machine.GP3
+var rtcSDA machine.Pin = machine.GP4
+var rtcSCL machine.Pin = machine.GP5
+var buzzerPin machine.Pin = machine.GP7
+var dspDC machine.Pin = machine.GP8
+var dspCS machine.Pin = machine.GP9
+var dspSCK machine.Pin = machine.GP10
+var dspSDO machine.Pin = machine.GP11
+var dspReset machine.Pin = machine.GP12
+var dspBackLight machine.Pin = machine.GP13
+var add1HrButton machine.Pin = machine.GP15
+var add30MButton machine.Pin = machine.GP17
+var dspSDI machine.Pin = machine.GP28
+
+var led machine.Pin = machine.GPIO25 // GP25 machine.LED
+
+const (
+	// Flash offset of the saved med tracker state
+	MED_RECORD_OFFSET = 0
+
+	// Dose schedule
+	DOSE_INTERVAL_HOURS   = 6
+	DOSE_OVERDUE_MINUTES  = 15
+	DOSE_ESCALATE_MINUTES = 10
+	MAX_DOSES_PER_DAY     = 4
+	BLOCK_DOSES_OVER_MAX  = true
+
+	// Screen backlight percent and idle timeouts, the pages switch every SCREEN_PAGE_SECONDS while it is on
+	SCREEN_BRIGHT       = 100
+	SCREEN_DIM          = 20
+	SCREEN_DIM_SECONDS  = 20
+	SCREEN_OFF_SECONDS  = 60
+	SCREEN_PAGE_SECONDS = 4
+)
+
+// Node is the med tracker with the clock and display it runs on
+type Node struct {
+	Tracker *med.MedTracker
+	Clock   *clock.Synced
+	RTC     *ds3231.Device
+
+	display   st7789.Device
+	screen    *ui.Screen
+	backlight *ui.Backlight
+}
+
+// Setup starts the run light and sets up the buzzer, the clock, the med tracker and the display
+func Setup() *Node {
+
+	var n Node
+
+	//
+	// run light
+	//
+	led.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	dsp.RunLight(led, 10)
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	//
+	// PWM for tone alarm
+	//
+	buzzer, err := tone.New(machine.PWM3, buzzerPin)
+	if err != nil {
+		log.Panicln("failed to configure buzzer")
+	}
+
+	//
+	// Real time clock
+	//
+	n.Clock = clock.NewSynced(clock.System{})
+	machine.I2C0.Configure(machine.I2CConfig{SDA: rtcSDA, SCL: rtcSCL})
+	rtc := ds3231.New(machine.I2C0)
+	rtc.Configure()
+	n.RTC = &rtc
+	if err := n.Clock.SyncFrom(n.RTC); err != nil {
+		log.Printf("RTC: %v, the last taken time can't be trusted after a reboot", err)
+	} else {
+		log.Printf("RTC: time is %v", n.Clock.Now())
+	}
+
+	//
+	// Med Device
+	//
+	medRecord, err := store.NewRecord(machine.Flash, MED_RECORD_OFFSET)
+	if err != nil {
+		log.Panicln("failed to create med record")
+	}
+
+	n.Tracker = med.New(add1HrButton, sub1HrButton, add30MButton, tookMedsButton, buzzer, medRecord, n.Clock, dose.Schedule{
+		Interval:      time.Hour * DOSE_INTERVAL_HOURS,
+		OverdueAfter:  time.Minute * DOSE_OVERDUE_MINUTES,
+		MaxDoses:      MAX_DOSES_PER_DAY,
+		BlockOverMax:  BLOCK_DOSES_OVER_MAX,
+		EscalateEvery: time.Minute * DOSE_ESCALATE_MINUTES,
+	})
+	go n.Tracker.KeyPressChannelConsumer()
+
+	//
+	// Display
+	//
+	machine.SPI1.Configure(machine.SPIConfig{
+		Frequency: 8000000,
+		LSBFirst:  false,
+		Mode:      0,
+		SCK:       dspSCK,
+		SDO:       dspSDO,
+		SDI:       dspSDI, // I don't think this is actually used for LCD, just assign to any open pin
+	})
+
+	n.display = st7789.New(machine.SPI1,
+		dspReset,     // TFT_RESET
+		dspDC,        // TFT_DC
+		dspCS,        // TFT_CS
+		dspBackLight) // TFT_LITE
+
+	n.display.Configure(st7789.Config{
+		// With the display in portrait and the usb socket on the left and in the back
+		// the actual width and height are switched width=320 and height=240
+		Width:        240,
+		Height:       320,
+		Rotation:     st7789.ROTATION_90,
+		RowOffset:    0,
+		ColumnOffset: 0,
+		FrameRate:    st7789.FRAMERATE_111,
+		VSyncLines:   st7789.MAX_VSYNC_SCANLINES,
+	})
+
+	width, height := n.display.Size()
+	log.Printf("width: %v, height: %v\n", width, height)
+
+	red := color.RGBA{255, 0, 0, 255}
+	black := color.RGBA{0, 0, 0, 255}
+
+	//
+	// Screen
+	//
+	// Only the views that change are redrawn. A key wakes the screen, it dims and
+	// then turns off when idle. While it is on it switches between the pages.
+	//
+	n.screen = ui.NewScreen(&n.display, black, pages(n.Tracker, &freemono.Regular12pt7b, red)...)
+
+	light, err := ui.NewPWMLight(machine.PWM6, dspBackLight)
+	if err != nil {
+		log.Panicln("failed to configure backlight")
+	}
+	n.backlight = ui.NewBacklight(light, ui.BacklightConfig{
+		Bright:   SCREEN_BRIGHT,
+		Dim:      SCREEN_DIM,
+		DimAfter: time.Second * SCREEN_DIM_SECONDS,
+		OffAfter: time.Second * SCREEN_OFF_SECONDS,
+	}, time.Now())
+	n.Tracker.WakeOnKey(n.backlight)
+
+	return &n
+}
+
+// Run is the main loop, it draws the screen and sounds the alarm and never returns
+func (n *Node) Run() {
+
+	log.Printf("start")
+
+	screenOn := true
+	pageAt := time.Now()
+
+	for {
+
+		n.backlight.Step(time.Now())
+
+		// The panel is only talked to from here, wake it or put it to sleep when the backlight changes
+		switch {
+		case n.backlight.IsOn() && !screenOn:
+			n.display.Sleep(false)
+			n.screen.SetPage(0)
+			pageAt = time.Now()
+			screenOn = true
+		case !n.backlight.IsOn() && screenOn:
+			n.display.Sleep(true)
+			screenOn = false
+		}
+
+		if screenOn {
+			if time.Since(pageAt) >= time.Second*SCREEN_PAGE_SECONDS {
+				n.screen.NextPage()
+				pageAt = time.Now()
+			}
+			n.screen.Draw(n.Clock.Now())
+		}
+
+		// Sound the buzzer when the dose is overdue
+		n.Tracker.SoundAlarm()
+
+		runtime.Gosched()
+		time.Sleep(time.Millisecond * 250)
+	}
+
+}
@@ -0,0 +1,93 @@
+package node
+
+import (
+	"fmt"
+	"image/color"
+	"time"
+
+	"github.com/tonygilkerson/mbx-iot/internal/med"
+	"github.com/tonygilkerson/mbx-iot/internal/med/dose"
+	"github.com/tonygilkerson/mbx-iot/internal/ui"
+	"tinygo.org/x/tinyfont"
+)
+
+// pages are the screens of the med display, the status and the dose history.
+// The regions are laid out for a 320x240 screen and a font with a 24 pixel line.
+func pages(mt *med.MedTracker, font tinyfont.Fonter, c color.RGBA) []ui.Page {
+
+	view := func(name string, region ui.Rect, text func(mt *med.MedTracker, now time.Time) string) ui.View {
+		return ui.View{Name: name, Region: region, Font: font, Color: c, Text: func(now time.Time) string { return text(mt, now) }}
+	}
+
+	return []ui.Page{
+		{
+			Name: "status",
+			Views: []ui.View{
+				view("last taken", ui.Rect{X: 10, Y: 0, W: 300, H: 52}, lastTakenText),
+				view("next due", ui.Rect{X: 10, Y: 56, W: 300, H: 26}, nextDueText),
+				view("today", ui.Rect{X: 10, Y: 84, W: 300, H: 26}, todayText),
+				view("warning", ui.Rect{X: 10, Y: 112, W: 300, H: 26}, func(mt *med.MedTracker, _ time.Time) string { return mt.Warning() }),
+			},
+		},
+		{
+			Name: "history",
+			Views: []ui.View{
+				view("history", ui.Rect{X: 10, Y: 0, W: 300, H: 240}, historyText),
+			},
+		},
+	}
+}
+
+// lastTakenText is when the meds were last taken
+func lastTakenText(mt *med.MedTracker, now time.Time) string {
+
+	lastTaken, known := mt.GetLastTakenMedsAt()
+	if !known {
+		return "Last taken:\nunknown"
+	}
+	return fmt.Sprintf("Last taken:\n%1.2fh hours ago", now.Sub(lastTaken).Hours())
+}
+
+// nextDueText is where the tracker is in the dose schedule, it is empty if that is unknown
+func nextDueText(mt *med.MedTracker, now time.Time) string {
+
+	status := mt.Status()
+	switch status.State {
+	case dose.Waiting:
+		return fmt.Sprintf("Next in %1.1fh", status.NextDue.Sub(now).Hours())
+	case dose.Due:
+		return "Dose due now"
+	case dose.Overdue:
+		return fmt.Sprintf("OVERDUE %1.1fh", now.Sub(status.NextDue).Hours())
+	}
+	return ""
+}
+
+// todayText is the number of doses taken in the last day
+func todayText(mt *med.MedTracker, now time.Time) string {
+	return fmt.Sprintf("Today: %v of %v", mt.Status().DosesToday, mt.Schedule().MaxDoses)
+}
+
+// historyText lists the doses of the last 24 hours, newest first, adjusted doses are marked with a *
+func historyText(mt *med.MedTracker, now time.Time) string {
+
+	history, known := mt.GetHistory()
+	if !known {
+		return "Last 24h:\nunknown"
+	}
+
+	doses := history.Since(now.Add(-time.Hour * 24))
+	if len(doses) == 0 {
+		return "Last 24h:\nno doses"
+	}
+
+	s := "Last 24h:"
+	for i := len(doses) - 1; i >= 0; i-- {
+		mark := ""
+		if doses[i].Adjusted {
+			mark = " *"
+		}
+		s += fmt.Sprintf("\n%1.1fh ago%v", now.Sub(doses[i].At).Hours(), mark)
+	}
+	return s
+}
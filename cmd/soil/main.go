@@ -5,26 +5,59 @@ import (
 	"log"
 	"machine"
 	"runtime"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	tm1637mod "github.com/tonygilkerson/mbx-iot/hack/driver/tm1637"
+	"github.com/tonygilkerson/mbx-iot/internal/battery"
+	"github.com/tonygilkerson/mbx-iot/internal/clock"
 	"github.com/tonygilkerson/mbx-iot/internal/dsp"
 	"github.com/tonygilkerson/mbx-iot/internal/hbridge"
+	"github.com/tonygilkerson/mbx-iot/internal/input"
+	"github.com/tonygilkerson/mbx-iot/internal/marquee"
 	"github.com/tonygilkerson/mbx-iot/internal/road"
+	"github.com/tonygilkerson/mbx-iot/internal/sensor"
 	"github.com/tonygilkerson/mbx-iot/internal/soil"
+	"github.com/tonygilkerson/mbx-iot/internal/store"
+	"github.com/tonygilkerson/mbx-iot/internal/tm1637"
 	"github.com/tonygilkerson/mbx-iot/internal/util"
+	"github.com/tonygilkerson/mbx-iot/internal/water"
 	"github.com/tonygilkerson/mbx-iot/pkg/iot"
 
 	"tinygo.org/x/drivers/sx127x"
 )
 
+// Addresses of the soil probes on the I2C bus, one per bed, set with the AD0/AD1 jumpers on each probe.
+// The first probe is the one shown on the display.
+var soilProbeAddresses = []uint16{soil.Address}
+
+// The Pico measures VSYS on ADC3 (GP29) through a divider of three
+var batteryConfig = battery.Config{
+	Divider: 3,
+	VRef:    3.3,
+	Scale:   1,
+	Offset:  0,
+}
+
+// screenState is what the display screens show, it is updated by the main loop and read by the marquee
+type screenState struct {
+	mu sync.Mutex
+
+	moisture         uint16
+	moisturePercent  float64
+	calibrated       bool
+	moistureKnown    bool
+	temperature      float64
+	temperatureKnown bool
+	lastHeard        time.Time
+}
+
 func main() {
 
 	//
 	// Named PINs
 	//
+	var calibrateButton machine.Pin = machine.GP3
 	var vibrationPin machine.Pin = machine.GP5
 	var hBridgeEnable machine.Pin = machine.GP6
 	var hBridgeIn1 machine.Pin = machine.GP7
@@ -33,6 +66,7 @@ func main() {
 	var tm1637DIO machine.Pin = machine.GP11
 	var soilSDA machine.Pin = machine.GP12
 	var soilSCL machine.Pin = machine.GP13
+	var vsysPin machine.Pin = machine.GP29 // ADC3 VSYS/3
 	var loraEn machine.Pin = machine.GP15
 	var loraSdi machine.Pin = machine.GP16 // machine.SPI0_SDI_PIN
 	var loraCs machine.Pin = machine.GP17
@@ -46,6 +80,39 @@ func main() {
 
 	const (
 		HEARTBEAT_DURATION_SECONDS = 600
+
+		// Listen for downlink commands this often, a command waits at most this long plus the gateway retry
+		LORA_TXRX_SECONDS = 30
+
+		// When the soil sensor keeps failing reinitialize the I2C bus every few
+		// failures and as a last resort let the watchdog reset the node
+		SENSOR_REINIT_AFTER_FAILURES = 3
+		SENSOR_RESET_AFTER_FAILURES  = 12
+
+		// Number of moisture readings averaged into each reported reading
+		MOISTURE_SAMPLES = 8
+
+		// Flash offset of the saved probe calibrations
+		CALIBRATION_RECORD_OFFSET = 0
+
+		// Watering, water when the first probe reads below the threshold
+		WATER_THRESHOLD_PERCENT    = 30
+		WATER_MAX_RUN_SECONDS      = 120
+		WATER_MIN_INTERVAL_MINUTES = 60
+		WATER_DAILY_BUDGET_MINUTES = 10
+
+		// Ids of the last few commands applied, a resent command is acked again but not applied twice
+		COMMAND_IDS_KEPT = 8
+
+		// The display wakes on vibration, dims and then turns off
+		DISPLAY_DWELL_SECONDS = 2
+		DISPLAY_DIM_SECONDS   = 30
+		DISPLAY_OFF_SECONDS   = 60
+		DISPLAY_BRIGHT        = 5
+		DISPLAY_DIM           = 1
+
+		// The link is shown as lost when nothing has been heard from the gateway for this long
+		LINK_LOST_SECONDS = 600
 	)
 
 	//
@@ -72,16 +139,52 @@ func main() {
 
 	})
 
+	//
+	// Configure the calibrate button
+	//
+	calibrateEdges := make(chan string, 1)
+	calibrateButton.Configure(machine.PinConfig{Mode: machine.PinInputPulldown})
+	calibrateButton.SetInterrupt(machine.PinToggle, func(p machine.Pin) {
+		select {
+		case calibrateEdges <- "edge":
+		default:
+		}
+	})
+	calibrateEvents := make(chan input.Event, 1)
+	go input.New(input.Config{Debounce: time.Millisecond * 30}, time.Now()).Run(calibrateButton.Get, calibrateEdges, calibrateEvents)
+
 	//
 	// Configure L293D
 	//
+	// The valve and watering times are wall clock times once the gateway time broadcast is received
+	clk := clock.NewSynced(clock.System{})
+
 	log.Println("Configure L293D Pins")
-	hbridge := hbridge.New(hBridgeEnable, hBridgeIn1, hBridgeIn2)
+	valve := hbridge.New(hBridgeEnable, hBridgeIn1, hBridgeIn2, clk)
+
+	//
+	// Watering controller, the hbridge drives the valve solenoid
+	//
+	waterController := water.New(water.Config{
+		Threshold:   WATER_THRESHOLD_PERCENT,
+		MaxRun:      time.Second * WATER_MAX_RUN_SECONDS,
+		MinInterval: time.Minute * WATER_MIN_INTERVAL_MINUTES,
+		DailyBudget: time.Minute * WATER_DAILY_BUDGET_MINUTES,
+	}, valve, clk.Now())
 
 	//
 	// Configure 4 digit 7-segment display
 	//
-	tm := tm1637mod.New(tm1637CLK, tm1637DIO, 5)
+	tm := tm1637.New(tm1637.NewPinLink(tm1637CLK, tm1637DIO, tm1637.DefaultDelay), tm1637.Config{Digits: 4, Brightness: DISPLAY_BRIGHT})
+
+	//
+	// Setup battery monitor
+	//
+	machine.InitADC()
+	vsysADC := machine.ADC{Pin: vsysPin}
+	vsysADC.Configure(machine.ADCConfig{})
+	batt := battery.New(vsysADC, batteryConfig)
+	batt.Read(time.Now())
 
 	//
 	// Configure I2C
@@ -90,7 +193,44 @@ func main() {
 	err := i2c.Configure(machine.I2CConfig{SDA: soilSDA, SCL: soilSCL})
 	util.DoOrDie(err)
 
-	soil := soil.New(i2c)
+	var probes []*soil.Device
+	for _, address := range soilProbeAddresses {
+		probe, err := soil.NewWithAddress(i2c, address)
+		util.DoOrDie(err)
+		probe.Unit = soil.Fahrenheit
+
+		// Don't die if a probe is missing, the sensor scheduler will report the read errors
+		if err := probe.Configure(); err != nil {
+			log.Printf("Soil probe 0x%02x configure error: %v", address, err)
+		} else {
+			product, date, _ := probe.ReadVersion()
+			log.Printf("Soil probe 0x%02x product: %v date: %v", address, product, date)
+		}
+		probes = append(probes, probe)
+	}
+
+	//
+	// Load the probe calibrations saved in flash
+	//
+	calibrationRecord, err := store.NewRecord(machine.Flash, CALIBRATION_RECORD_OFFSET)
+	util.DoOrDie(err)
+	calibrations := loadCalibrations(calibrationRecord)
+	var calibrator soil.Calibrator
+
+	//
+	// Marquee, show the screens when the vibration sensor trips
+	//
+	var state screenState
+	display := marquee.New(tm, marquee.Config{
+		Screens:  screens(&state, valve, clk, batt, time.Second*LINK_LOST_SECONDS),
+		Dwell:    time.Second * DISPLAY_DWELL_SECONDS,
+		Bright:   DISPLAY_BRIGHT,
+		Dim:      DISPLAY_DIM,
+		DimAfter: time.Second * DISPLAY_DIM_SECONDS,
+		OffAfter: time.Second * DISPLAY_OFF_SECONDS,
+	})
+	display.Wake(time.Now())
+	go display.Run(chVibration)
 
 	//
 	// 	Setup Lora
@@ -115,21 +255,52 @@ func main() {
 		&rxQ,
 		10_000,
 		10_000,
-		HEARTBEAT_DURATION_SECONDS+1, // rule of thumb HEARTBEAT_DURATION_SECONDS + 1
-		road.TxOnly)
+		LORA_TXRX_SECONDS,
+		road.TxRx)
 
 	// Routine to send and receive
 	go radio.LoraRxTxRunner()
 
+	//
+	// Sensors
+	//
+	sensors := sensor.NewScheduler(clock.System{}, &txQ)
+	sensors.ErrorKey = iot.SoilSensorError
+	sensors.Recovery = sensor.Recovery{
+		ReinitAfter: SENSOR_REINIT_AFTER_FAILURES,
+		Reinit: func() error {
+			return i2c.Configure(machine.I2CConfig{SDA: soilSDA, SCL: soilSCL})
+		},
+		ResetAfter: SENSOR_RESET_AFTER_FAILURES,
+		Reset:      watchdogReset,
+	}
+	register := func(s sensor.Sensor) {
+		if err := sensors.Register(s); err != nil {
+			log.Panicf("main: register sensor %v: %v", s.Name(), err)
+		}
+	}
+	for _, probe := range probes {
+		probe := probe
+		// The moisture is sent as raw,percent
+		register(sensor.New(probe.Key("soil-moisture"), probe.Key(iot.SoilMoisture), "", time.Second*HEARTBEAT_DURATION_SECONDS, func() (float64, error) {
+			moisture, err := probe.ReadMoistureAverage(MOISTURE_SAMPLES)
+			return float64(moisture), err
+		}).WithFormat(func(raw float64) string {
+			// An uncalibrated probe sends -1 so it is not mistaken for bone dry
+			cal := calibrations[probe.Address]
+			if !cal.IsValid() {
+				return fmt.Sprintf("%v,-1", raw)
+			}
+			return fmt.Sprintf("%v,%v", raw, sensor.FormatValue(cal.Percent(uint16(raw))))
+		}))
+		register(sensor.New(probe.Key("soil-temperature"), probe.Key(iot.SoilTemperature), "F", time.Second*HEARTBEAT_DURATION_SECONDS, probe.ReadTemperature))
+	}
+
 	//
 	// Main loop
 	//
 	lastSoilReading := time.Now()
-	var showMarquee bool = true
-	var moistureReading uint16
-	var temperatureReading float64
-
-	var hbridgeToggel bool
+	applied := water.NewApplied(COMMAND_IDS_KEPT)
 
 	for {
 
@@ -145,65 +316,59 @@ func main() {
 			txQ <- iot.SoilMainLoopHeartbeat
 			dsp.RunLight(led, 2)
 
-			// Send Moisture reading  to Tx queue
-			moistureReading, err = soil.ReadMoisture()
-			util.DoOrDie(err)
-			txQ <- fmt.Sprintf("%v:%v", iot.SoilMoisture, moistureReading)
-			time.Sleep(time.Second)
+			// Valve accounting
+			sendValveStats(valve, clk.Now(), txQ)
 
-			// Send Temperature(F) to Tx queue
-			temperatureReading, err = soil.ReadTemperature()
-			util.DoOrDie(err)
-			txQ <- fmt.Sprintf("%v:%v", iot.SoilTemperature, temperatureReading)
-			time.Sleep(time.Second)
-
-			// hbridge not used yet
-			hbridge.Off()
+			// The battery screen shows the last reading, it runs on the marquee goroutine
+			batt.Read(time.Now())
 
 		}
 
-		// hbridge test, toggle it on and off each time
-		hbridgeToggel = !hbridgeToggel
-		if hbridgeToggel {
-			log.Println("HBRIDGE test [ ON ]")
-			hbridge.TurnOn()
-		} else {
-			log.Println("HBRIDGE test [ OFF ]")
-			hbridge.TurnOff()
+		//
+		// Send any sensor readings that are due to the Tx queue
+		//
+		for _, r := range sensors.Poll() {
+			if r.Err != nil {
+				// The scheduler reports the error and retries
+				continue
+			}
+
+			// Only the first probe is shown on the display and drives the watering
+			switch r.Key {
+			case probes[0].Key(iot.SoilMoisture):
+				cal := calibrations[probes[0].Address]
+				state.setMoisture(uint16(r.Value), cal)
+				if cal.IsValid() {
+					sendWaterEvents(waterController.Moisture(cal.Percent(uint16(r.Value)), clk.Now()), txQ)
+				} else {
+					log.Println("Watering: probe not calibrated, not watering")
+				}
+			case probes[0].Key(iot.SoilTemperature):
+				state.setTemperature(r.Value)
+			}
 		}
 
 		//
-		// Vibration sensor tripped
+		// Downlink commands from the gateway
 		//
-		select {
-		case <-chVibration:
-			showMarquee = true
-		default:
+		if handleCommands(rxQ, txQ, waterController, applied, clk, valve) {
+			state.heard(time.Now())
 		}
 
 		//
-		// Marquee Display
+		// Close the valve once it has run long enough
+		//
+		sendWaterEvents(waterController.Step(clk.Now()), txQ)
+
+		//
+		// Calibrate button pressed
 		//
-		if showMarquee {
-			// moisture
-			log.Printf("Moisture: %v\n", moistureReading)
-			tm.DisplayNumber(int16(moistureReading))
-			time.Sleep(time.Second * 2)
-			// temperature
-			log.Printf("Temperature (F): %v\n", temperatureReading)
-			tm.DisplayNumber(int16(temperatureReading))
-			time.Sleep(time.Second * 2)
-			// age
-			age := hbridge.GetTurnOnAge()
-			ageParts := strings.Split(age, ".")
-			h, _ := strconv.Atoi(ageParts[0])
-			m, _ := strconv.Atoi(ageParts[1])
-			log.Printf("Age: %v h: %v m: %v\n", age, h, m)
-			tm.DisplayClock(uint8(h), uint8(m), true)
-			time.Sleep(time.Second * 2)
-
-			tm.ClearDisplay()
-			showMarquee = false
+		select {
+		case e := <-calibrateEvents:
+			if e.Kind == input.Opened {
+				calibrate(&calibrator, probes, calibrations, calibrationRecord, display)
+			}
+		default:
 		}
 
 		//
@@ -215,3 +380,297 @@ func main() {
 	}
 
 }
+
+///////////////////////////////////////////////////////////////////////////////
+//
+//	Functions
+//
+///////////////////////////////////////////////////////////////////////////////
+
+// loadCalibrations returns the saved probe calibrations by probe address, if nothing has been saved the map is empty
+func loadCalibrations(record *store.Record) map[uint16]soil.Calibration {
+
+	calibrations := make(map[uint16]soil.Calibration)
+
+	buf := make([]byte, 64)
+	n, err := record.Load(buf)
+	if err != nil {
+		log.Printf("loadCalibrations: no saved calibrations: %v", err)
+		return calibrations
+	}
+
+	cals, err := soil.DecodeCalibrations(buf[:n])
+	if err != nil {
+		log.Printf("loadCalibrations: %v", err)
+		return calibrations
+	}
+
+	for _, c := range cals {
+		log.Printf("loadCalibrations: probe 0x%02x dry: %v wet: %v", c.Address, c.Dry, c.Wet)
+		calibrations[c.Address] = c
+	}
+	return calibrations
+}
+
+// calibrate moves the calibration to the next step each time the calibrate button is pressed
+//
+//	1st press - shows "dry", put the probes in dry soil
+//	2nd press - captures the dry readings and shows "wet", put the probes in wet soil
+//	3rd press - captures the wet readings, saves the calibration and shows "done"
+func calibrate(calibrator *soil.Calibrator, probes []*soil.Device, calibrations map[uint16]soil.Calibration, record *store.Record, display *marquee.Marquee) {
+
+	var readings []uint16
+	if calibrator.Step() != soil.CalibrationIdle {
+		for _, p := range probes {
+			moisture, err := p.ReadMoistureAverage(16)
+			if err != nil {
+				log.Printf("calibrate: probe 0x%02x read error: %v", p.Address, err)
+				display.Show("Err ", time.Now())
+				return
+			}
+			readings = append(readings, moisture)
+		}
+	}
+
+	cals, done := calibrator.Press(probes, readings)
+	if !done {
+		switch calibrator.Step() {
+		case soil.CalibrationDry:
+			log.Println("calibrate: put the probes in dry soil and press again")
+			display.Show("dry ", time.Now())
+		case soil.CalibrationWet:
+			log.Printf("calibrate: dry readings %v, put the probes in wet soil and press again", readings)
+			display.Show("wet ", time.Now())
+		}
+		return
+	}
+
+	log.Printf("calibrate: wet readings %v", readings)
+	for _, c := range cals {
+		if !c.IsValid() {
+			log.Printf("calibrate: probe 0x%02x wet %v must read higher than dry %v, not saved", c.Address, c.Wet, c.Dry)
+			display.Show("Err ", time.Now())
+			return
+		}
+	}
+
+	for _, c := range cals {
+		calibrations[c.Address] = c
+	}
+
+	var all []soil.Calibration
+	for _, c := range calibrations {
+		all = append(all, c)
+	}
+	if err := record.Save(soil.EncodeCalibrations(all)); err != nil {
+		log.Printf("calibrate: save error: %v", err)
+		display.Show("Err ", time.Now())
+		return
+	}
+
+	display.Show("done", time.Now())
+}
+
+// sendWaterEvents sends the watering events to the Tx queue
+func sendWaterEvents(events []water.Event, txQ chan string) {
+
+	for _, e := range events {
+		log.Printf("sendWaterEvents: %v %v (%v)", e.Kind, e.Reason, e.Duration)
+		switch e.Kind {
+		case water.Started:
+			txQ <- fmt.Sprintf("%v:%v", iot.SoilWateringStarted, e.Reason)
+		case water.Stopped:
+			txQ <- fmt.Sprintf("%v:%v,%v", iot.SoilWateringStopped, int(e.Duration.Seconds()), e.Reason)
+		}
+	}
+
+}
+
+// screens are the marquee screens: moisture, temperature, time since last watered, battery and link status
+func screens(state *screenState, valve *hbridge.Device, clk clock.Clock, batt *battery.Monitor, linkLost time.Duration) []marquee.Screen {
+
+	return []marquee.Screen{
+		{Name: "moisture", Show: func(d marquee.Display) bool {
+			state.mu.Lock()
+			defer state.mu.Unlock()
+
+			if !state.moistureKnown {
+				return false
+			}
+			// as a percent once the probe is calibrated
+			if state.calibrated {
+				d.DisplayNumber(int16(state.moisturePercent + 0.5))
+			} else {
+				d.DisplayNumber(int16(state.moisture))
+			}
+			return true
+		}},
+
+		{Name: "temperature", Show: func(d marquee.Display) bool {
+			state.mu.Lock()
+			defer state.mu.Unlock()
+
+			if !state.temperatureKnown {
+				return false
+			}
+			d.DisplayText([]byte(fmt.Sprintf("%3d*", int(state.temperature))))
+			return true
+		}},
+
+		{Name: "last-watered", Show: func(d marquee.Display) bool {
+			// time since last watered as HH:MM
+			if age, ok := valve.SinceTurnOn(clk.Now()); ok {
+				h, m := hoursMinutes(age)
+				d.DisplayClock(h, m, true)
+			} else {
+				d.DisplayText([]byte("----"))
+			}
+			return true
+		}},
+
+		{Name: "battery", Show: func(d marquee.Display) bool {
+			d.DisplayText([]byte(fmt.Sprintf("b%3d", batt.Last().Percent)))
+			return true
+		}},
+
+		{Name: "link", Show: func(d marquee.Display) bool {
+			state.mu.Lock()
+			defer state.mu.Unlock()
+
+			if !state.lastHeard.IsZero() && time.Since(state.lastHeard) < linkLost {
+				d.DisplayText([]byte("Good"))
+			} else {
+				d.DisplayText([]byte("Lost"))
+			}
+			return true
+		}},
+	}
+
+}
+
+func (s *screenState) setMoisture(raw uint16, cal soil.Calibration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.moisture = raw
+	s.moistureKnown = true
+	s.calibrated = cal.IsValid()
+	s.moisturePercent = cal.Percent(raw)
+}
+
+func (s *screenState) setTemperature(t float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.temperature = t
+	s.temperatureKnown = true
+}
+
+func (s *screenState) heard(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastHeard = now
+}
+
+// sendValveStats sends the valve run time accounting to the Tx queue
+func sendValveStats(valve *hbridge.Device, now time.Time, txQ chan string) {
+
+	stats := valve.Stats(now)
+
+	lastOpened := -1
+	if age, ok := valve.SinceTurnOn(now); ok {
+		lastOpened = int(age.Seconds())
+	}
+
+	log.Printf("sendValveStats: on: %v cycles: %v last opened: %vs ago", stats.OnTime, stats.Cycles, lastOpened)
+	txQ <- fmt.Sprintf("%v:%v", iot.SoilValveOnSeconds, int(stats.OnTime.Seconds()))
+	txQ <- fmt.Sprintf("%v:%v", iot.SoilValveCycles, stats.Cycles)
+	txQ <- fmt.Sprintf("%v:%v", iot.SoilValveLastOpened, lastOpened)
+
+}
+
+// hoursMinutes splits a duration into hours and minutes for the clock display, it tops out at 99:59
+func hoursMinutes(d time.Duration) (h uint8, m uint8) {
+
+	if d >= time.Hour*100 {
+		return 99, 59
+	}
+	return uint8(d / time.Hour), uint8(d % time.Hour / time.Minute)
+
+}
+
+// handleCommands applies the commands received from the gateway and acks them, it returns true if anything was received.
+// The gateway resends a command until it sees the ack so a repeated id is acked again but not applied twice.
+// The gateway time broadcast sets the clock, the valve and the controller move their times with it.
+func handleCommands(rxQ chan string, txQ chan string, controller *water.Controller, applied *water.Applied, clk *clock.Synced, valve *hbridge.Device) (heard bool) {
+
+	for {
+		var msgBatch string
+		select {
+		case msgBatch = <-rxQ:
+			heard = true
+		default:
+			return heard
+		}
+
+		for _, msg := range road.SplitMessageBatch(msgBatch) {
+
+			if strings.HasPrefix(msg, iot.GatewayTime+":") {
+				jump, err := clk.SetFromMessage(strings.TrimPrefix(msg, iot.GatewayTime+":"), nil)
+				if err != nil {
+					log.Printf("handleCommands: bad time [%v]: %v", msg, err)
+					continue
+				}
+				valve.ClockJumped(jump)
+				controller.ClockJumped(jump)
+				continue
+			}
+
+			if !strings.HasPrefix(msg, iot.SoilCommand+":") {
+				continue
+			}
+
+			cmd, err := water.ParseCommand(strings.TrimPrefix(msg, iot.SoilCommand+":"))
+			if err != nil {
+				log.Printf("handleCommands: [%v] %v", msg, err)
+				continue
+			}
+
+			// The ack may have been lost, ack it again with the same result
+			if result, ok := applied.Result(cmd.ID); ok {
+				log.Printf("handleCommands: [%v] already applied", msg)
+				txQ <- fmt.Sprintf("%v:%v,%v", iot.SoilCommandAck, cmd.ID, result)
+				continue
+			}
+
+			events, err := controller.Apply(cmd, clk.Now())
+			if err != nil {
+				log.Printf("handleCommands: [%v] %v", msg, err)
+				result := strings.TrimPrefix(err.Error(), "water: ")
+				applied.Add(cmd.ID, result)
+				txQ <- fmt.Sprintf("%v:%v,%v", iot.SoilCommandAck, cmd.ID, result)
+				continue
+			}
+
+			log.Printf("handleCommands: applied [%v]", msg)
+			applied.Add(cmd.ID, "ok")
+			txQ <- fmt.Sprintf("%v:%v,ok", iot.SoilCommandAck, cmd.ID)
+			sendWaterEvents(events, txQ)
+		}
+	}
+
+}
+
+// watchdogReset starts the watchdog and stops feeding it so the node resets
+func watchdogReset() {
+
+	log.Println("watchdogReset: reset by watchdog")
+	machine.Watchdog.Configure(machine.WatchdogConfig{TimeoutMillis: 1_000})
+	machine.Watchdog.Start()
+
+	for {
+		time.Sleep(time.Second)
+	}
+
+}
@@ -0,0 +1,204 @@
+// Package battery estimates the state of a single cell Li-ion battery from an ADC reading.
+//
+// The battery is measured through a voltage divider on an ADC pin. Readings are calibrated,
+// smoothed and converted to a percent using a typical Li-ion discharge curve. The discharge
+// rate over time is used to estimate the remaining runtime.
+package battery
+
+import (
+	"sync"
+	"time"
+)
+
+// ADC is an analog input, it is notably implemented by machine.ADC
+type ADC interface {
+	Get() uint16
+}
+
+// Config for a battery Monitor
+type Config struct {
+	// Battery volts = pin volts * Divider, for example 2 for two equal resistors
+	Divider float64
+
+	// ADC reference voltage, 0 defaults to 3.3
+	VRef float64
+
+	// Calibration applied to the divided voltage: volts = measured * Scale + Offset.
+	// A Scale of 0 defaults to 1.
+	Scale  float64
+	Offset float64
+
+	// Exponential smoothing factor between 0 and 1, a smaller value smooths more.
+	// 0 defaults to 0.2.
+	Smoothing float64
+
+	// Minimum time between samples used to estimate the discharge rate, 0 defaults to 30 minutes
+	RateWindow time.Duration
+}
+
+// Reading is the state of the battery
+type Reading struct {
+	Volts   float64
+	Percent int
+
+	// Estimated time until the battery is empty, only valid when RuntimeKnown is true.
+	// The runtime is unknown until the battery has been seen discharging.
+	Runtime      time.Duration
+	RuntimeKnown bool
+}
+
+// Monitor reads and tracks the battery, Last can be called from another goroutine while Read runs
+type Monitor struct {
+	mu  sync.Mutex
+	adc ADC
+	cfg Config
+
+	volts   float64
+	started bool
+
+	rateFrom   float64   // percent at the start of the rate window
+	rateFromAt time.Time // start of the rate window
+	rate       float64   // smoothed discharge rate in percent per hour
+	rateKnown  bool
+
+	last Reading
+}
+
+// New creates a battery monitor
+func New(adc ADC, cfg Config) *Monitor {
+
+	if cfg.Divider == 0 {
+		cfg.Divider = 1
+	}
+	if cfg.VRef == 0 {
+		cfg.VRef = 3.3
+	}
+	if cfg.Scale == 0 {
+		cfg.Scale = 1
+	}
+	if cfg.Smoothing == 0 {
+		cfg.Smoothing = 0.2
+	}
+	if cfg.RateWindow == 0 {
+		cfg.RateWindow = time.Minute * 30
+	}
+
+	return &Monitor{adc: adc, cfg: cfg}
+}
+
+// Volts returns the calibrated battery voltage of a single ADC sample
+func (m *Monitor) Volts() float64 {
+	pin := float64(m.adc.Get()) / 65535 * m.cfg.VRef
+	return pin*m.cfg.Divider*m.cfg.Scale + m.cfg.Offset
+}
+
+// Read samples the battery and returns the smoothed state
+func (m *Monitor) Read(now time.Time) Reading {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	v := m.Volts()
+	if !m.started {
+		m.volts = v
+		m.started = true
+		m.rateFrom = PercentFloat(v)
+		m.rateFromAt = now
+	} else {
+		m.volts += m.cfg.Smoothing * (v - m.volts)
+	}
+
+	percent := PercentFloat(m.volts)
+
+	//
+	// Discharge rate, only measured over a long enough window to get past the noise
+	//
+	if elapsed := now.Sub(m.rateFromAt); elapsed >= m.cfg.RateWindow {
+		rate := (m.rateFrom - percent) / elapsed.Hours()
+
+		if rate > 0 {
+			if m.rateKnown {
+				m.rate += m.cfg.Smoothing * (rate - m.rate)
+			} else {
+				m.rate = rate
+				m.rateKnown = true
+			}
+		} else {
+			// Charging or holding steady, we can't say when it will run out
+			m.rateKnown = false
+		}
+
+		m.rateFrom = percent
+		m.rateFromAt = now
+	}
+
+	reading := Reading{
+		Volts:   m.volts,
+		Percent: int(percent + 0.5),
+	}
+
+	if m.rateKnown {
+		reading.Runtime = time.Duration(percent / m.rate * float64(time.Hour))
+		reading.RuntimeKnown = true
+	}
+
+	m.last = reading
+	return reading
+}
+
+// Last returns the result of the last Read
+func (m *Monitor) Last() Reading {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.last
+}
+
+// Typical resting voltage of a Li-ion cell at each percent of charge
+var curve = []struct {
+	volts   float64
+	percent float64
+}{
+	{3.27, 0},
+	{3.61, 5},
+	{3.69, 10},
+	{3.71, 15},
+	{3.73, 20},
+	{3.75, 25},
+	{3.77, 30},
+	{3.79, 35},
+	{3.80, 40},
+	{3.82, 45},
+	{3.84, 50},
+	{3.85, 55},
+	{3.87, 60},
+	{3.91, 65},
+	{3.95, 70},
+	{3.98, 75},
+	{4.02, 80},
+	{4.08, 85},
+	{4.11, 90},
+	{4.15, 95},
+	{4.20, 100},
+}
+
+// Percent returns the state of charge for a Li-ion cell voltage
+func Percent(volts float64) int {
+	return int(PercentFloat(volts) + 0.5)
+}
+
+// PercentFloat returns the state of charge for a Li-ion cell voltage, interpolated along the discharge curve
+func PercentFloat(volts float64) float64 {
+
+	if volts <= curve[0].volts {
+		return 0
+	}
+
+	for i := 1; i < len(curve); i++ {
+		if volts <= curve[i].volts {
+			lo, hi := curve[i-1], curve[i]
+			return lo.percent + (volts-lo.volts)/(hi.volts-lo.volts)*(hi.percent-lo.percent)
+		}
+	}
+
+	return 100
+}
@@ -0,0 +1,116 @@
+package battery
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// adc returns the raw value for a battery voltage through a divider of 2 on a 3.3v reference
+type adc struct {
+	volts float64
+}
+
+func (a *adc) Get() uint16 {
+	return uint16(a.volts / 2 / 3.3 * 65535)
+}
+
+func TestPercent(t *testing.T) {
+
+	tests := []struct {
+		volts float64
+		want  int
+	}{
+		{volts: 2.9, want: 0},
+		{volts: 3.27, want: 0},
+		{volts: 3.84, want: 50},
+		{volts: 3.89, want: 63},
+		{volts: 4.20, want: 100},
+		{volts: 4.35, want: 100},
+	}
+
+	for _, tt := range tests {
+		if got := Percent(tt.volts); got != tt.want {
+			t.Errorf("Percent(%v) = %v, want %v", tt.volts, got, tt.want)
+		}
+	}
+}
+
+func TestVoltsThroughDivider(t *testing.T) {
+
+	m := New(&adc{volts: 3.9}, Config{Divider: 2, Scale: 1, Offset: 0.05})
+	if v := m.Volts(); math.Abs(v-3.95) > 0.001 {
+		t.Errorf("Volts = %v, want 3.95 from 3.9 plus the 0.05 offset", v)
+	}
+}
+
+func TestSmoothing(t *testing.T) {
+
+	now := time.Unix(0, 0)
+	a := &adc{volts: 4.0}
+	m := New(a, Config{Divider: 2, Smoothing: 0.5})
+
+	if r := m.Read(now); math.Abs(r.Volts-4.0) > 0.001 {
+		t.Fatalf("first read = %v, want the sample as is", r.Volts)
+	}
+
+	// A single low sample only moves the reading part way
+	a.volts = 3.8
+	r := m.Read(now.Add(time.Minute))
+	if math.Abs(r.Volts-3.9) > 0.001 {
+		t.Errorf("smoothed = %v, want 3.9", r.Volts)
+	}
+	if m.Last() != r {
+		t.Errorf("Last = %+v, want the last reading %+v", m.Last(), r)
+	}
+}
+
+func TestRuntime(t *testing.T) {
+
+	now := time.Unix(0, 0)
+	a := &adc{volts: 3.84}
+	m := New(a, Config{Divider: 2, Smoothing: 1, RateWindow: time.Hour})
+
+	if r := m.Read(now); r.RuntimeKnown {
+		t.Fatal("runtime should be unknown before the battery is seen discharging")
+	}
+
+	// 50% to 45% in an hour leaves 9 hours at 5% an hour
+	a.volts = 3.82
+	r := m.Read(now.Add(time.Hour))
+	if !r.RuntimeKnown {
+		t.Fatal("runtime should be known once discharging")
+	}
+	if math.Abs(r.Runtime.Hours()-9) > 0.2 {
+		t.Errorf("runtime = %v, want about 9h", r.Runtime)
+	}
+
+	// Charging makes the runtime unknown again
+	a.volts = 3.95
+	if r := m.Read(now.Add(time.Hour * 2)); r.RuntimeKnown {
+		t.Error("runtime should be unknown while charging")
+	}
+}
+
+func TestLastWhileReading(t *testing.T) {
+
+	m := New(&adc{volts: 3.84}, Config{Divider: 2})
+	start := time.Unix(0, 0)
+
+	// The display goroutine shows Last while the main loop reads
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			m.Last()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		m.Read(start.Add(time.Minute * time.Duration(i)))
+	}
+	<-done
+
+	if last := m.Last(); last.Percent != 50 {
+		t.Errorf("Last().Percent = %v, want 50", last.Percent)
+	}
+}
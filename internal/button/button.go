@@ -0,0 +1,318 @@
+// Package button turns the edges of a push button into press events.
+//
+// A Button does not touch any hardware, it is fed the level after each edge along with
+// the time of the edge, and Tick is called when Next says a timed event may be due.
+// The level is debounced by an input.Debouncer, the Button adds the long press, double press
+// and repeat on top of its open and close events.
+// Run wires it to a pin read function and an edge channel, typically fed from a pin interrupt.
+package button
+
+import (
+	"time"
+
+	"github.com/tonygilkerson/mbx-iot/internal/input"
+)
+
+// EventKind is the type of button event
+type EventKind int
+
+// Press
+// The button was pressed and released. If double press is enabled it is sent once the
+// double press time has passed without a second press.
+//
+// LongPress
+// The button has been held for the long press time, it is sent while the button is still held
+// and Release is sent when it is released
+//
+// DoublePress
+// The button was pressed and released twice within the double press time
+//
+// Repeat
+// The button is being held and repeat is enabled, it is sent after the repeat delay and then
+// every repeat interval until the button is released, then Release is sent.
+//
+// Release
+// The button was released after a LongPress or Repeat, use it to finish what the hold started
+const (
+	Press EventKind = iota
+	LongPress
+	DoublePress
+	Repeat
+	Release
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case Press:
+		return "Press"
+	case LongPress:
+		return "LongPress"
+	case DoublePress:
+		return "DoublePress"
+	case Repeat:
+		return "Repeat"
+	case Release:
+		return "Release"
+	default:
+		return "Unknown"
+	}
+}
+
+// Event is a button event, At is when the button went down for Press and DoublePress,
+// when the event was raised for LongPress and Repeat and when the button went up for Release
+type Event struct {
+	Button string
+	Kind   EventKind
+	At     time.Time
+
+	// Count is the number of the repeat, starting at 1
+	Count int
+}
+
+// Config for a Button
+type Config struct {
+	// The level must be stable for this long before a change is accepted, 0 defaults to 20ms
+	Debounce time.Duration
+
+	// Send LongPress once the button is held this long, 0 disables
+	LongPress time.Duration
+
+	// A second press within this long of the first release is a DoublePress, 0 disables.
+	// Enabling it delays Press by this long.
+	DoublePress time.Duration
+
+	// While held send Repeat after RepeatDelay and then every RepeatEvery, 0 RepeatEvery disables.
+	// Repeat takes the place of LongPress so they should not both be set.
+	RepeatDelay time.Duration
+	RepeatEvery time.Duration
+}
+
+// Button tracks the state of one push button. It is not safe for concurrent use, Run owns it.
+type Button struct {
+	name string
+	cfg  Config
+
+	// raw is the level last fed, debouncer accepts it once it has been stable for the debounce time
+	raw       bool
+	debouncer *input.Debouncer
+
+	downAt   time.Time
+	longSent bool
+	repeats  int
+
+	// A first press waiting to see if it becomes a double press
+	pending    bool
+	second     bool
+	firstAt    time.Time
+	releasedAt time.Time
+}
+
+// New creates a button, the button is assumed to be up as of now
+func New(name string, cfg Config, now time.Time) *Button {
+
+	if cfg.Debounce == 0 {
+		cfg.Debounce = time.Millisecond * 20
+	}
+
+	return &Button{
+		name:      name,
+		cfg:       cfg,
+		debouncer: input.New(input.Config{Debounce: cfg.Debounce}, now),
+	}
+}
+
+// Name returns the name the events are sent with
+func (b *Button) Name() string {
+	return b.name
+}
+
+// IsDown returns the debounced state
+func (b *Button) IsDown() bool {
+	return b.debouncer.IsOpen()
+}
+
+// Edge feeds the button the level after an edge, pressed is true if the button is down
+func (b *Button) Edge(pressed bool, at time.Time) []Event {
+
+	b.raw = pressed
+	return b.Tick(at)
+}
+
+// Tick returns the events that are due as of now
+func (b *Button) Tick(now time.Time) []Event {
+
+	var events []Event
+
+	for _, e := range b.debouncer.Update(b.raw, now) {
+		switch e.Kind {
+		case input.Opened:
+			events = append(events, b.pressed(e.At)...)
+		case input.Closed:
+			events = append(events, b.released(e.At)...)
+		}
+	}
+
+	// A hold is not reported while a release is settling
+	holding := b.debouncer.IsOpen() && b.raw
+
+	switch {
+	case holding && b.cfg.RepeatEvery > 0:
+		if due := b.nextRepeat(); !now.Before(due) {
+			events = append(events, b.held()...)
+			b.repeats++
+			events = append(events, b.event(Repeat, now))
+
+			// Skip the repeats that were missed
+			for !now.Before(b.nextRepeat()) {
+				b.repeats++
+			}
+		}
+
+	case holding && b.cfg.LongPress > 0 && !b.longSent:
+		if now.Sub(b.downAt) >= b.cfg.LongPress {
+			events = append(events, b.held()...)
+			b.longSent = true
+			events = append(events, b.event(LongPress, now))
+		}
+
+	case !b.debouncer.IsOpen() && b.pending && now.Sub(b.releasedAt) >= b.cfg.DoublePress:
+		b.pending = false
+		events = append(events, b.event(Press, b.firstAt))
+	}
+
+	return events
+}
+
+// Next returns when Tick next needs to be called, ok is false if nothing is waiting on the time
+func (b *Button) Next() (next time.Time, ok bool) {
+
+	earliest := func(t time.Time) {
+		if !ok || t.Before(next) {
+			next = t
+			ok = true
+		}
+	}
+
+	if at, ok := b.debouncer.SettleAt(); ok {
+		earliest(at)
+	}
+
+	// The same hold as Tick, a hold is not waited on while a release is settling
+	holding := b.debouncer.IsOpen() && b.raw
+
+	switch {
+	case holding && b.cfg.RepeatEvery > 0:
+		earliest(b.nextRepeat())
+	case holding && b.cfg.LongPress > 0 && !b.longSent:
+		earliest(b.downAt.Add(b.cfg.LongPress))
+	case !b.debouncer.IsOpen() && b.pending:
+		earliest(b.releasedAt.Add(b.cfg.DoublePress))
+	}
+
+	return next, ok
+}
+
+// pressed is the debounced button going down, a first press that was waiting too long for a
+// double press is reported
+func (b *Button) pressed(at time.Time) []Event {
+
+	var events []Event
+	if b.pending && at.Sub(b.releasedAt) >= b.cfg.DoublePress {
+		b.pending = false
+		events = append(events, b.event(Press, b.firstAt))
+	}
+
+	b.downAt = at
+	b.longSent = false
+	b.repeats = 0
+	b.second = b.pending
+
+	return events
+}
+
+// released is the debounced button going up
+func (b *Button) released(at time.Time) []Event {
+
+	switch {
+	case b.longSent || b.repeats > 0:
+		// The hold was already reported
+		return []Event{b.event(Release, at)}
+
+	case b.second:
+		b.pending = false
+		b.second = false
+		return []Event{b.event(DoublePress, b.firstAt)}
+
+	case b.cfg.DoublePress > 0:
+		b.pending = true
+		b.firstAt = b.downAt
+		b.releasedAt = at
+		return nil
+
+	default:
+		return []Event{b.event(Press, b.downAt)}
+	}
+
+}
+
+// held reports a first press that was waiting for a double press when the second press turns into a hold
+func (b *Button) held() []Event {
+
+	if !b.second {
+		return nil
+	}
+
+	b.pending = false
+	b.second = false
+	return []Event{b.event(Press, b.firstAt)}
+}
+
+// nextRepeat returns when the next repeat is due
+func (b *Button) nextRepeat() time.Time {
+	return b.downAt.Add(b.cfg.RepeatDelay + time.Duration(b.repeats)*b.cfg.RepeatEvery)
+}
+
+func (b *Button) event(kind EventKind, at time.Time) Event {
+	return Event{Button: b.name, Kind: kind, At: at, Count: b.repeats}
+}
+
+// Run feeds the button each time an edge time arrives on the edges channel, typically sent from
+// a pin interrupt, and ticks it when a timed event may be due. The level is read after each edge,
+// it returns true while the button is down. Events are sent to the events channel.
+// Run does not return.
+func (b *Button) Run(level func() bool, edges <-chan time.Time, events chan<- Event) {
+
+	// One timer is set again for each wait rather than allocating a new one every loop
+	timer := time.NewTimer(time.Hour)
+
+	for {
+
+		// Stop the timer and empty its channel before it is set again
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+
+		var wait <-chan time.Time
+		if next, ok := b.Next(); ok {
+			timer.Reset(time.Until(next))
+			wait = timer.C
+		}
+
+		var found []Event
+		select {
+		case at := <-edges:
+			found = b.Edge(level(), at)
+		case <-wait:
+			// Sample the level in case an edge was dropped
+			found = b.Edge(level(), time.Now())
+		}
+
+		for _, e := range found {
+			events <- e
+		}
+	}
+
+}
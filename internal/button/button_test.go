@@ -0,0 +1,223 @@
+package button
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+const ms = time.Millisecond
+
+var t0 = time.Unix(1_700_000_000, 0)
+
+// at is a time after t0
+func at(d time.Duration) time.Time {
+	return t0.Add(d)
+}
+
+func kinds(events []Event) []EventKind {
+	var k []EventKind
+	for _, e := range events {
+		k = append(k, e.Kind)
+	}
+	return k
+}
+
+func wantKinds(t *testing.T, what string, events []Event, want ...EventKind) {
+	t.Helper()
+
+	got := kinds(events)
+	if len(got) != len(want) {
+		t.Fatalf("%v: events %v, want %v", what, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("%v: events %v, want %v", what, got, want)
+		}
+	}
+}
+
+func TestPress(t *testing.T) {
+
+	b := New("b", Config{Debounce: 30 * ms}, t0)
+
+	wantKinds(t, "down", b.Edge(true, at(100*ms)))
+	wantKinds(t, "settled", b.Tick(at(130*ms)))
+	if !b.IsDown() {
+		t.Fatal("IsDown = false after the debounce")
+	}
+
+	wantKinds(t, "up", b.Edge(false, at(300*ms)))
+	events := b.Tick(at(330 * ms))
+	wantKinds(t, "released", events, Press)
+	if events[0].Button != "b" || !events[0].At.Equal(at(100*ms)) {
+		t.Errorf("Press = %+v, want button b at the press", events[0])
+	}
+}
+
+func TestBounce(t *testing.T) {
+
+	b := New("b", Config{Debounce: 30 * ms}, t0)
+
+	// Contact bounce on the way down and up is a single press
+	b.Edge(true, at(100*ms))
+	b.Edge(false, at(105*ms))
+	b.Edge(true, at(110*ms))
+	wantKinds(t, "bouncing", b.Tick(at(135*ms)))
+	wantKinds(t, "settled", b.Tick(at(140*ms)))
+
+	b.Edge(false, at(300*ms))
+	b.Edge(true, at(302*ms))
+	b.Edge(false, at(304*ms))
+	wantKinds(t, "bouncing up", b.Tick(at(330*ms)))
+	events := b.Tick(at(334 * ms))
+	wantKinds(t, "released", events, Press)
+	if !events[0].At.Equal(at(110 * ms)) {
+		t.Errorf("Press at %v, want the last edge down", events[0].At.Sub(t0))
+	}
+
+	// A glitch shorter than the debounce is not a press
+	b.Edge(true, at(500*ms))
+	b.Edge(false, at(510*ms))
+	wantKinds(t, "glitch", b.Tick(at(600*ms)))
+}
+
+func TestLongPress(t *testing.T) {
+
+	b := New("b", Config{Debounce: 30 * ms, LongPress: 1500 * ms}, t0)
+
+	b.Edge(true, at(0))
+	wantKinds(t, "held", b.Tick(at(1000*ms)))
+	if next, ok := b.Next(); !ok || !next.Equal(at(1500*ms)) {
+		t.Errorf("Next = %v %v, want the long press time", next.Sub(t0), ok)
+	}
+
+	wantKinds(t, "long", b.Tick(at(1500*ms)), LongPress)
+	wantKinds(t, "still held", b.Tick(at(3000*ms)))
+
+	b.Edge(false, at(3100*ms))
+	events := b.Tick(at(3130 * ms))
+	wantKinds(t, "released", events, Release)
+	if !events[0].At.Equal(at(3100 * ms)) {
+		t.Errorf("Release at %v, want the release", events[0].At.Sub(t0))
+	}
+}
+
+func TestDoublePress(t *testing.T) {
+
+	b := New("b", Config{Debounce: 30 * ms, DoublePress: 300 * ms}, t0)
+
+	// Two presses close together
+	b.Edge(true, at(0))
+	b.Tick(at(30 * ms))
+	b.Edge(false, at(100*ms))
+	wantKinds(t, "first up", b.Tick(at(130*ms)))
+	b.Edge(true, at(250*ms))
+	b.Tick(at(280 * ms))
+	b.Edge(false, at(350*ms))
+	events := b.Tick(at(380 * ms))
+	wantKinds(t, "second up", events, DoublePress)
+	if !events[0].At.Equal(at(0)) {
+		t.Errorf("DoublePress at %v, want the first press", events[0].At.Sub(t0))
+	}
+
+	// A single press waits out the double press time
+	b.Edge(true, at(1000*ms))
+	b.Tick(at(1030 * ms))
+	b.Edge(false, at(1100*ms))
+	wantKinds(t, "waiting", b.Tick(at(1300*ms)))
+	if next, ok := b.Next(); !ok || !next.Equal(at(1400*ms)) {
+		t.Errorf("Next = %v %v, want the end of the double press time", next.Sub(t0), ok)
+	}
+	wantKinds(t, "single", b.Tick(at(1400*ms)), Press)
+}
+
+func TestRepeatSkipsMissedSlots(t *testing.T) {
+
+	b := New("b", Config{Debounce: 30 * ms, RepeatDelay: time.Second, RepeatEvery: 700 * ms}, t0)
+
+	b.Edge(true, at(0))
+	b.Tick(at(30 * ms))
+
+	events := b.Tick(at(time.Second))
+	wantKinds(t, "first", events, Repeat)
+	if events[0].Count != 1 {
+		t.Errorf("first Count = %v, want 1", events[0].Count)
+	}
+
+	// Ticked late, the slots at 2.4s and 3.1s are skipped rather than sent in a burst
+	events = b.Tick(at(3200 * ms))
+	wantKinds(t, "late", events, Repeat)
+	if events[0].Count != 2 {
+		t.Errorf("late Count = %v, want 2", events[0].Count)
+	}
+	if next, ok := b.Next(); !ok || !next.Equal(at(3800*ms)) {
+		t.Errorf("Next = %v %v, want 3.8s", next.Sub(t0), ok)
+	}
+
+	b.Edge(false, at(3500*ms))
+	wantKinds(t, "released", b.Tick(at(3530*ms)), Release)
+	if _, ok := b.Next(); ok {
+		t.Error("Next ok after the release, want nothing waiting")
+	}
+}
+
+func TestNextWhileReleaseSettles(t *testing.T) {
+
+	b := New("b", Config{Debounce: 30 * ms, RepeatDelay: time.Second, RepeatEvery: 700 * ms}, t0)
+
+	b.Edge(true, at(0))
+	b.Tick(at(30 * ms))
+
+	// Released just before the first repeat, the repeat is not due while the release settles
+	b.Edge(false, at(990*ms))
+	next, ok := b.Next()
+	if !ok || !next.Equal(at(1020*ms)) {
+		t.Fatalf("Next = %v %v, want the end of the debounce", next.Sub(t0), ok)
+	}
+	wantKinds(t, "settling", b.Tick(at(1000*ms)))
+	wantKinds(t, "released", b.Tick(next), Press)
+}
+
+func TestRun(t *testing.T) {
+
+	b := New("b", Config{Debounce: 5 * ms, DoublePress: 20 * ms}, time.Now())
+
+	var mu sync.Mutex
+	pressed := false
+	level := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return pressed
+	}
+	set := func(p bool) {
+		mu.Lock()
+		pressed = p
+		mu.Unlock()
+	}
+
+	edges := make(chan time.Time, 1)
+	events := make(chan Event, 4)
+	go b.Run(level, edges, events)
+
+	// Two presses, the timer waits out the debounce and then the double press time
+	for i := 0; i < 2; i++ {
+		set(true)
+		edges <- time.Now()
+		time.Sleep(10 * ms)
+		set(false)
+		edges <- time.Now()
+		time.Sleep(40 * ms)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case e := <-events:
+			if e.Kind != Press {
+				t.Fatalf("event %v = %v, want Press", i, e.Kind)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no event %v from Run", i)
+		}
+	}
+}
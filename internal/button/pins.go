@@ -0,0 +1,27 @@
+//go:build tinygo
+
+package button
+
+import (
+	"machine"
+	"time"
+)
+
+// Watch runs the button on an active low pin with a pull-up, it starts a goroutine that sends
+// the button events to the events channel
+func Watch(pin machine.Pin, b *Button, events chan<- Event) {
+
+	edges := make(chan time.Time, 8)
+
+	pin.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
+	pin.SetInterrupt(machine.PinToggle, func(p machine.Pin) {
+		// Use non-blocking send so if the channel buffer is full,
+		// the value will get dropped instead of crashing the system
+		select {
+		case edges <- time.Now():
+		default:
+		}
+	})
+
+	go b.Run(func() bool { return !pin.Get() }, edges, events)
+}
@@ -0,0 +1,159 @@
+// Package charger models the status pins of a bq24074 solar charger as a state machine.
+//
+// CHG - Charge status (active low) is pulled to GND while the battery is charging.
+// PGOOD - Power good (active low) is pulled to GND while a valid input source is connected.
+//
+// The charger flashes CHG when it hits a fault, for example the safety timer expired or the
+// battery is too hot or cold, so a CHG pin that keeps toggling is reported as a fault.
+package charger
+
+import (
+	"sync"
+	"time"
+)
+
+// State of the charger
+type State string
+
+const (
+	Unknown  State = "unknown"
+	Charging State = "charging"
+	Charged  State = "charged"
+	NoSource State = "no-source"
+	Fault    State = "fault"
+)
+
+const (
+	// CHG toggling this many times inside the blink window is a fault
+	faultToggles = 3
+	blinkWindow  = time.Second * 5
+
+	day = time.Hour * 24
+)
+
+// Harvest is a summary of the solar charging over one day
+type Harvest struct {
+	Charging time.Duration
+	Start    time.Time
+}
+
+// Tracker follows the charger state.
+// Feed it on every edge of the CHG pin so a blink is never missed, the state is guarded by mu
+// so the goroutine watching the pins and the main loop can both use it.
+type Tracker struct {
+	mu    sync.Mutex
+	state State
+	at    time.Time
+
+	lastChg bool
+	toggles []time.Time
+	sampled bool
+
+	dayStart time.Time
+	charging time.Duration
+}
+
+// New creates a tracker, the first Update sets the state
+func New(now time.Time) *Tracker {
+	return &Tracker{
+		state:    Unknown,
+		at:       now,
+		dayStart: now,
+	}
+}
+
+// State returns the current state
+func (t *Tracker) State() State {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.state
+}
+
+// Update feeds the tracker a sample of the raw pin levels, remember both pins are active low.
+// It returns the state and whether it changed.
+func (t *Tracker) Update(chgPin bool, pgoodPin bool, now time.Time) (state State, changed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Count time spent charging since the last sample
+	if t.state == Charging {
+		t.charging += now.Sub(t.at)
+	}
+	t.at = now
+
+	//
+	// Look for a blinking CHG pin
+	//
+	if t.sampled && chgPin != t.lastChg {
+		t.toggles = append(t.toggles, now)
+	}
+	t.lastChg = chgPin
+	t.sampled = true
+
+	var recent []time.Time
+	for _, toggle := range t.toggles {
+		if now.Sub(toggle) <= blinkWindow {
+			recent = append(recent, toggle)
+		}
+	}
+	t.toggles = recent
+
+	charging := !chgPin
+	powerGood := !pgoodPin
+
+	switch {
+	case len(t.toggles) >= faultToggles:
+		state = Fault
+	case charging && !powerGood:
+		// Can't be charging without a source
+		state = Fault
+	case charging:
+		state = Charging
+	case powerGood:
+		state = Charged
+	default:
+		state = NoSource
+	}
+
+	changed = state != t.state
+	t.state = state
+
+	return state, changed
+}
+
+// RecheckAt returns when the oldest CHG toggle leaves the blink window, call Update again then so a
+// fault clears once the pin stops blinking. ok is false if there are no recent toggles.
+func (t *Tracker) RecheckAt() (at time.Time, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.toggles) == 0 {
+		return at, false
+	}
+
+	return t.toggles[0].Add(blinkWindow + time.Millisecond), true
+}
+
+// DailyHarvest returns the charging summary once a full day has passed since the last summary, ok is false until then
+func (t *Tracker) DailyHarvest(now time.Time) (harvest Harvest, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now.Sub(t.dayStart) < day {
+		return harvest, false
+	}
+
+	// Include the time charging since the last sample
+	charging := t.charging
+	if t.state == Charging {
+		charging += now.Sub(t.at)
+		t.at = now
+	}
+
+	harvest = Harvest{Charging: charging, Start: t.dayStart}
+	t.charging = 0
+	t.dayStart = now
+
+	return harvest, true
+}
@@ -0,0 +1,62 @@
+package charger
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBlinkingChgIsAFaultUntilItStops(t *testing.T) {
+
+	start := time.Unix(0, 0)
+	c := New(start)
+
+	// Charging with a good source, the pins are active low
+	if state, _ := c.Update(false, false, start); state != Charging {
+		t.Fatalf("state = %v, want %v", state, Charging)
+	}
+	if _, ok := c.RecheckAt(); ok {
+		t.Fatal("no toggles so nothing to recheck")
+	}
+
+	// The charger blinks CHG at 1Hz, the edges are fed as they happen
+	chg := false
+	at := start
+	var state State
+	for i := 0; i < 5; i++ {
+		at = at.Add(time.Millisecond * 500)
+		chg = !chg
+		state, _ = c.Update(chg, false, at)
+	}
+	if state != Fault {
+		t.Fatalf("state = %v after 5 toggles, want %v", state, Fault)
+	}
+
+	// The blinking stops with CHG off, the fault clears once the toggles leave the window
+	recheck, ok := c.RecheckAt()
+	for ok {
+		state, _ = c.Update(chg, false, recheck)
+		recheck, ok = c.RecheckAt()
+	}
+	if state != Charged {
+		t.Errorf("state = %v after the blinking stopped, want %v", state, Charged)
+	}
+}
+
+func TestDailyHarvest(t *testing.T) {
+
+	start := time.Unix(0, 0)
+	c := New(start)
+
+	c.Update(true, false, start)
+	c.Update(false, false, start.Add(time.Hour*8))
+	c.Update(true, false, start.Add(time.Hour*11))
+
+	if _, ok := c.DailyHarvest(start.Add(time.Hour * 23)); ok {
+		t.Fatal("no summary before a day has passed")
+	}
+
+	harvest, ok := c.DailyHarvest(start.Add(time.Hour * 24))
+	if !ok || harvest.Charging != time.Hour*3 {
+		t.Errorf("harvest = %v, %v, want 3h", harvest.Charging, ok)
+	}
+}
@@ -0,0 +1,87 @@
+// Package clock is the time source used by the nodes.
+//
+// Code that makes decisions based on time should read it through a Clock
+// so the logic can be run on the host with a Fake clock.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time and timers
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// System is the clock provided by the runtime
+type System struct{}
+
+func (System) Now() time.Time {
+	return time.Now()
+}
+
+func (System) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+type waiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// Fake is a clock that only moves when told to, it is used to test time based logic on the host
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+}
+
+// NewFake creates a fake clock set to now
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+
+	f.waiters = append(f.waiters, waiter{at: f.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward and fires any timers that are now due
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+
+	var pending []waiter
+	for _, w := range f.waiters {
+		if w.at.After(f.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- f.now
+	}
+	f.waiters = pending
+}
+
+// Set moves the clock to t and fires any timers that are now due
+func (f *Fake) Set(t time.Time) {
+	f.Advance(t.Sub(f.Now()))
+}
@@ -0,0 +1,203 @@
+package clock
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var errIO = errors.New("i2c")
+
+// rtc is a fake DS3231, err fails both the read and the write
+type rtc struct {
+	now   time.Time
+	valid bool
+	err   error
+
+	set []time.Time
+}
+
+func (r *rtc) ReadTime() (time.Time, error) {
+	return r.now, r.err
+}
+
+func (r *rtc) IsTimeValid() bool {
+	return r.valid
+}
+
+func (r *rtc) SetTime(t time.Time) error {
+	r.set = append(r.set, t)
+	return r.err
+}
+
+func TestFakeAfter(t *testing.T) {
+
+	f := NewFake(time.Unix(0, 0))
+	soon := f.After(time.Second)
+	later := f.After(time.Minute)
+
+	f.Advance(time.Second)
+	select {
+	case <-soon:
+	default:
+		t.Error("the 1s timer did not fire")
+	}
+	select {
+	case <-later:
+		t.Error("the 1m timer fired early")
+	default:
+	}
+
+	f.Set(time.Unix(60, 0))
+	if got := <-later; !got.Equal(time.Unix(60, 0)) {
+		t.Errorf("the 1m timer fired at %v", got)
+	}
+}
+
+func TestSyncedSetFromMessage(t *testing.T) {
+
+	boot := time.Unix(0, 0)
+	gateway := time.Unix(1_700_000_000, 0)
+
+	tests := []struct {
+		name     string
+		value    string
+		rtcErr   error
+		wantErr  bool
+		wantSet  bool
+		wantJump time.Duration
+		wantRTC  bool
+	}{
+		{name: "set", value: "1700000000", wantSet: true, wantJump: gateway.Sub(boot.Add(time.Minute)), wantRTC: true},
+		{name: "not a number", value: "noon", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+		{name: "out of range", value: "99999999999999999999", wantErr: true},
+		{name: "rtc write fails", value: "1700000000", rtcErr: errIO, wantErr: true, wantSet: true, wantJump: gateway.Sub(boot.Add(time.Minute)), wantRTC: true},
+	}
+
+	for _, tt := range tests {
+		base := NewFake(boot)
+		s := NewSynced(base)
+		r := &rtc{err: tt.rtcErr}
+		base.Advance(time.Minute)
+
+		jump, err := s.SetFromMessage(tt.value, r)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: err = %v, want an error %v", tt.name, err, tt.wantErr)
+		}
+		if s.IsSet() != tt.wantSet {
+			t.Errorf("%v: IsSet = %v, want %v", tt.name, s.IsSet(), tt.wantSet)
+		}
+		if jump != tt.wantJump {
+			t.Errorf("%v: jump = %v, want %v", tt.name, jump, tt.wantJump)
+		}
+		if (len(r.set) == 1) != tt.wantRTC {
+			t.Errorf("%v: rtc set %v, want written %v", tt.name, r.set, tt.wantRTC)
+		}
+		if !tt.wantSet && !s.Now().Equal(base.Now()) {
+			t.Errorf("%v: Now = %v, want the base clock", tt.name, s.Now())
+		}
+	}
+}
+
+func TestSyncedJump(t *testing.T) {
+
+	base := NewFake(time.Unix(0, 0))
+	s := NewSynced(base)
+
+	if _, ok := s.LastSync(); ok || s.IsSet() {
+		t.Fatal("a new clock should not be set")
+	}
+
+	// Set an hour after boot, times taken before the set move by the jump
+	base.Advance(time.Hour)
+	before := s.Now()
+	set := time.Unix(1_700_000_000, 0)
+	jump := s.Set(set)
+	if !before.Add(jump).Equal(set) {
+		t.Errorf("jump = %v, a time taken before the set plus the jump is %v, want %v", jump, before.Add(jump), set)
+	}
+
+	// The clock runs on from the base
+	base.Advance(time.Minute)
+	if !s.Now().Equal(set.Add(time.Minute)) {
+		t.Errorf("Now = %v, want a minute after the set", s.Now())
+	}
+	if last, ok := s.LastSync(); !ok || !last.Equal(set) {
+		t.Errorf("LastSync = %v %v, want %v", last, ok, set)
+	}
+
+	// A later set only jumps by the drift
+	if jump := s.Set(set.Add(time.Minute + time.Second*2)); jump != time.Second*2 {
+		t.Errorf("second jump = %v, want the 2s drift", jump)
+	}
+	if jump := s.Set(set.Add(time.Minute)); jump != -time.Second*2 {
+		t.Errorf("jump back = %v, want -2s", jump)
+	}
+
+	// Timers run on the base clock so a jump does not fire or stretch them
+	after := s.After(time.Second)
+	s.Set(set.Add(time.Hour))
+	select {
+	case <-after:
+		t.Error("the timer fired on the jump")
+	default:
+	}
+	base.Advance(time.Second)
+	select {
+	case <-after:
+	default:
+		t.Error("the timer did not fire after 1s of the base clock")
+	}
+}
+
+func TestSyncFrom(t *testing.T) {
+
+	now := time.Unix(1_700_000_000, 0)
+
+	tests := []struct {
+		name    string
+		rtc     rtc
+		wantErr error
+		wantSet bool
+	}{
+		{name: "valid", rtc: rtc{now: now, valid: true}, wantSet: true},
+		{name: "lost power", rtc: rtc{now: now}, wantErr: ErrRTCNotSet},
+		{name: "read fails", rtc: rtc{valid: true, err: errIO}, wantErr: errIO},
+	}
+
+	for _, tt := range tests {
+		s := NewSynced(NewFake(time.Unix(0, 0)))
+
+		err := s.SyncFrom(&tt.rtc)
+		if err != tt.wantErr {
+			t.Errorf("%v: err = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if s.IsSet() != tt.wantSet {
+			t.Errorf("%v: IsSet = %v, want %v", tt.name, s.IsSet(), tt.wantSet)
+		}
+		if tt.wantSet && !s.Now().Equal(now) {
+			t.Errorf("%v: Now = %v, want the rtc time %v", tt.name, s.Now(), now)
+		}
+	}
+}
+
+func TestUnixRoundTrip(t *testing.T) {
+
+	now := time.Unix(1_700_000_000, 999_000_000)
+	value := FormatUnix(now)
+	if value != "1700000000" {
+		t.Fatalf("FormatUnix = %q, want whole seconds", value)
+	}
+
+	got, err := ParseUnix(value)
+	if err != nil || !got.Equal(now.Truncate(time.Second)) {
+		t.Errorf("ParseUnix = %v %v, want %v", got, err, now.Truncate(time.Second))
+	}
+
+	for _, bad := range []string{"", "1.5", "-", "1700000000x"} {
+		if _, err := ParseUnix(bad); err == nil {
+			t.Errorf("ParseUnix(%q) err = nil, want an error", bad)
+		}
+	}
+}
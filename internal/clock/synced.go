@@ -0,0 +1,131 @@
+package clock
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RTC is a battery backed real time clock, it is notably implemented by the ds3231 driver
+type RTC interface {
+	ReadTime() (time.Time, error)
+	IsTimeValid() bool
+}
+
+// RTCWriter is an RTC that can be set, the ds3231 driver implements it too
+type RTCWriter interface {
+	SetTime(t time.Time) error
+}
+
+// ErrRTCNotSet is returned when the RTC lost power and does not know the time
+var ErrRTCNotSet = errors.New("clock: rtc time is not valid")
+
+// Synced is a clock that is set from a real time source.
+//
+// The pico has no battery backed clock so time.Now restarts at boot. Synced keeps an offset from
+// the base clock that is set from an RTC or a time broadcast by the gateway. Until it is set the
+// time is the base clock's and IsSet returns false, so anything that compares times across a
+// reboot must check IsSet first.
+type Synced struct {
+	mu       sync.Mutex
+	base     Clock
+	offset   time.Duration
+	set      bool
+	lastSync time.Time
+}
+
+// NewSynced creates a clock that runs from base until it is set
+func NewSynced(base Clock) *Synced {
+	return &Synced{base: base}
+}
+
+func (s *Synced) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.base.Now().Add(s.offset)
+}
+
+func (s *Synced) After(d time.Duration) <-chan time.Time {
+	return s.base.After(d)
+}
+
+// Set sets the current time and returns how far the clock jumped,
+// add the jump to any times taken before the set to keep their age
+func (s *Synced) Set(t time.Time) (jump time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	offset := t.Sub(s.base.Now())
+	jump = offset - s.offset
+	s.offset = offset
+	s.set = true
+	s.lastSync = t
+
+	return jump
+}
+
+// IsSet returns true once the clock has been set from a real time source
+func (s *Synced) IsSet() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.set
+}
+
+// LastSync returns the time the clock was last set, ok is false if it has not been set
+func (s *Synced) LastSync() (t time.Time, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lastSync, s.set
+}
+
+// SyncFrom sets the clock from an RTC
+func (s *Synced) SyncFrom(rtc RTC) error {
+
+	if !rtc.IsTimeValid() {
+		return ErrRTCNotSet
+	}
+
+	t, err := rtc.ReadTime()
+	if err != nil {
+		return err
+	}
+
+	s.Set(t)
+	return nil
+}
+
+// SetFromMessage sets the clock from the value of a GatewayTime message and,
+// if rtc is not nil, writes the time to the RTC so it is known after the next reboot
+func (s *Synced) SetFromMessage(value string, rtc RTCWriter) (jump time.Duration, err error) {
+
+	t, err := ParseUnix(value)
+	if err != nil {
+		return 0, err
+	}
+
+	jump = s.Set(t)
+
+	if rtc != nil {
+		err = rtc.SetTime(t)
+	}
+	return jump, err
+}
+
+// FormatUnix formats a time as unix seconds for a GatewayTime message
+func FormatUnix(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+// ParseUnix parses the unix seconds of a GatewayTime message
+func ParseUnix(value string) (time.Time, error) {
+
+	sec, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(sec, 0), nil
+}
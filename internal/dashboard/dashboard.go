@@ -0,0 +1,330 @@
+// Package dashboard keeps the status shown on the e-paper display.
+//
+// The dashboard is a list of tiles, each one bound to a pkg/iot key. A tile has a label,
+// a formatter, a staleness rule and a dirty rule that decides when a change needs someone's
+// attention. Adding a status to the display is a matter of adding a tile to the list.
+//
+// Update and Lines take the time from the caller so the dashboard can be run on the host.
+package dashboard
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Value is what a tile knows about its key, it is passed to the tile formatter
+type Value struct {
+	// Text is the last value received, it is the tile's Initial until something is received
+	Text     string
+	Received bool
+
+	// Changed is true if the value changed since the dashboard was last acknowledged
+	Changed bool
+
+	// Alert is true while the value needs attention, see Tile.Alert and Tile.AlertKeys
+	Alert bool
+
+	// Stale is true if nothing has been received for longer than the tile's StaleAfter
+	Stale bool
+
+	// Updated is when the value was last received
+	Updated time.Time
+
+	// Clean is the time since the dashboard was last acknowledged
+	Clean time.Duration
+}
+
+// Tile is one line of the dashboard
+type Tile struct {
+	// Label is shown before the value, a tile without a label shows only the formatted value
+	Label string
+
+	// Key is the pkg/iot key shown by the tile. A tile without a key never receives a value,
+	// use it with Format for separators and lines made from the dashboard itself.
+	Key string
+
+	// Initial is the text shown until a value is received, defaults to "-"
+	Initial string
+
+	// Format turns the value into the text shown, nil shows the value as is
+	Format func(v Value) string
+
+	// Sensors binds the tile to the per-sensor keys Key-<address> as well, for example SoilMoisture-0x37.
+	// Format is called with each sensor's value and they are shown on the one line, Key first.
+	Sensors bool
+
+	// Show " (stale)" after the value if nothing is received for this long, 0 never goes stale
+	StaleAfter time.Duration
+
+	// Merge folds other keys into the tile value, for example a node offline alert into the
+	// list of offline nodes. It is called with the current value and the message value.
+	Merge map[string]func(current string, value string) string
+
+	// Alert returns true if a value needs attention
+	Alert func(value string) bool
+
+	// AlertKeys are other keys that raise or clear the alert, for example a low battery alert
+	// for the battery percent tile. It is called with the message value.
+	AlertKeys map[string]func(value string) bool
+
+	// Dirty returns true if the change needs someone's attention, nil never marks the dashboard dirty
+	Dirty func(old Value, new Value) bool
+}
+
+// Dashboard is the state of all the tiles
+type Dashboard struct {
+	tiles  []Tile
+	values []Value
+
+	// sensors is the last value of each sensor of a Sensors tile, Key itself is the sensor ""
+	sensors []map[string]string
+
+	dirty     bool
+	lastClean time.Time
+}
+
+// New creates a dashboard, it is clean as of now
+func New(tiles []Tile, now time.Time) *Dashboard {
+
+	d := &Dashboard{
+		tiles:     tiles,
+		values:    make([]Value, len(tiles)),
+		sensors:   make([]map[string]string, len(tiles)),
+		lastClean: now,
+	}
+
+	for i, t := range tiles {
+		d.values[i].Text = t.Initial
+		if t.Initial == "" {
+			d.values[i].Text = "-"
+		}
+	}
+
+	return d
+}
+
+// Keys returns every key the tiles use, use it to decide which messages to pass on to the display
+func Keys(tiles []Tile) []string {
+
+	var keys []string
+	add := func(k string) {
+		for _, have := range keys {
+			if have == k {
+				return
+			}
+		}
+		keys = append(keys, k)
+	}
+
+	for _, t := range tiles {
+		if t.Key != "" {
+			add(t.Key)
+		}
+		for k := range t.Merge {
+			add(k)
+		}
+		for k := range t.AlertKeys {
+			add(k)
+		}
+	}
+
+	return keys
+}
+
+// Uses returns true if a tile uses the key, it is Keys plus the per-sensor keys of the Sensors tiles
+func Uses(tiles []Tile, key string) bool {
+
+	for _, k := range Keys(tiles) {
+		if k == key {
+			return true
+		}
+	}
+	for _, t := range tiles {
+		if _, ok := t.sensor(key); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sensor returns the sensor a key is for, ok is false if the key is not one of the tile's sensors
+func (t Tile) sensor(key string) (sensor string, ok bool) {
+
+	if !t.Sensors || t.Key == "" {
+		return "", false
+	}
+	if key == t.Key {
+		return "", true
+	}
+	if strings.HasPrefix(key, t.Key+"-") {
+		return strings.TrimPrefix(key, t.Key+"-"), true
+	}
+	return "", false
+}
+
+// Update feeds a message to the tiles bound to the key, it returns true if any tile used it
+func (d *Dashboard) Update(key string, value string, now time.Time) (used bool) {
+
+	for i, t := range d.tiles {
+
+		old := d.values[i]
+		v := old
+
+		sensor, isSensor := t.sensor(key)
+
+		switch {
+		case isSensor:
+			if d.sensors[i] == nil {
+				d.sensors[i] = make(map[string]string)
+			}
+			if d.sensors[i][sensor] != value {
+				v.Changed = true
+			}
+			d.sensors[i][sensor] = value
+			if sensor == "" {
+				v.Text = value
+				if t.Alert != nil {
+					v.Alert = t.Alert(value)
+				}
+			}
+		case t.Key != "" && key == t.Key:
+			v.Text = value
+			if t.Alert != nil {
+				v.Alert = t.Alert(value)
+			}
+		case t.Merge[key] != nil:
+			current := v.Text
+			if !v.Received {
+				current = ""
+			}
+			v.Text = t.Merge[key](current, value)
+		case t.AlertKeys[key] != nil:
+			// An alert on its own says nothing about the value
+			v.Alert = t.AlertKeys[key](value)
+			used = true
+			if t.Dirty != nil && t.Dirty(old, v) {
+				d.dirty = true
+			}
+			d.values[i] = v
+			continue
+		default:
+			continue
+		}
+
+		used = true
+		v.Received = true
+		v.Updated = now
+		if v.Text != old.Text {
+			v.Changed = true
+		}
+
+		if t.Dirty != nil && t.Dirty(old, v) {
+			d.dirty = true
+		}
+		d.values[i] = v
+	}
+
+	return used
+}
+
+// IsDirty returns true if a change needs someone's attention
+func (d *Dashboard) IsDirty() bool {
+	return d.dirty
+}
+
+// Ack acknowledges the changes, the dashboard is clean as of now
+func (d *Dashboard) Ack(now time.Time) {
+
+	d.dirty = false
+	d.lastClean = now
+	for i := range d.values {
+		d.values[i].Changed = false
+	}
+
+}
+
+// ClockJumped keeps the ages right when the clock is set
+func (d *Dashboard) ClockJumped(jump time.Duration) {
+
+	d.lastClean = d.lastClean.Add(jump)
+	for i := range d.values {
+		if d.values[i].Received {
+			d.values[i].Updated = d.values[i].Updated.Add(jump)
+		}
+	}
+
+}
+
+// Value returns what the tile at index i knows as of now
+func (d *Dashboard) Value(i int, now time.Time) Value {
+
+	v := d.values[i]
+	v.Clean = now.Sub(d.lastClean)
+	if t := d.tiles[i]; t.Key != "" && t.StaleAfter > 0 {
+		v.Stale = !v.Received || now.Sub(v.Updated) > t.StaleAfter
+	}
+
+	return v
+}
+
+// Lines returns a line of text for each tile
+func (d *Dashboard) Lines(now time.Time) []string {
+
+	lines := make([]string, len(d.tiles))
+	for i, t := range d.tiles {
+
+		v := d.Value(i, now)
+
+		text := format(t, v)
+		if len(d.sensors[i]) > 0 {
+			text = d.sensorText(i, v)
+		}
+		if v.Stale && v.Received {
+			text += " (stale)"
+		}
+
+		if t.Label != "" {
+			text = t.Label + ": " + text
+		}
+		lines[i] = text
+	}
+
+	return lines
+}
+
+// format turns a value into the text shown by the tile
+func format(t Tile, v Value) string {
+	if t.Format != nil {
+		return t.Format(v)
+	}
+	return v.Text
+}
+
+// sensorText is the value of each sensor of a Sensors tile, Key itself first and then by address
+func (d *Dashboard) sensorText(i int, v Value) string {
+
+	var sensors []string
+	for sensor := range d.sensors[i] {
+		sensors = append(sensors, sensor)
+	}
+	sort.Strings(sensors)
+
+	parts := make([]string, len(sensors))
+	for j, sensor := range sensors {
+		sv := v
+		sv.Text = d.sensors[i][sensor]
+		parts[j] = format(d.tiles[i], sv)
+		if sensor != "" {
+			parts[j] = sensor + " " + parts[j]
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// Text is the whole dashboard, one tile per line
+func (d *Dashboard) Text(now time.Time) string {
+	return strings.Join(d.Lines(now), "\n")
+}
@@ -0,0 +1,163 @@
+package dashboard
+
+import (
+	"testing"
+	"time"
+)
+
+var start = time.Unix(1_700_000_000, 0)
+
+func TestStale(t *testing.T) {
+
+	tiles := []Tile{
+		{Label: "HB", Key: "Heartbeat", StaleAfter: time.Minute * 5},
+		{Label: "Count", Key: "Count"},
+		{Format: func(v Value) string { return "---" }},
+	}
+	d := New(tiles, start)
+
+	// Nothing received yet is stale but shows the initial text rather than "(stale)"
+	if v := d.Value(0, start); !v.Stale || v.Received {
+		t.Errorf("before a value %+v, want stale and not received", v)
+	}
+	if got := d.Lines(start)[0]; got != "HB: -" {
+		t.Errorf("before a value %q", got)
+	}
+
+	d.Update("Heartbeat", "7", start)
+	d.Update("Count", "1", start)
+
+	tests := []struct {
+		after     time.Duration
+		wantStale bool
+		want      string
+	}{
+		{after: 0, want: "HB: 7"},
+		{after: time.Minute * 5, want: "HB: 7"},
+		{after: time.Minute*5 + time.Second, wantStale: true, want: "HB: 7 (stale)"},
+	}
+	for _, tt := range tests {
+		now := start.Add(tt.after)
+		if v := d.Value(0, now); v.Stale != tt.wantStale {
+			t.Errorf("at %v Stale = %v, want %v", tt.after, v.Stale, tt.wantStale)
+		}
+		if got := d.Lines(now)[0]; got != tt.want {
+			t.Errorf("at %v %q, want %q", tt.after, got, tt.want)
+		}
+	}
+
+	// Without StaleAfter or a key a tile never goes stale
+	later := start.Add(time.Hour * 24)
+	if d.Value(1, later).Stale || d.Value(2, later).Stale {
+		t.Error("a tile without StaleAfter or a key went stale")
+	}
+
+	// A clock jump keeps the age of the value
+	d.ClockJumped(time.Hour)
+	if v := d.Value(0, start.Add(time.Hour+time.Minute)); v.Stale {
+		t.Errorf("after the jump %+v, want a minute old and not stale", v)
+	}
+}
+
+func TestSensors(t *testing.T) {
+
+	tiles := []Tile{
+		{Label: "Soil", Key: "Moisture", Sensors: true, Format: func(v Value) string { return "[" + v.Text + "]" }},
+		{Label: "Raw", Key: "MoistureRaw"},
+	}
+	d := New(tiles, start)
+
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{key: "Moisture", want: true},
+		{key: "Moisture-0x37", want: true},
+		{key: "MoistureRaw", want: true},
+		{key: "MoistureRaw-0x37", want: false},
+		{key: "Moist", want: false},
+	}
+	for _, tt := range tests {
+		if got := Uses(tiles, tt.key); got != tt.want {
+			t.Errorf("Uses(%v) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+
+	// The probes are merged on the one line, the tile's own key first and then by address
+	d.Update("Moisture-0x38", "70", start)
+	d.Update("Moisture", "43", start)
+	d.Update("Moisture-0x37", "-1", start)
+	if got := d.Lines(start)[0]; got != "Soil: [43], 0x37 [-1], 0x38 [70]" {
+		t.Errorf("Soil = %q", got)
+	}
+
+	// The prefix of another key is not a sensor of the tile
+	if d.Update("MoistureRaw", "612", start); d.Lines(start)[0] != "Soil: [43], 0x37 [-1], 0x38 [70]" {
+		t.Errorf("Soil after MoistureRaw = %q", d.Lines(start)[0])
+	}
+
+	// Only a probe reading that changed marks the tile changed
+	d.Ack(start)
+	d.Update("Moisture-0x37", "-1", start.Add(time.Minute))
+	if d.Value(0, start).Changed {
+		t.Error("Changed after the same probe reading")
+	}
+	d.Update("Moisture-0x37", "12", start.Add(time.Minute))
+	if !d.Value(0, start).Changed {
+		t.Error("not Changed after a new probe reading")
+	}
+	if got := d.Value(0, start).Text; got != "43" {
+		t.Errorf("Text = %q, want the tile key's value", got)
+	}
+}
+
+func TestAlert(t *testing.T) {
+
+	tiles := []Tile{
+		{Label: "Mule", Key: "Mule", Alert: NotZero, Dirty: OnAlert},
+		{Label: "Battery", Key: "Battery", AlertKeys: map[string]func(string) bool{"BatteryLow": Always, "BatteryOk": Never}, Dirty: OnAlert},
+		{Label: "Offline", Key: "Offline", Merge: map[string]func(string, string) string{"NodeOffline": AddItem, "NodeOnline": RemoveItem}, Dirty: OnListGrow},
+	}
+	d := New(tiles, start)
+
+	tests := []struct {
+		name      string
+		key       string
+		value     string
+		tile      int
+		wantAlert bool
+		wantDirty bool
+	}{
+		{name: "no count", key: "Mule", value: "0", tile: 0},
+		{name: "count", key: "Mule", value: "1", tile: 0, wantAlert: true, wantDirty: true},
+		{name: "still counting", key: "Mule", value: "2", tile: 0, wantAlert: true},
+		{name: "battery", key: "Battery", value: "80", tile: 1},
+		{name: "low battery", key: "BatteryLow", value: "15", tile: 1, wantAlert: true, wantDirty: true},
+		{name: "reading while low", key: "Battery", value: "14", tile: 1, wantAlert: true},
+		{name: "battery ok", key: "BatteryOk", value: "40", tile: 1},
+		{name: "node offline", key: "NodeOffline", value: "soil", tile: 2, wantDirty: true},
+		{name: "node back online", key: "NodeOnline", value: "soil", tile: 2},
+	}
+
+	for _, tt := range tests {
+		d.Ack(start)
+
+		if !d.Update(tt.key, tt.value, start) {
+			t.Fatalf("%v: Update(%v) not used", tt.name, tt.key)
+		}
+		if v := d.Value(tt.tile, start); v.Alert != tt.wantAlert {
+			t.Errorf("%v: Alert = %v, want %v", tt.name, v.Alert, tt.wantAlert)
+		}
+		if d.IsDirty() != tt.wantDirty {
+			t.Errorf("%v: IsDirty = %v, want %v", tt.name, d.IsDirty(), tt.wantDirty)
+		}
+	}
+
+	// The alert key says nothing about the value
+	if got := d.Lines(start)[1]; got != "Battery: 14" {
+		t.Errorf("Battery = %q", got)
+	}
+	if d.Update("Unknown", "1", start) {
+		t.Error("Update of a key no tile uses returned true")
+	}
+}
@@ -0,0 +1,84 @@
+package dashboard
+
+import (
+	"strings"
+)
+
+// OnChange marks the dashboard dirty whenever the value changes
+func OnChange(old Value, new Value) bool {
+	return new.Text != old.Text
+}
+
+// OnAlert marks the dashboard dirty when the tile goes into alert
+func OnAlert(old Value, new Value) bool {
+	return new.Alert && !old.Alert
+}
+
+// OnListGrow marks the dashboard dirty when an item is added to a comma separated list
+func OnListGrow(old Value, new Value) bool {
+
+	var have []string
+	if old.Received {
+		have = list(old.Text)
+	}
+
+	for _, item := range list(new.Text) {
+		if !contains(have, item) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddItem is a Merge that adds the value to a comma separated list
+func AddItem(current string, value string) string {
+
+	items := list(current)
+	if value == "" || contains(items, value) {
+		return current
+	}
+	return strings.Join(append(items, value), ",")
+}
+
+// RemoveItem is a Merge that removes the value from a comma separated list
+func RemoveItem(current string, value string) string {
+
+	var items []string
+	for _, item := range list(current) {
+		if item != value {
+			items = append(items, item)
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// Always is an alert key that always raises the alert
+func Always(value string) bool {
+	return true
+}
+
+// Never is an alert key that always clears the alert
+func Never(value string) bool {
+	return false
+}
+
+// NotZero is an alert for a count, it is raised for anything but 0 or an empty value
+func NotZero(value string) bool {
+	return value != "" && value != "0"
+}
+
+func list(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -1,12 +1,14 @@
+//go:build tinygo
+
 package dsp
 
 import (
-	"fmt"
 	"image/color"
 	"log"
 	"machine"
 	"time"
 
+	"github.com/tonygilkerson/mbx-iot/internal/dashboard"
 	"tinygo.org/x/drivers/waveshare-epd/epd4in2"
 	"tinygo.org/x/drivers/ws2812"
 	"tinygo.org/x/tinyfont"
@@ -14,75 +16,6 @@ import (
 	"tinygo.org/x/tinyfont/gophers"
 )
 
-// Content for the Display
-type Content struct {
-	name      string
-	isDirty   bool
-	lastClean time.Time
-	age       string
-
-	gatewayHeartbeatStatus string
-	mbxDoorOpenedStatus    string
-	youGotMailIndicator    string
-}
-
-// NewContent
-func NewContent() *Content {
-
-	content := Content{
-		isDirty:                false,
-		lastClean:              time.Now(),
-		age:                    "0h",
-		name:                   "Mailbox IOT",
-		gatewayHeartbeatStatus: "initial",
-		mbxDoorOpenedStatus:    "initial",
-		youGotMailIndicator:    "initial",
-	}
-
-	return &content
-}
-
-func (content *Content) SetGatewayHeartbeatStatus(status string) {
-	if status != content.gatewayHeartbeatStatus {
-		// Don't set is dirty just for a heartbeat
-		content.gatewayHeartbeatStatus = status
-	}
-}
-func (content *Content) SetMbxDoorOpenedStatus(status string) {
-
-	if status != content.mbxDoorOpenedStatus {
-		content.isDirty = true
-		content.mbxDoorOpenedStatus = status
-		content.youGotMailIndicator = "You got mail!"
-	}
-}
-
-func (content *Content) SetIsDirty(d bool) {
-
-	log.Printf("internal.dsp.SetIsDirty: %v ", d)
-	content.isDirty = d
-	content.lastClean = time.Now() // reset
-	content.UpdateAge()
-
-}
-
-
-func (content *Content) UpdateAge() {
-
-	duration := time.Since(content.lastClean)
-  age := fmt.Sprintf("%1.1fh", duration.Hours())
-	content.age = age
-
-}
-
-func (content *Content) IsDirty() bool {
-	return content.isDirty
-}
-
-func (content *Content) SetYouGotMailIndicator(i string) {
-	content.youGotMailIndicator = i
-}
-
 func RunLight(led machine.Pin, count int) {
 
 	// blink run light for a bit seconds so I can tell it is starting
@@ -131,27 +64,23 @@ func FontExamples(display *epd4in2.Device) {
 
 }
 
-func (content *Content) DisplayContent(display *epd4in2.Device) {
+// DisplayDashboard writes the dashboard to the display, one tile per line
+func DisplayDashboard(display *epd4in2.Device, board *dashboard.Dashboard, now time.Time) {
 
-	log.Println("internal.dsp.DisplayContent: sleep for a bit!")
+	log.Println("internal.dsp.DisplayDashboard: sleep for a bit!")
 
 	black := color.RGBA{1, 1, 1, 255}
 	time.Sleep(3 * time.Second)
 
-	stuff := fmt.Sprintf("Gateway HB: %s\n", content.gatewayHeartbeatStatus)
-	stuff += fmt.Sprintf("Age: %s\n", content.age)
-	stuff += fmt.Sprintf("-------------------------\n\n")
-	stuff += fmt.Sprintf("Mbx: %s %s ", content.mbxDoorOpenedStatus, content.youGotMailIndicator)
-
 	// tinyfont.WriteLineRotated(display, &gophers.Regular58pt, 40, 50,  "HH", black, tinyfont.NO_ROTATION)
-	tinyfont.WriteLineRotated(display, &freemono.Bold9pt7b, 30, 50, stuff, black, tinyfont.NO_ROTATION)
+	tinyfont.WriteLineRotated(display, &freemono.Bold9pt7b, 30, 50, board.Text(now), black, tinyfont.NO_ROTATION)
 
-	log.Println("internal.dsp.DisplayContent: Display()")
+	log.Println("internal.dsp.DisplayDashboard: Display()")
 	display.Display()
 
-	log.Println("internal.dsp.DisplayContent: WaitUntilIdle()")
+	log.Println("internal.dsp.DisplayDashboard: WaitUntilIdle()")
 	display.WaitUntilIdle()
-	log.Println("internal.dsp.DisplayContent: WaitUntilIdle() done.")
+	log.Println("internal.dsp.DisplayDashboard: WaitUntilIdle() done.")
 
 }
 
@@ -0,0 +1,124 @@
+package dsp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tonygilkerson/mbx-iot/internal/dashboard"
+	"github.com/tonygilkerson/mbx-iot/pkg/iot"
+)
+
+// Tiles are the lines of the e-paper dashboard, dsp.com passes on the messages for these keys.
+// To show another status add a tile here.
+var Tiles = []dashboard.Tile{
+	{
+		Label:      "Gateway HB",
+		Key:        iot.GatewayHeartbeat,
+		Initial:    "initial",
+		StaleAfter: time.Minute * 5,
+	},
+	{
+		// Time since someone last acknowledged the display
+		Label: "Age",
+		Format: func(v dashboard.Value) string {
+			return fmt.Sprintf("%1.1fh", v.Clean.Hours())
+		},
+	},
+	{
+		Label: "Down",
+		Key:   iot.GatewayNodesOffline,
+		Format: func(v dashboard.Value) string {
+			if !v.Received || v.Text == "" {
+				return "none"
+			}
+			return v.Text
+		},
+		Merge: map[string]func(string, string) string{
+			iot.NodeOffline: dashboard.AddItem,
+			iot.NodeOnline:  dashboard.RemoveItem,
+		},
+		Dirty: dashboard.OnListGrow,
+	},
+	{
+		Label:   "Batt",
+		Key:     iot.MbxBatteryPercent,
+		Initial: "initial",
+		Format: func(v dashboard.Value) string {
+			switch {
+			case !v.Received:
+				return v.Text
+			case v.Alert:
+				return v.Text + "% LOW!"
+			default:
+				return v.Text + "%"
+			}
+		},
+		AlertKeys: map[string]func(string) bool{
+			iot.BatteryLow: dashboard.Always,
+			iot.BatteryOk:  dashboard.Never,
+		},
+		Dirty: dashboard.OnAlert,
+	},
+	{
+		Label:   "Soil",
+		Key:     iot.SoilMoisture,
+		Sensors: true,
+		Format: func(v dashboard.Value) string {
+			// The value is raw,percent, the percent is -1 if the probe is not calibrated
+			parts := strings.Split(v.Text, ",")
+			switch {
+			case len(parts) < 2:
+				return v.Text
+			case parts[1] == "-1":
+				return "uncal"
+			}
+			return parts[1] + "%"
+		},
+		StaleAfter: time.Minute * 30,
+	},
+	{
+		Label: "Meds",
+		Key:   iot.MedsLastDoseHours,
+		Format: func(v dashboard.Value) string {
+			text := v.Text + "h ago"
+			if !v.Received || v.Text == "-1" {
+				text = "unknown"
+			}
+			if v.Alert {
+				text += " OVERDUE!"
+			}
+			return text
+		},
+		AlertKeys: map[string]func(string) bool{
+			iot.MedsOverdue: dashboard.NotZero,
+		},
+		Dirty: dashboard.OnAlert,
+	},
+	{
+		Format: func(v dashboard.Value) string {
+			return "-------------------------\n"
+		},
+	},
+	{
+		Label:   "Mbx",
+		Key:     iot.MbxDoorOpened,
+		Initial: "initial",
+		Format: func(v dashboard.Value) string {
+			switch {
+			case !v.Received:
+				return v.Text
+			case v.Changed:
+				return v.Text + " You got mail!"
+			default:
+				return v.Text + " waiting..."
+			}
+		},
+		Dirty: dashboard.OnChange,
+	},
+	{
+		// Counted by the gateway from its event history
+		Label: "Mail 24h",
+		Key:   iot.GatewayMailToday,
+	},
+}
@@ -0,0 +1,145 @@
+package dsp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tonygilkerson/mbx-iot/internal/clock"
+	"github.com/tonygilkerson/mbx-iot/internal/dashboard"
+	"github.com/tonygilkerson/mbx-iot/pkg/iot"
+)
+
+// line returns the dashboard line with the label
+func line(t *testing.T, board *dashboard.Dashboard, label string, now time.Time) string {
+	t.Helper()
+
+	for _, l := range board.Lines(now) {
+		if strings.HasPrefix(l, label+": ") {
+			return l
+		}
+	}
+	t.Fatalf("no %q line in %q", label, board.Text(now))
+	return ""
+}
+
+func TestTilesGoStale(t *testing.T) {
+
+	clk := clock.NewFake(time.Unix(1_700_000_000, 0))
+	board := dashboard.New(Tiles, clk.Now())
+
+	board.Update(iot.GatewayHeartbeat, "7", clk.Now())
+	board.Update(iot.SoilMoisture, "612,43.5", clk.Now())
+
+	clk.Advance(time.Minute * 5)
+	if got := line(t, board, "Gateway HB", clk.Now()); got != "Gateway HB: 7" {
+		t.Errorf("at 5m %q", got)
+	}
+
+	clk.Advance(time.Second)
+	if got := line(t, board, "Gateway HB", clk.Now()); got != "Gateway HB: 7 (stale)" {
+		t.Errorf("after 5m %q", got)
+	}
+	if got := line(t, board, "Soil", clk.Now()); got != "Soil: 43.5%" {
+		t.Errorf("soil at 5m %q", got)
+	}
+
+	clk.Advance(time.Minute * 25)
+	if got := line(t, board, "Soil", clk.Now()); got != "Soil: 43.5% (stale)" {
+		t.Errorf("soil after 30m %q", got)
+	}
+
+	// Setting the clock does not make everything stale
+	board.ClockJumped(time.Hour * 24)
+	board.Update(iot.GatewayHeartbeat, "8", clk.Now().Add(time.Hour*24))
+	if got := line(t, board, "Gateway HB", clk.Now().Add(time.Hour*24)); got != "Gateway HB: 8" {
+		t.Errorf("after the clock jump %q", got)
+	}
+}
+
+func TestTileValues(t *testing.T) {
+
+	clk := clock.NewFake(time.Unix(1_700_000_000, 0))
+	board := dashboard.New(Tiles, clk.Now())
+
+	tests := []struct {
+		key, value string
+		label      string
+		want       string
+	}{
+		{key: iot.SoilMoisture, value: "512,-1", label: "Soil", want: "Soil: uncal"},
+		{key: iot.MedsLastDoseHours, value: "-1", label: "Meds", want: "Meds: unknown"},
+		{key: iot.MedsLastDoseHours, value: "3.5", label: "Meds", want: "Meds: 3.5h ago"},
+		{key: iot.MedsOverdue, value: "12", label: "Meds", want: "Meds: 3.5h ago OVERDUE!"},
+		{key: iot.MedsOverdue, value: "0", label: "Meds", want: "Meds: 3.5h ago"},
+		{key: iot.MbxBatteryPercent, value: "80", label: "Batt", want: "Batt: 80%"},
+		{key: iot.BatteryLow, value: "mbx", label: "Batt", want: "Batt: 80% LOW!"},
+		{key: iot.NodeOffline, value: "soil", label: "Down", want: "Down: soil"},
+		{key: iot.NodeOnline, value: "soil", label: "Down", want: "Down: none"},
+	}
+
+	for _, tt := range tests {
+		clk.Advance(time.Second)
+		board.Update(tt.key, tt.value, clk.Now())
+		if got := line(t, board, tt.label, clk.Now()); got != tt.want {
+			t.Errorf("after %v:%v %q, want %q", tt.key, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestMailAndAge(t *testing.T) {
+
+	start := time.Unix(1_700_000_000, 0)
+	clk := clock.NewFake(start)
+	board := dashboard.New(Tiles, clk.Now())
+
+	if got := line(t, board, "Mbx", clk.Now()); got != "Mbx: initial" {
+		t.Errorf("before any mail %q", got)
+	}
+
+	clk.Advance(time.Hour * 2)
+	board.Update(iot.MbxDoorOpened, "1", clk.Now())
+	if got := line(t, board, "Mbx", clk.Now()); got != "Mbx: 1 You got mail!" || !board.IsDirty() {
+		t.Errorf("after the door opened %q dirty %v", got, board.IsDirty())
+	}
+	if got := line(t, board, "Age", clk.Now()); got != "Age: 2.0h" {
+		t.Errorf("age %q", got)
+	}
+
+	// Someone looked at the display
+	board.Ack(clk.Now())
+	clk.Advance(time.Minute * 30)
+	if got := line(t, board, "Mbx", clk.Now()); got != "Mbx: 1 waiting..." || board.IsDirty() {
+		t.Errorf("after the ack %q dirty %v", got, board.IsDirty())
+	}
+	if got := line(t, board, "Age", clk.Now()); got != "Age: 0.5h" {
+		t.Errorf("age after the ack %q", got)
+	}
+}
+
+func TestSoilProbes(t *testing.T) {
+
+	clk := clock.NewFake(time.Unix(1_700_000_000, 0))
+	board := dashboard.New(Tiles, clk.Now())
+
+	if !dashboard.Uses(Tiles, iot.SoilMoisture+"-0x37") || dashboard.Uses(Tiles, iot.SoilSensorError+"-0x37") {
+		t.Error("Uses should take the per-probe soil keys only")
+	}
+
+	// A probe at another address shows on the same line, before and after the default probe reports
+	board.Update(iot.SoilMoisture+"-0x38", "700,51", clk.Now())
+	if got := line(t, board, "Soil", clk.Now()); got != "Soil: 0x38 51%" {
+		t.Errorf("one probe %q", got)
+	}
+
+	board.Update(iot.SoilMoisture, "612,43.5", clk.Now())
+	board.Update(iot.SoilMoisture+"-0x37", "650,-1", clk.Now())
+	if got := line(t, board, "Soil", clk.Now()); got != "Soil: 43.5%, 0x37 uncal, 0x38 51%" {
+		t.Errorf("three probes %q", got)
+	}
+
+	clk.Advance(time.Minute * 31)
+	if got := line(t, board, "Soil", clk.Now()); got != "Soil: 43.5%, 0x37 uncal, 0x38 51% (stale)" {
+		t.Errorf("after 30m %q", got)
+	}
+}
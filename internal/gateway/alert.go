@@ -0,0 +1,31 @@
+package gateway
+
+// LowAlert raises an alert when a value drops below Low and clears it once the value
+// climbs back above Clear. Keeping Clear above Low stops a value that hovers around
+// the threshold from toggling the alert on every reading.
+type LowAlert struct {
+	Low   float64
+	Clear float64
+
+	active bool
+}
+
+// Update feeds the alert a new value. It returns the alert state and whether the state changed.
+func (a *LowAlert) Update(value float64) (active bool, changed bool) {
+
+	switch {
+	case !a.active && value < a.Low:
+		a.active = true
+		changed = true
+	case a.active && value > a.Clear:
+		a.active = false
+		changed = true
+	}
+
+	return a.active, changed
+}
+
+// Active returns the current alert state
+func (a *LowAlert) Active() bool {
+	return a.active
+}
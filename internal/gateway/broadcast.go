@@ -0,0 +1,107 @@
+package gateway
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Policy controls when a status key is broadcast over the air
+type Policy int
+
+// Never
+// The key is kept in the gateway status but is never broadcast
+//
+// OnChange
+// The key is broadcast when its value changes. If the rule has an Interval the
+// value is also re-sent after that long so a display that restarts will catch up.
+//
+// OnInterval
+// The key is broadcast every Interval whether it changed or not
+const (
+	Never Policy = iota
+	OnChange
+	OnInterval
+)
+
+// Rule is the broadcast policy for one status key
+type Rule struct {
+	Key      string
+	Policy   Policy
+	Interval time.Duration
+
+	// Sensors applies the rule to the per-sensor keys Key-<address> as well, for example SoilMoisture-0x37
+	Sensors bool
+}
+
+// keys returns the status keys the rule applies to, the key itself first and then the sensors in order
+func (r Rule) keys(statusMap map[string]string) []string {
+
+	keys := []string{r.Key}
+	if !r.Sensors {
+		return keys
+	}
+
+	var sensors []string
+	for k := range statusMap {
+		if strings.HasPrefix(k, r.Key+"-") {
+			sensors = append(sensors, k)
+		}
+	}
+	sort.Strings(sensors)
+
+	return append(keys, sensors...)
+}
+
+type lastSent struct {
+	value string
+	at    time.Time
+}
+
+// Broadcaster decides which status keys to send over the air.
+// The rules act as an allow-list, keys without a rule are never broadcast.
+type Broadcaster struct {
+	rules []Rule
+	sent  map[string]lastSent
+}
+
+// NewBroadcaster creates a broadcaster for the given rules, messages are produced in rule order
+func NewBroadcaster(rules []Rule) *Broadcaster {
+	return &Broadcaster{
+		rules: rules,
+		sent:  make(map[string]lastSent),
+	}
+}
+
+// Messages returns the key:value messages that are due to be broadcast and records them as sent
+func (b *Broadcaster) Messages(statusMap map[string]string, now time.Time) []string {
+
+	var messages []string
+
+	for _, rule := range b.rules {
+		for _, key := range rule.keys(statusMap) {
+
+			value, found := statusMap[key]
+			if !found {
+				continue
+			}
+
+			last, wasSent := b.sent[key]
+			due := false
+
+			switch rule.Policy {
+			case OnChange:
+				due = !wasSent || value != last.value || (rule.Interval > 0 && now.Sub(last.at) >= rule.Interval)
+			case OnInterval:
+				due = !wasSent || now.Sub(last.at) >= rule.Interval
+			}
+
+			if due {
+				b.sent[key] = lastSent{value: value, at: now}
+				messages = append(messages, key+":"+value)
+			}
+		}
+	}
+
+	return messages
+}
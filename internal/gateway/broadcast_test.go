@@ -0,0 +1,138 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tonygilkerson/mbx-iot/pkg/iot"
+)
+
+func TestBroadcastSensors(t *testing.T) {
+
+	now := time.Unix(1_700_000_000, 0)
+	b := NewBroadcaster([]Rule{
+		{Key: iot.SoilMoisture, Policy: OnChange, Interval: time.Minute * 15, Sensors: true},
+		{Key: iot.MbxBatteryPercent, Policy: OnChange},
+	})
+
+	status := map[string]string{
+		iot.SoilMoisture + "-0x38": "700,51",
+		iot.SoilMoisture:           "612,43",
+		iot.SoilMoisture + "-0x37": "650,-1",
+		iot.SoilMoisture + "Raw":   "1",
+		iot.SoilSensorError:        "read",
+	}
+
+	got := b.Messages(status, now)
+	want := []string{"SoilMoisture:612,43", "SoilMoisture-0x37:650,-1", "SoilMoisture-0x38:700,51"}
+	if len(got) != len(want) {
+		t.Fatalf("Messages = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Messages = %v, want %v", got, want)
+		}
+	}
+
+	// Each probe is sent on its own change
+	status[iot.SoilMoisture+"-0x37"] = "655,-1"
+	if got := b.Messages(status, now.Add(time.Minute)); len(got) != 1 || got[0] != "SoilMoisture-0x37:655,-1" {
+		t.Errorf("after a change Messages = %v, want only the changed probe", got)
+	}
+
+	if got := b.Messages(status, now.Add(time.Minute*16)); len(got) != 3 {
+		t.Errorf("after the interval Messages = %v, want every probe again", got)
+	}
+}
+
+// messageSet returns the messages as a set
+func messageSet(messages []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, m := range messages {
+		set[m] = true
+	}
+	return set
+}
+
+func TestBroadcastPolicies(t *testing.T) {
+
+	now := time.Unix(1_700_000_000, 0)
+	b := NewBroadcaster([]Rule{
+		{Key: iot.MbxDoorOpened, Policy: OnChange},
+		{Key: iot.MbxBatteryPercent, Policy: OnChange, Interval: time.Minute * 15},
+		{Key: iot.GatewayHeartbeat, Policy: OnInterval, Interval: time.Minute},
+		{Key: iot.MbxTemperature, Policy: Never},
+	})
+
+	status := map[string]string{
+		iot.MbxDoorOpened:     "1",
+		iot.MbxBatteryPercent: "80",
+		iot.GatewayHeartbeat:  "1",
+		iot.MbxTemperature:    "21",
+	}
+
+	tests := []struct {
+		name   string
+		after  time.Duration
+		change map[string]string
+		want   []string
+	}{
+		{name: "first", want: []string{"MailboxDoorOpened:1", "BatteryPercent:80", "GatewayHeartbeat:1"}},
+		{name: "unchanged", after: time.Second * 30},
+		{name: "changed", after: time.Second * 40, change: map[string]string{iot.MbxDoorOpened: "2"}, want: []string{"MailboxDoorOpened:2"}},
+		{name: "interval", after: time.Minute, want: []string{"GatewayHeartbeat:1"}},
+		{name: "never even when changed", after: time.Minute + time.Second, change: map[string]string{iot.MbxTemperature: "22"}},
+		{name: "on change re-sent after its interval", after: time.Minute * 15, want: []string{"BatteryPercent:80", "GatewayHeartbeat:1"}},
+		{name: "on change without an interval is not re-sent", after: time.Hour, want: []string{"BatteryPercent:80", "GatewayHeartbeat:1"}},
+	}
+
+	for _, tt := range tests {
+		for k, v := range tt.change {
+			status[k] = v
+		}
+
+		got := b.Messages(status, now.Add(tt.after))
+		if len(got) != len(tt.want) {
+			t.Fatalf("%v: Messages = %v, want %v", tt.name, got, tt.want)
+		}
+		for i := range tt.want {
+			if got[i] != tt.want[i] {
+				t.Fatalf("%v: Messages = %v, want %v in rule order", tt.name, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestBroadcastAllowList(t *testing.T) {
+
+	now := time.Unix(1_700_000_000, 0)
+	b := NewBroadcaster([]Rule{
+		{Key: iot.MbxBatteryPercent, Policy: OnChange},
+		{Key: iot.SoilMoisture, Policy: OnChange},
+		{Key: iot.MedsTaken, Policy: OnChange},
+	})
+
+	// The med node has not reported, only the keys with a rule go out
+	status := map[string]string{
+		iot.MbxBatteryPercent:      "80",
+		iot.MbxBatteryVoltage:      "3.9",
+		iot.SoilMoisture:           "612,43",
+		iot.SoilMoisture + "-0x37": "650,-1",
+		iot.SoilSensorError:        "read",
+	}
+
+	got := messageSet(b.Messages(status, now))
+	if len(got) != 2 || !got["BatteryPercent:80"] || !got["SoilMoisture:612,43"] {
+		t.Errorf("Messages = %v, want only the battery percent and the soil moisture", got)
+	}
+
+	// A rule without Sensors does not pick up the per-sensor keys
+	if got := b.Messages(status, now.Add(time.Hour)); len(got) != 0 {
+		t.Errorf("Messages = %v, want nothing unchanged", got)
+	}
+
+	// No rules, nothing is sent
+	if got := NewBroadcaster(nil).Messages(status, now); len(got) != 0 {
+		t.Errorf("Messages without rules = %v, want nothing", got)
+	}
+}
@@ -0,0 +1,100 @@
+package gateway
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DoseTracker remembers when the med node last reported a dose so the gateway can
+// tell the display how long ago it was. The node reports ages rather than times
+// because its clock may not be set.
+type DoseTracker struct {
+	mu sync.Mutex
+
+	lastDose time.Time
+	known    bool
+	overdue  int
+}
+
+// doseMoveTolerance is how far the dose time can drift between reports before it counts as a new
+// or adjusted dose. The node reports whole seconds taken at send time so the same dose moves a little.
+const doseMoveTolerance = time.Minute * 2
+
+// Dose records a dose report, the value is the seconds since the last dose, -1 if it is unknown.
+// A dose that moves later by more than doseMoveTolerance clears the overdue alert, the same dose
+// reported with each heartbeat does not.
+func (d *DoseTracker) Dose(value string, now time.Time) error {
+
+	seconds, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if seconds < 0 {
+		d.known = false
+		return nil
+	}
+
+	lastDose := now.Add(-time.Duration(seconds) * time.Second)
+	if d.known && lastDose.Sub(d.lastDose) > doseMoveTolerance {
+		d.overdue = 0
+	}
+	d.lastDose = lastDose
+	d.known = true
+	return nil
+}
+
+// Taken records a new dose, see Dose for the value
+func (d *DoseTracker) Taken(value string, now time.Time) error {
+
+	if err := d.Dose(value, now); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.overdue = 0
+	return nil
+}
+
+// Overdue records the overdue state, the value is the minutes past due, 0 if the dose is not overdue.
+// The node sends it with every alarm and heartbeat, changed is true only when the dose becomes overdue
+// or stops being overdue so the history is not filled with repeats.
+func (d *DoseTracker) Overdue(value string) (changed bool, err error) {
+
+	minutes, err := strconv.Atoi(value)
+	if err != nil {
+		return false, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	changed = (minutes > 0) != (d.overdue > 0)
+	d.overdue = minutes
+	return changed, nil
+}
+
+// LastDoseHours returns the hours since the last dose formatted for the status map, -1 if it is unknown
+func (d *DoseTracker) LastDoseHours(now time.Time) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.known {
+		return "-1"
+	}
+	return strconv.FormatFloat(now.Sub(d.lastDose).Hours(), 'f', 1, 64)
+}
+
+// OverdueMinutes returns the minutes overdue from the last alert formatted for the status map, 0 if not overdue
+func (d *DoseTracker) OverdueMinutes() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return strconv.Itoa(d.overdue)
+}
@@ -0,0 +1,102 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDoseHeartbeatKeepsOverdue(t *testing.T) {
+
+	now := time.Unix(1_700_000_000, 0)
+	var d DoseTracker
+
+	if err := d.Dose("21600", now); err != nil {
+		t.Fatalf("Dose: %v", err)
+	}
+	if _, err := d.Overdue("20"); err != nil {
+		t.Fatalf("Overdue: %v", err)
+	}
+
+	// The same dose reported with the next heartbeat, a second off
+	if err := d.Dose("21901", now.Add(time.Minute*5)); err != nil {
+		t.Fatalf("Dose: %v", err)
+	}
+	if got := d.OverdueMinutes(); got != "20" {
+		t.Errorf("OverdueMinutes after heartbeat = %v, want 20", got)
+	}
+	if got := d.LastDoseHours(now.Add(time.Minute * 5)); got != "6.1" {
+		t.Errorf("LastDoseHours = %v, want 6.1", got)
+	}
+
+	// Moving the dose later clears it
+	if err := d.Dose("3600", now.Add(time.Minute*5)); err != nil {
+		t.Fatalf("Dose: %v", err)
+	}
+	if got := d.OverdueMinutes(); got != "0" {
+		t.Errorf("OverdueMinutes after adjust = %v, want 0", got)
+	}
+}
+
+func TestDoseTakenAndRestart(t *testing.T) {
+
+	now := time.Unix(1_700_000_000, 0)
+	var d DoseTracker
+
+	// A restarted gateway gets the overdue state back from the heartbeat
+	if err := d.Dose("22500", now); err != nil {
+		t.Fatalf("Dose: %v", err)
+	}
+	if _, err := d.Overdue("15"); err != nil {
+		t.Fatalf("Overdue: %v", err)
+	}
+	if got := d.OverdueMinutes(); got != "15" {
+		t.Errorf("OverdueMinutes = %v, want 15", got)
+	}
+
+	if err := d.Taken("0", now.Add(time.Minute)); err != nil {
+		t.Fatalf("Taken: %v", err)
+	}
+	if got := d.OverdueMinutes(); got != "0" {
+		t.Errorf("OverdueMinutes after taken = %v, want 0", got)
+	}
+
+	if err := d.Dose("-1", now); err != nil {
+		t.Fatalf("Dose: %v", err)
+	}
+	if got := d.LastDoseHours(now); got != "-1" {
+		t.Errorf("LastDoseHours unknown = %v, want -1", got)
+	}
+
+	if _, err := d.Overdue("x"); err == nil {
+		t.Error("Overdue(x) err = nil, want an error")
+	}
+}
+
+func TestOverdueChanged(t *testing.T) {
+
+	var d DoseTracker
+
+	// The heartbeat sends the overdue minutes every few minutes, only the start and the end are a change
+	tests := []struct {
+		value       string
+		wantChanged bool
+	}{
+		{"0", false},
+		{"0", false},
+		{"1", true},
+		{"11", false},
+		{"21", false},
+		{"0", true},
+		{"0", false},
+	}
+
+	for i, tt := range tests {
+		changed, err := d.Overdue(tt.value)
+		if err != nil {
+			t.Fatalf("%v: Overdue(%v): %v", i, tt.value, err)
+		}
+		if changed != tt.wantChanged {
+			t.Errorf("%v: Overdue(%v) changed = %v, want %v", i, tt.value, changed, tt.wantChanged)
+		}
+	}
+}
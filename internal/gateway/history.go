@@ -0,0 +1,177 @@
+package gateway
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tonygilkerson/mbx-iot/pkg/iot"
+)
+
+// Event is something that happened on a node, as seen by the gateway
+type Event struct {
+	At    time.Time
+	Node  string
+	Key   string
+	Value string
+}
+
+// Message returns the event as a GatewayHistoryEvent key:value message.
+// The time is sent as seconds before now because the nodes and the host do not share a clock.
+// The event value goes last because it can have commas of its own, for example the soil moisture raw,percent.
+//
+//	GatewayHistoryEvent:node,key,secondsAgo,value
+func (e Event) Message(now time.Time) string {
+	ago := int64(now.Sub(e.At).Seconds())
+	return iot.GatewayHistoryEvent + ":" + e.Node + "," + e.Key + "," + strconv.FormatInt(ago, 10) + "," + e.Value
+}
+
+// ErrBadEvent is returned by ParseEvent for a value that is not node,key,secondsAgo,value
+var ErrBadEvent = errors.New("gateway: bad history event")
+
+// ParseEvent reads the value of a GatewayHistoryEvent message, the time is worked out from the seconds ago
+func ParseEvent(value string, now time.Time) (e Event, err error) {
+
+	parts := strings.SplitN(value, ",", 4)
+	if len(parts) < 4 {
+		return e, ErrBadEvent
+	}
+
+	ago, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return e, err
+	}
+
+	return Event{
+		At:    now.Add(-time.Duration(ago) * time.Second),
+		Node:  parts[0],
+		Key:   parts[1],
+		Value: parts[3],
+	}, nil
+}
+
+// ring is a fixed size buffer of events, once full the oldest event is overwritten
+type ring struct {
+	events []Event
+	next   int
+	full   bool
+}
+
+func (r *ring) add(e Event) {
+	r.events[r.next] = e
+	r.next = (r.next + 1) % len(r.events)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// all returns the events oldest first
+func (r *ring) all() []Event {
+	if !r.full {
+		return append([]Event(nil), r.events[:r.next]...)
+	}
+	return append(append([]Event(nil), r.events[r.next:]...), r.events[:r.next]...)
+}
+
+// History keeps the most recent events for each node
+type History struct {
+	mu    sync.Mutex
+	size  int
+	nodes map[string]*ring
+}
+
+// NewHistory creates a history that keeps up to size events per node, if size is 0 it defaults to 32
+func NewHistory(size int) *History {
+
+	if size == 0 {
+		size = 32
+	}
+
+	return &History{
+		size:  size,
+		nodes: make(map[string]*ring),
+	}
+}
+
+// Record adds an event to the node's history
+func (h *History) Record(node string, key string, value string, at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r, found := h.nodes[node]
+	if !found {
+		r = &ring{events: make([]Event, h.size)}
+		h.nodes[node] = r
+	}
+
+	r.add(Event{At: at, Node: node, Key: key, Value: value})
+}
+
+// Events returns up to limit of the node's most recent events, oldest first. A limit of 0 returns them all.
+func (h *History) Events(node string, limit int) []Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r, found := h.nodes[node]
+	if !found {
+		return nil
+	}
+
+	events := r.all()
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+
+	return events
+}
+
+// Count returns how many times key was recorded for the node since the given time
+func (h *History) Count(node string, key string, since time.Time) int {
+
+	var count int
+	for _, e := range h.Events(node, 0) {
+		if e.Key == key && !e.At.Before(since) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Last returns the most recent event with the given key for the node
+func (h *History) Last(node string, key string) (last Event, ok bool) {
+
+	events := h.Events(node, 0)
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Key == key {
+			return events[i], true
+		}
+	}
+
+	return last, false
+}
+
+// Query answers a GatewayHistoryQuery. The query value is the node name optionally followed by a limit,
+// for example "mbx" or "mbx,5". The reply is one GatewayHistoryEvent message per event followed by a
+// GatewayHistoryEnd message with the node name and the number of events sent.
+func (h *History) Query(query string, now time.Time) []string {
+
+	parts := strings.Split(query, ",")
+	node := parts[0]
+
+	var limit int
+	if len(parts) > 1 {
+		limit, _ = strconv.Atoi(parts[1])
+	}
+
+	var messages []string
+	events := h.Events(node, limit)
+	for _, e := range events {
+		messages = append(messages, e.Message(now))
+	}
+	messages = append(messages, iot.GatewayHistoryEnd+":"+node+","+strconv.Itoa(len(events)))
+
+	return messages
+}
@@ -0,0 +1,52 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tonygilkerson/mbx-iot/pkg/iot"
+)
+
+func TestEventMessageRoundTrip(t *testing.T) {
+
+	now := time.Unix(1_700_000_000, 0)
+	e := Event{At: now.Add(-time.Minute), Node: iot.NodeSoil, Key: iot.SoilWateringStopped, Value: "120,dry"}
+
+	msg := e.Message(now)
+	want := iot.GatewayHistoryEvent + ":soil,WateringStopped,60,120,dry"
+	if msg != want {
+		t.Fatalf("Message() = %q, want %q", msg, want)
+	}
+
+	got, err := ParseEvent(msg[len(iot.GatewayHistoryEvent)+1:], now)
+	if err != nil {
+		t.Fatalf("ParseEvent: %v", err)
+	}
+	if got != e {
+		t.Errorf("ParseEvent = %+v, want %+v", got, e)
+	}
+
+	if _, err := ParseEvent("soil,WateringStopped", now); err != ErrBadEvent {
+		t.Errorf("ParseEvent short value err = %v, want ErrBadEvent", err)
+	}
+}
+
+func TestQueryLimit(t *testing.T) {
+
+	now := time.Unix(1_700_000_000, 0)
+	h := NewHistory(4)
+	for i := 0; i < 6; i++ {
+		h.Record(iot.NodeMbx, iot.MbxDoorOpened, "", now.Add(time.Duration(i)*time.Second))
+	}
+
+	replies := h.Query("mbx,2", now.Add(time.Minute))
+	if len(replies) != 3 {
+		t.Fatalf("got %d replies, want 2 events and the end: %v", len(replies), replies)
+	}
+	if replies[2] != iot.GatewayHistoryEnd+":mbx,2" {
+		t.Errorf("end = %q", replies[2])
+	}
+	if n := h.Count(iot.NodeMbx, iot.MbxDoorOpened, now); n != 4 {
+		t.Errorf("Count = %d, want the 4 kept events", n)
+	}
+}
@@ -0,0 +1,137 @@
+package gateway
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tonygilkerson/mbx-iot/pkg/iot"
+)
+
+// Transition is a change in a node's online state
+type Transition struct {
+	Node   string
+	Online bool
+}
+
+// Message returns the transition as a key:value message, for example "NodeOffline:mbx"
+func (t Transition) Message() string {
+	if t.Online {
+		return iot.NodeOnline + ":" + t.Node
+	}
+	return iot.NodeOffline + ":" + t.Node
+}
+
+type node struct {
+	name         string
+	heartbeatKey string
+	interval     time.Duration
+	lastHeard    time.Time
+	online       bool
+}
+
+// Liveness tracks the last time each node's heartbeat was heard.
+// A node is considered offline once it has missed missedIntervals heartbeats in a row.
+// Heard is called from the goroutine reading the radio while the main loop calls Check, the nodes are guarded by mu.
+type Liveness struct {
+	mu              sync.Mutex
+	missedIntervals int
+	nodes           []*node
+}
+
+// NewLiveness creates a liveness tracker, if missedIntervals is 0 it defaults to 3
+func NewLiveness(missedIntervals int) *Liveness {
+
+	if missedIntervals == 0 {
+		missedIntervals = 3
+	}
+
+	return &Liveness{missedIntervals: missedIntervals}
+}
+
+// Register adds a node to track. The node starts out online as of now so it has
+// a full grace period to send its first heartbeat after the gateway boots.
+func (l *Liveness) Register(name string, heartbeatKey string, interval time.Duration, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.nodes = append(l.nodes, &node{
+		name:         name,
+		heartbeatKey: heartbeatKey,
+		interval:     interval,
+		lastHeard:    now,
+		online:       true,
+	})
+
+}
+
+// Heard records a message key. If the key is the heartbeat of a node that was offline,
+// the node is marked online and the transition is returned with ok set to true.
+func (l *Liveness) Heard(key string, now time.Time) (t Transition, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, n := range l.nodes {
+		if n.heartbeatKey != key {
+			continue
+		}
+
+		n.lastHeard = now
+		if !n.online {
+			n.online = true
+			return Transition{Node: n.name, Online: true}, true
+		}
+	}
+
+	return t, false
+}
+
+// Check returns a transition for each node that has just missed too many heartbeats
+func (l *Liveness) Check(now time.Time) []Transition {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var transitions []Transition
+
+	for _, n := range l.nodes {
+		if !n.online {
+			continue
+		}
+
+		if now.Sub(n.lastHeard) > n.interval*time.Duration(l.missedIntervals) {
+			n.online = false
+			transitions = append(transitions, Transition{Node: n.name, Online: false})
+		}
+	}
+
+	return transitions
+}
+
+// Offline returns the names of the nodes that are currently offline
+func (l *Liveness) Offline() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var names []string
+
+	for _, n := range l.nodes {
+		if !n.online {
+			names = append(names, n.name)
+		}
+	}
+
+	return names
+}
+
+// LastHeard returns the last time a heartbeat was heard from the named node
+func (l *Liveness) LastHeard(name string) (lastHeard time.Time, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, n := range l.nodes {
+		if n.name == name {
+			return n.lastHeard, true
+		}
+	}
+
+	return lastHeard, false
+}
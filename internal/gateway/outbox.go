@@ -0,0 +1,122 @@
+package gateway
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Command is a downlink command waiting to be acknowledged by a node
+type Command struct {
+	ID       string
+	Key      string
+	Value    string
+	Queued   time.Time
+	Attempts int
+
+	next time.Time
+}
+
+// Message returns the command as a key:value message with the id in front of the value
+//
+//	SoilCommand:id,verb,args
+func (c Command) Message() string {
+	return c.Key + ":" + c.ID + "," + c.Value
+}
+
+// Outbox holds downlink commands until the node acknowledges them.
+//
+// A node only listens for a short window each cycle so a command is sent again every
+// Retry until it is acknowledged or has been sent MaxAttempts times. The node must
+// acknowledge a repeated id without applying the command a second time.
+type Outbox struct {
+	mu      sync.Mutex
+	pending []*Command
+	nextID  uint32
+
+	// Retry is how long to wait for an ack before sending again
+	Retry time.Duration
+
+	// MaxAttempts is the number of sends before the command is given up on
+	MaxAttempts int
+}
+
+// NewOutbox creates an outbox, the first command gets firstID.
+// Seed firstID randomly so the ids do not repeat across gateway restarts.
+func NewOutbox(retry time.Duration, maxAttempts int, firstID uint32) *Outbox {
+	return &Outbox{
+		nextID:      firstID,
+		Retry:       retry,
+		MaxAttempts: maxAttempts,
+	}
+}
+
+// Queue adds a command, it is due to be sent right away. The id assigned to the command is returned.
+func (o *Outbox) Queue(key string, value string, now time.Time) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	id := strconv.FormatUint(uint64(o.nextID), 10)
+	o.nextID++
+
+	o.pending = append(o.pending, &Command{ID: id, Key: key, Value: value, Queued: now, next: now})
+	return id
+}
+
+// Ack removes the command, the ack value starts with the command id for example "42,ok".
+// It returns the acknowledged command and false if there is no pending command with the id.
+func (o *Outbox) Ack(ackValue string) (cmd Command, ok bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	id := strings.Split(ackValue, ",")[0]
+	for i, c := range o.pending {
+		if c.ID == id {
+			o.pending = append(o.pending[:i], o.pending[i+1:]...)
+			return *c, true
+		}
+	}
+
+	return cmd, false
+}
+
+// Due returns the messages to send now and the commands that were given up on
+func (o *Outbox) Due(now time.Time) (send []string, expired []Command) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var keep []*Command
+	for _, c := range o.pending {
+
+		if now.Before(c.next) {
+			keep = append(keep, c)
+			continue
+		}
+
+		if c.Attempts >= o.MaxAttempts {
+			expired = append(expired, *c)
+			continue
+		}
+
+		c.Attempts++
+		c.next = now.Add(o.Retry)
+		send = append(send, c.Message())
+		keep = append(keep, c)
+	}
+	o.pending = keep
+
+	return send, expired
+}
+
+// Pending returns the commands waiting for an ack
+func (o *Outbox) Pending() []Command {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var cmds []Command
+	for _, c := range o.pending {
+		cmds = append(cmds, *c)
+	}
+	return cmds
+}
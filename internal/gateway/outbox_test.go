@@ -0,0 +1,94 @@
+package gateway
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/tonygilkerson/mbx-iot/internal/clock"
+	"github.com/tonygilkerson/mbx-iot/pkg/iot"
+)
+
+func TestOutboxIDs(t *testing.T) {
+
+	now := time.Unix(1_700_000_000, 0)
+
+	// The ids count up from the random seed so a restarted gateway does not reuse the ids a node remembers
+	o := NewOutbox(time.Minute, 3, 3_141_592)
+	if id := o.Queue(iot.SoilCommand, "water,60", now); id != "3141592" {
+		t.Errorf("first id = %v, want the seed", id)
+	}
+	if id := o.Queue(iot.SoilCommand, "skip", now); id != "3141593" {
+		t.Errorf("second id = %v, want the next one", id)
+	}
+
+	// The id wraps rather than overflowing
+	o = NewOutbox(time.Minute, 3, math.MaxUint32)
+	o.Queue(iot.SoilCommand, "skip", now)
+	if id := o.Queue(iot.SoilCommand, "skip", now); id != "0" {
+		t.Errorf("id after the max = %v, want 0", id)
+	}
+}
+
+func TestOutboxResend(t *testing.T) {
+
+	clk := clock.NewFake(time.Unix(1_700_000_000, 0))
+	o := NewOutbox(time.Minute, 3, 7)
+	o.Queue(iot.SoilCommand, "water,60", clk.Now())
+
+	send, expired := o.Due(clk.Now())
+	if len(send) != 1 || send[0] != iot.SoilCommand+":7,water,60" || len(expired) != 0 {
+		t.Fatalf("Due = %v %v, want the command sent right away", send, expired)
+	}
+
+	// Nothing until the ack has had the retry time to come back
+	clk.Advance(time.Second * 59)
+	if send, _ := o.Due(clk.Now()); len(send) != 0 {
+		t.Errorf("Due before the retry = %v, want nothing", send)
+	}
+
+	clk.Advance(time.Second)
+	if send, _ := o.Due(clk.Now()); len(send) != 1 {
+		t.Errorf("Due after the retry = %v, want the command again", send)
+	}
+	clk.Advance(time.Minute)
+	o.Due(clk.Now())
+
+	// Sent MaxAttempts times without an ack, it is given up on
+	clk.Advance(time.Minute)
+	send, expired = o.Due(clk.Now())
+	if len(send) != 0 || len(expired) != 1 || expired[0].ID != "7" || expired[0].Attempts != 3 {
+		t.Errorf("Due after the last attempt = %v %+v, want the command expired after 3 attempts", send, expired)
+	}
+	if len(o.Pending()) != 0 {
+		t.Errorf("Pending = %+v, want nothing after the expiry", o.Pending())
+	}
+}
+
+func TestOutboxAck(t *testing.T) {
+
+	clk := clock.NewFake(time.Unix(1_700_000_000, 0))
+	o := NewOutbox(time.Minute, 3, 7)
+	o.Queue(iot.SoilCommand, "water,60", clk.Now())
+	o.Queue(iot.SoilCommand, "skip", clk.Now())
+	o.Due(clk.Now())
+
+	c, ok := o.Ack("7,ok")
+	if !ok || c.ID != "7" || c.Attempts != 1 {
+		t.Fatalf("Ack = %+v %v, want command 7 after 1 attempt", c, ok)
+	}
+
+	// The node acks every resend so the same id can come back after it was dropped
+	if _, ok := o.Ack("7,ok"); ok {
+		t.Error("duplicate Ack ok, want false")
+	}
+	if _, ok := o.Ack("99,ok"); ok {
+		t.Error("unknown Ack ok, want false")
+	}
+
+	// Only the acked command stops being sent
+	clk.Advance(time.Minute)
+	if send, _ := o.Due(clk.Now()); len(send) != 1 || send[0] != iot.SoilCommand+":8,skip" {
+		t.Errorf("Due = %v, want only the command waiting for its ack", send)
+	}
+}
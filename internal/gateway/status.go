@@ -0,0 +1,59 @@
+package gateway
+
+import (
+	"strconv"
+	"sync"
+)
+
+// Status is the gateway's latest value for each status key.
+// The goroutine reading the radio writes it while the main loop broadcasts it, the values are guarded by mu.
+type Status struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+// NewStatus creates an empty status
+func NewStatus() *Status {
+	return &Status{values: make(map[string]string)}
+}
+
+// Set stores the value for a key
+func (s *Status) Set(key string, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values[key] = value
+}
+
+// Get returns the value for a key, it is empty if the key was never set
+func (s *Status) Get(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.values[key]
+}
+
+// Increment adds one to a counter key and returns the new count, a key that is not a number counts from 0
+func (s *Status) Increment(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, _ := strconv.Atoi(s.values[key])
+	c += 1
+	s.values[key] = strconv.Itoa(c)
+
+	return c
+}
+
+// Snapshot returns a copy of the values that is safe to read without the lock
+func (s *Status) Snapshot() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := make(map[string]string, len(s.values))
+	for k, v := range s.values {
+		snapshot[k] = v
+	}
+
+	return snapshot
+}
@@ -0,0 +1,48 @@
+package hbridge
+
+import (
+	"sync"
+)
+
+// FakePin records the level of a pin, use it to run a channel on the host.
+// The end of a pulse sets the pins from another goroutine so the level is read with IsHigh.
+type FakePin struct {
+	mu   sync.Mutex
+	high bool
+}
+
+func (p *FakePin) Set(high bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.high = high
+}
+
+// IsHigh returns the level last set
+func (p *FakePin) IsHigh() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.high
+}
+
+// FakeEnable records the duty cycle of an enable pin
+type FakeEnable struct {
+	mu   sync.Mutex
+	duty uint8
+}
+
+func (e *FakeEnable) SetDuty(duty uint8) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.duty = duty
+}
+
+// Duty returns the duty cycle percent last set
+func (e *FakeEnable) Duty() uint8 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.duty
+}
@@ -1,78 +1,347 @@
+// Package hbridge drives a L293D H-bridge for a motor or a solenoid valve.
+//
+// The channel logic only talks to its pins through the Pin and Enable interfaces and reads the
+// time from a clock.Clock, so it can be run on the host against FakePin and a fake clock.
+// The pins.go file sets up the pico pins.
 package hbridge
 
 import (
-	"machine"
+	"sync"
 	"time"
-	"fmt"
+
+	"github.com/tonygilkerson/mbx-iot/internal/clock"
 )
 
+// Pin is an output pin, it is implemented by machine.Pin
+type Pin interface {
+	Set(high bool)
+}
+
+// Enable drives the enable pin of a channel at a duty cycle percent, 0 is off.
+// It is implemented by PinEnable and PWMEnable.
+type Enable interface {
+	SetDuty(duty uint8)
+}
+
+// StopMode is what a channel does when it stops
 //
-// Device is a L293D IC
+// Coast
+// Disable the output, the motor spins down on its own and a solenoid is released
 //
-type Device struct {
-	enable machine.Pin
-	in1 machine.Pin
-	in2 machine.Pin
-	lastTurnOnTime time.Time
-	// Currently only using the left channel thus I will not define in3 & in4 until I need them
+// Brake
+// Enable the output with both inputs at the same level, this shorts the motor so it stops fast
+type StopMode int
+
+const (
+	Coast StopMode = iota
+	Brake
+)
+
+// FullSpeed is the duty cycle percent that keeps the enable pin on all the time
+const FullSpeed = 100
+
+// ChannelConfig for one side of the L293D, the left side is EN1,2 IN1 IN2 and the right side is EN3,4 IN3 IN4.
+// The pins must already be configured as outputs.
+type ChannelConfig struct {
+	Enable Enable
+	In1    Pin
+	In2    Pin
+
+	// Pulse is how long TurnOn and TurnOff drive a latching solenoid, defaults to 1 second
+	Pulse time.Duration
+
+	// HoldDuty is the duty cycle percent used after a TurnOn pulse to keep a non-latching
+	// solenoid pulled in with less current, 0 stops the channel after the pulse
+	HoldDuty uint8
+
+	Stop StopMode
+
+	// Clock times the pulses and timestamps the on and off times, defaults to the system clock.
+	// Use the node's synced clock so the times are wall clock times.
+	Clock clock.Clock
+}
+
+// Stats is the run time accounting of a channel.
+//
+// The channel is on from TurnOn, Forward or Reverse until TurnOff or Stop. For a latching
+// solenoid that is the time the valve is open, not the length of the pulse.
+type Stats struct {
+	On          bool
+	LastTurnOn  time.Time
+	LastTurnOff time.Time
+
+	// OnTime is the total time on including the current run
+	OnTime time.Duration
+
+	// Cycles counts the completed on and off cycles
+	Cycles int
+}
+
+// Channel is one side of the L293D
+type Channel struct {
+	mu  sync.Mutex
+	cfg ChannelConfig
+
+	pulse int // incremented for each pulse so a stale timer does not end a newer pulse
+
+	on              bool
+	lastTurnOnTime  time.Time
+	lastTurnOffTime time.Time
+	onTime          time.Duration
+	cycles          int
+}
+
+// NewChannel creates one side of the L293D, the channel starts stopped
+func NewChannel(cfg ChannelConfig) *Channel {
+
+	if cfg.Pulse == 0 {
+		cfg.Pulse = time.Second
+	}
+
+	if cfg.Clock == nil {
+		cfg.Clock = clock.System{}
+	}
+
+	c := &Channel{cfg: cfg}
+	c.stop()
+	return c
+}
+
+// Forward runs a motor CW at the duty cycle percent until it is stopped
+func (c *Channel) Forward(duty uint8) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cancel()
+	c.cw(duty)
+	c.turnedOn(c.cfg.Clock.Now())
+}
+
+// Reverse runs a motor CCW at the duty cycle percent until it is stopped
+func (c *Channel) Reverse(duty uint8) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cancel()
+	c.ccw(duty)
+	c.turnedOn(c.cfg.Clock.Now())
+}
+
+// Stop brakes or coasts depending on the StopMode
+func (c *Channel) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cancel()
+	c.stop()
+	c.turnedOff(c.cfg.Clock.Now())
+}
+
+// TurnOn means push solenoid rod or rotate motor CW for the pulse duration.
+// It returns right away, the pulse is ended in the background.
+func (c *Channel) TurnOn() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cancel()
+	c.cw(FullSpeed)
+	c.turnedOn(c.cfg.Clock.Now())
+
+	c.endPulse(func() {
+		if c.cfg.HoldDuty > 0 {
+			c.cw(c.cfg.HoldDuty)
+		} else {
+			c.stop()
+		}
+	})
+}
+
+// TurnOff means pull solenoid rod or rotate motor CCW for the pulse duration.
+// It returns right away, the pulse is ended in the background.
+func (c *Channel) TurnOff() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cancel()
+	c.ccw(FullSpeed)
+	c.turnedOff(c.cfg.Clock.Now())
+
+	c.endPulse(c.stop)
 }
 
-// New creates an instance of a L293D device
-func New(enable machine.Pin, in1 machine.Pin, in2 machine.Pin) *Device {
+// Stats returns the run time accounting as of now
+func (c *Channel) Stats(now time.Time) Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := Stats{
+		On:          c.on,
+		LastTurnOn:  c.lastTurnOnTime,
+		LastTurnOff: c.lastTurnOffTime,
+		OnTime:      c.onTime,
+		Cycles:      c.cycles,
+	}
+	if c.on {
+		stats.OnTime += now.Sub(c.lastTurnOnTime)
+	}
+	return stats
+}
 
-	enable.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	in1.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	in2.Configure(machine.PinConfig{Mode: machine.PinOutput})
+// ClockJumped keeps the on and off times right when the clock is set
+func (c *Channel) ClockJumped(jump time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	return &Device{
-		enable: enable,
-		in1: in1,
-		in2: in2,
-		lastTurnOnTime: time.Now(),
+	if !c.lastTurnOnTime.IsZero() {
+		c.lastTurnOnTime = c.lastTurnOnTime.Add(jump)
+	}
+	if !c.lastTurnOffTime.IsZero() {
+		c.lastTurnOffTime = c.lastTurnOffTime.Add(jump)
 	}
 }
 
-// Off turns off power, does not push or pull and the motor does not rotate
-func (d *Device) Off(){
-	d.enable.Low()
-	d.in1.Low()
-	d.in2.Low()
+// SinceTurnOn returns the time since the last TurnOn, ok is false if it has never been turned on
+func (c *Channel) SinceTurnOn(now time.Time) (age time.Duration, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lastTurnOnTime.IsZero() {
+		return 0, false
+	}
+	return now.Sub(c.lastTurnOnTime), true
 }
 
-// TurnOn means push solenoid rod or rotate motor CW
-func (d *Device) TurnOn(){
-	d.cw()
-	time.Sleep(time.Duration(time.Second * 1))
-	d.Off()
+// turnedOn starts a run, turning on while already on continues the same run
+func (c *Channel) turnedOn(now time.Time) {
+	if c.on {
+		return
+	}
+	c.on = true
+	c.lastTurnOnTime = now
 }
 
-// TurnOn means pull solenoid rod or rotate motor CCW
-func (d *Device) TurnOff(){
-	d.ccw()
-	time.Sleep(time.Duration(time.Second * 1))
-	d.Off()
+// turnedOff ends a run and counts the cycle
+func (c *Channel) turnedOff(now time.Time) {
+	c.lastTurnOffTime = now
+	if !c.on {
+		return
+	}
+	c.on = false
+	c.onTime += now.Sub(c.lastTurnOnTime)
+	c.cycles++
+}
+
+// endPulse calls end once the pulse duration has passed unless the pulse is cancelled first
+func (c *Channel) endPulse(end func()) {
+
+	pulse := c.pulse
+	after := c.cfg.Clock.After(c.cfg.Pulse)
+	go func() {
+		<-after
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if pulse != c.pulse {
+			return
+		}
+		end()
+	}()
+}
+
+// cancel makes a pending end of pulse do nothing
+func (c *Channel) cancel() {
+	c.pulse++
 }
 
 // cw means push solenoid rod or rotate motor CW
-func (d *Device) cw(){
-	d.enable.High()
-	d.in1.High()
-	d.in2.Low()
+func (c *Channel) cw(duty uint8) {
+	c.cfg.In1.Set(true)
+	c.cfg.In2.Set(false)
+	c.enable(duty)
 }
 
 // ccw means pull solenoid rod or rotate motor CCW
-func (d *Device) ccw(){
-	d.enable.High()
-	d.in1.Low()
-	d.in2.High()
+func (c *Channel) ccw(duty uint8) {
+	c.cfg.In1.Set(false)
+	c.cfg.In2.Set(true)
+	c.enable(duty)
+}
+
+func (c *Channel) stop() {
+	c.cfg.In1.Set(false)
+	c.cfg.In2.Set(false)
+
+	if c.cfg.Stop == Brake {
+		c.enable(FullSpeed)
+	} else {
+		c.enable(0)
+	}
+}
+
+// enable sets the enable pin duty cycle percent
+func (c *Channel) enable(duty uint8) {
+
+	if duty > FullSpeed {
+		duty = FullSpeed
+	}
+
+	c.cfg.Enable.SetDuty(duty)
+}
+
+// Device is a L293D IC, the Left channel is always set, Right is nil unless both channels are used
+type Device struct {
+	Left  *Channel
+	Right *Channel
+}
+
+// NewDual creates an instance of a L293D device using both channels
+func NewDual(left ChannelConfig, right ChannelConfig) *Device {
+	return &Device{Left: NewChannel(left), Right: NewChannel(right)}
 }
 
-// GetTurnOnAge returns the age since last TurnOn as a HH.hh string where hh is a fraction of an hour
-// for example, 3.5 is 3 hours and 30 minutes
-func (d *Device) GetTurnOnAge() string {
+// Off stops both channels
+func (d *Device) Off() {
+	d.Left.Stop()
+	if d.Right != nil {
+		d.Right.Stop()
+	}
+}
 
-	duration := time.Since(d.lastTurnOnTime)
-  age := fmt.Sprintf("%0.2f", duration.Hours())
-	return age
+// TurnOn pulses the left channel, see Channel.TurnOn
+func (d *Device) TurnOn() {
+	d.Left.TurnOn()
+}
+
+// TurnOff pulses the left channel, see Channel.TurnOff
+func (d *Device) TurnOff() {
+	d.Left.TurnOff()
+}
+
+// Open opens a latching solenoid valve, it implements water.Valve
+func (d *Device) Open() {
+	d.TurnOn()
+}
+
+// Close closes a latching solenoid valve, it implements water.Valve
+func (d *Device) Close() {
+	d.TurnOff()
+}
+
+// Stats returns the left channel run time accounting, see Channel.Stats
+func (d *Device) Stats(now time.Time) Stats {
+	return d.Left.Stats(now)
+}
+
+// ClockJumped keeps the on and off times of both channels right when the clock is set
+func (d *Device) ClockJumped(jump time.Duration) {
+	d.Left.ClockJumped(jump)
+	if d.Right != nil {
+		d.Right.ClockJumped(jump)
+	}
+}
 
+// SinceTurnOn returns the time since the left channel was last turned on, see Channel.SinceTurnOn
+func (d *Device) SinceTurnOn(now time.Time) (age time.Duration, ok bool) {
+	return d.Left.SinceTurnOn(now)
 }
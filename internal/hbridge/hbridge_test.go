@@ -0,0 +1,93 @@
+package hbridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tonygilkerson/mbx-iot/internal/clock"
+)
+
+type pins struct {
+	enable   *FakeEnable
+	in1, in2 *FakePin
+}
+
+// channel returns a channel on fake pins run by the fake clock
+func channel(clk clock.Clock, hold uint8, stop StopMode) (*Channel, pins) {
+
+	p := pins{enable: &FakeEnable{}, in1: &FakePin{}, in2: &FakePin{}}
+	c := NewChannel(ChannelConfig{
+		Enable:   p.enable,
+		In1:      p.in1,
+		In2:      p.in2,
+		Pulse:    time.Second,
+		HoldDuty: hold,
+		Stop:     stop,
+		Clock:    clk,
+	})
+
+	return c, p
+}
+
+// state returns the pins as in1, in2 and the enable duty
+func (p pins) state() (bool, bool, uint8) {
+	return p.in1.IsHigh(), p.in2.IsHigh(), p.enable.Duty()
+}
+
+// waitFor waits for the end of a pulse, it runs on its own goroutine once the fake clock fires
+func waitFor(t *testing.T, what string, p pins, in1, in2 bool, duty uint8) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if a, b, d := p.state(); a == in1 && b == in2 && d == duty {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	a, b, d := p.state()
+	t.Fatalf("%v: in1 %v in2 %v duty %v, want %v %v %v", what, a, b, d, in1, in2, duty)
+}
+
+func TestPulse(t *testing.T) {
+
+	clk := clock.NewFake(time.Unix(0, 0))
+	c, p := channel(clk, 0, Coast)
+	waitFor(t, "new channel is stopped", p, false, false, 0)
+
+	c.TurnOn()
+	waitFor(t, "turn on", p, true, false, FullSpeed)
+
+	clk.Advance(time.Millisecond * 999)
+	if _, _, duty := p.state(); duty != FullSpeed {
+		t.Fatalf("duty = %v before the pulse ended", duty)
+	}
+
+	clk.Advance(time.Millisecond)
+	waitFor(t, "end of the on pulse", p, false, false, 0)
+
+	c.TurnOff()
+	waitFor(t, "turn off", p, false, true, FullSpeed)
+	clk.Advance(time.Second)
+	waitFor(t, "end of the off pulse", p, false, false, 0)
+}
+
+func TestHoldAndBrake(t *testing.T) {
+
+	clk := clock.NewFake(time.Unix(0, 0))
+	c, p := channel(clk, 30, Brake)
+
+	// A brake stop shorts the motor with the output enabled
+	waitFor(t, "brake", p, false, false, FullSpeed)
+
+	// A non-latching solenoid is held at the hold duty after the pulse
+	c.TurnOn()
+	clk.Advance(time.Second)
+	waitFor(t, "hold", p, true, false, 30)
+
+	c.Forward(120)
+	waitFor(t, "forward is capped at full speed", p, true, false, FullSpeed)
+	c.Reverse(50)
+	waitFor(t, "reverse", p, false, true, 50)
+}
@@ -0,0 +1,77 @@
+//go:build tinygo
+
+package hbridge
+
+import (
+	"fmt"
+	"machine"
+
+	"github.com/tonygilkerson/mbx-iot/internal/clock"
+)
+
+// PWM is a PWM slice driving an enable pin, it is implemented by machine.PWM0 ... machine.PWM7
+type PWM interface {
+	Configure(config machine.PWMConfig) error
+	Channel(pin machine.Pin) (uint8, error)
+	Top() uint32
+	Set(channel uint8, value uint32)
+}
+
+// PinEnable is an enable pin that is simply on or off, any duty over 0 is on
+type PinEnable machine.Pin
+
+// NewPinEnable configures the enable pin as an output
+func NewPinEnable(pin machine.Pin) PinEnable {
+	pin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	return PinEnable(pin)
+}
+
+func (e PinEnable) SetDuty(duty uint8) {
+	machine.Pin(e).Set(duty > 0)
+}
+
+// PWMEnable drives the enable pin with PWM so a motor can run slower or a solenoid can be held with less current
+type PWMEnable struct {
+	pwm     PWM
+	channel uint8
+}
+
+// NewPWMEnable configures the enable pin for PWM
+func NewPWMEnable(pwm PWM, pin machine.Pin) (*PWMEnable, error) {
+
+	err := pwm.Configure(machine.PWMConfig{})
+	if err != nil {
+		return nil, fmt.Errorf("hbridge: configure pwm: %w", err)
+	}
+
+	channel, err := pwm.Channel(pin)
+	if err != nil {
+		return nil, fmt.Errorf("hbridge: pwm channel: %w", err)
+	}
+
+	return &PWMEnable{pwm: pwm, channel: channel}, nil
+}
+
+func (e *PWMEnable) SetDuty(duty uint8) {
+	e.pwm.Set(e.channel, e.pwm.Top()*uint32(duty)/FullSpeed)
+}
+
+// Output configures a pin as an output for In1 or In2
+func Output(pin machine.Pin) machine.Pin {
+	pin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	return pin
+}
+
+// New creates an instance of a L293D device using only the left channel with the enable pin full on,
+// clk times the pulses and timestamps the on and off times, nil uses the system clock
+func New(enable machine.Pin, in1 machine.Pin, in2 machine.Pin, clk clock.Clock) *Device {
+
+	left := NewChannel(ChannelConfig{
+		Enable: NewPinEnable(enable),
+		In1:    Output(in1),
+		In2:    Output(in2),
+		Clock:  clk,
+	})
+
+	return &Device{Left: left}
+}
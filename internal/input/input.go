@@ -0,0 +1,198 @@
+// Package input turns a noisy digital input, such as a reed switch, vibration sensor or
+// button, into clean open/close events.
+//
+// The Debouncer itself does not touch any hardware, it is fed level samples along with
+// the time they were taken. Run wires it to a pin read function and an interrupt channel.
+package input
+
+import (
+	"time"
+)
+
+// EventKind is the type of input event
+type EventKind int
+
+// Opened
+// The input went active and stayed active for the debounce and hold time
+//
+// Closed
+// The input went inactive after being opened, Duration is how long it was open
+//
+// LeftOpen
+// The input has been open longer than the stuck open time, Duration is how long it has been open.
+// It is sent once per opening.
+const (
+	Opened EventKind = iota
+	Closed
+	LeftOpen
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case Opened:
+		return "Opened"
+	case Closed:
+		return "Closed"
+	case LeftOpen:
+		return "LeftOpen"
+	default:
+		return "Unknown"
+	}
+}
+
+// Event is a debounced change of the input
+type Event struct {
+	Kind     EventKind
+	At       time.Time
+	Duration time.Duration
+}
+
+// Config for a Debouncer
+type Config struct {
+	// The raw level must be stable for this long before a change is accepted
+	Debounce time.Duration
+
+	// The input must be active for at least this long to count as opened,
+	// this filters out short blips such as vibration. If less than Debounce, Debounce is used.
+	Hold time.Duration
+
+	// Send a LeftOpen event once the input has been open this long, 0 disables
+	StuckOpen time.Duration
+
+	// How often to sample the input while a change is pending, 0 defaults to 10ms
+	Poll time.Duration
+}
+
+// Debouncer tracks the debounced state of one input
+type Debouncer struct {
+	cfg Config
+
+	raw      bool
+	rawSince time.Time
+
+	open         bool
+	openedAt     time.Time
+	leftOpenSent bool
+}
+
+// New creates a debouncer, the input is assumed to be inactive as of now
+func New(cfg Config, now time.Time) *Debouncer {
+
+	if cfg.Hold < cfg.Debounce {
+		cfg.Hold = cfg.Debounce
+	}
+
+	if cfg.Poll == 0 {
+		cfg.Poll = time.Millisecond * 10
+	}
+
+	return &Debouncer{
+		cfg:      cfg,
+		rawSince: now,
+	}
+}
+
+// IsOpen returns the debounced state
+func (d *Debouncer) IsOpen() bool {
+	return d.open
+}
+
+// Pending returns true if the debouncer needs to be sampled again to settle because a change has not been accepted yet
+func (d *Debouncer) Pending() bool {
+	return d.raw != d.open
+}
+
+// LeftOpenAt returns when LeftOpen is due, ok is false if the input is closed or LeftOpen was already sent
+func (d *Debouncer) LeftOpenAt() (at time.Time, ok bool) {
+
+	if !d.open || d.cfg.StuckOpen == 0 || d.leftOpenSent {
+		return at, false
+	}
+
+	return d.openedAt.Add(d.cfg.StuckOpen), true
+}
+
+// SettleAt returns when a pending change is accepted if the level stays the same, ok is false if nothing is pending
+func (d *Debouncer) SettleAt() (at time.Time, ok bool) {
+
+	switch {
+	case d.raw && !d.open:
+		return d.rawSince.Add(d.cfg.Hold), true
+	case !d.raw && d.open:
+		return d.rawSince.Add(d.cfg.Debounce), true
+	default:
+		return at, false
+	}
+}
+
+// Update feeds the debouncer a level sample and returns any events that resulted
+func (d *Debouncer) Update(active bool, now time.Time) []Event {
+
+	var events []Event
+
+	if active != d.raw {
+		d.raw = active
+		d.rawSince = now
+	}
+
+	stable := now.Sub(d.rawSince)
+
+	switch {
+	case !d.open && d.raw && stable >= d.cfg.Hold:
+		d.open = true
+		d.openedAt = d.rawSince
+		d.leftOpenSent = false
+		events = append(events, Event{Kind: Opened, At: d.openedAt})
+
+	case d.open && !d.raw && stable >= d.cfg.Debounce:
+		d.open = false
+		events = append(events, Event{Kind: Closed, At: d.rawSince, Duration: d.rawSince.Sub(d.openedAt)})
+	}
+
+	if d.open && d.cfg.StuckOpen > 0 && !d.leftOpenSent && now.Sub(d.openedAt) >= d.cfg.StuckOpen {
+		d.leftOpenSent = true
+		events = append(events, Event{Kind: LeftOpen, At: now, Duration: now.Sub(d.openedAt)})
+	}
+
+	return events
+}
+
+// Run samples the input each time something arrives on the edges channel, typically sent from a pin
+// interrupt, and keeps sampling every Poll while a change is pending. While the input is open it
+// sleeps until LeftOpen is due rather than polling. Events are sent to the events channel.
+// Run does not return.
+func (d *Debouncer) Run(level func() bool, edges <-chan string, events chan<- Event) {
+
+	for {
+
+		now := time.Now()
+		for _, e := range d.Update(level(), now) {
+			events <- e
+		}
+
+		if d.Pending() {
+			// Wait for an edge or the next poll
+			select {
+			case <-edges:
+			case <-time.After(d.cfg.Poll):
+			}
+			continue
+		}
+
+		at, ok := d.LeftOpenAt()
+		if !ok {
+			// Nothing to settle so wait for the next edge
+			<-edges
+			continue
+		}
+
+		// Wait for an edge or for LeftOpen to be due
+		timer := time.NewTimer(at.Sub(now))
+		select {
+		case <-edges:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+
+}
@@ -0,0 +1,66 @@
+package input
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeftOpenIsScheduledNotPolled(t *testing.T) {
+
+	start := time.Unix(0, 0)
+	d := New(Config{Debounce: time.Millisecond * 50, StuckOpen: time.Minute * 5}, start)
+
+	d.Update(true, start)
+	if !d.Pending() {
+		t.Fatal("a change that is not accepted yet should be pending")
+	}
+
+	events := d.Update(true, start.Add(time.Millisecond*50))
+	if len(events) != 1 || events[0].Kind != Opened {
+		t.Fatalf("got %v, want Opened", events)
+	}
+
+	// Open and settled, nothing to poll for until LeftOpen is due
+	if d.Pending() {
+		t.Error("an open input that has settled should not be pending")
+	}
+	at, ok := d.LeftOpenAt()
+	if !ok || !at.Equal(start.Add(time.Minute*5)) {
+		t.Fatalf("LeftOpenAt = %v, %v, want 5m after opening", at, ok)
+	}
+
+	events = d.Update(true, at)
+	if len(events) != 1 || events[0].Kind != LeftOpen {
+		t.Fatalf("got %v, want LeftOpen", events)
+	}
+	if _, ok := d.LeftOpenAt(); ok {
+		t.Error("LeftOpen is only sent once per opening")
+	}
+
+	d.Update(false, at.Add(time.Second))
+	events = d.Update(false, at.Add(time.Second+time.Millisecond*50))
+	if len(events) != 1 || events[0].Kind != Closed || events[0].Duration != time.Minute*5+time.Second {
+		t.Fatalf("got %v, want Closed after 5m1s", events)
+	}
+}
+
+func TestBlipIsFiltered(t *testing.T) {
+
+	start := time.Unix(0, 0)
+	d := New(Config{Debounce: time.Millisecond * 10, Hold: time.Millisecond * 200}, start)
+
+	d.Update(true, start)
+	if at, ok := d.SettleAt(); !ok || !at.Equal(start.Add(time.Millisecond*200)) {
+		t.Errorf("SettleAt = %v, %v, want the end of the hold time", at, ok)
+	}
+	d.Update(true, start.Add(time.Millisecond*100))
+	if events := d.Update(false, start.Add(time.Millisecond*150)); len(events) != 0 {
+		t.Fatalf("a blip shorter than Hold should not open, got %v", events)
+	}
+	if d.IsOpen() || d.Pending() {
+		t.Error("the input should be closed and settled")
+	}
+	if _, ok := d.SettleAt(); ok {
+		t.Error("SettleAt ok with nothing pending")
+	}
+}
@@ -0,0 +1,46 @@
+package marquee
+
+import (
+	"fmt"
+)
+
+// FakeDisplay records what is written to it, use it to run the marquee on the host
+type FakeDisplay struct {
+	// Shown is what the display shows now, for example "text:dry ", "number:42" or "clock:01:30"
+	Shown string
+
+	// BrightnessLevel is the last brightness set
+	BrightnessLevel uint8
+
+	// Log is everything shown in order
+	Log []string
+}
+
+func (d *FakeDisplay) ClearDisplay() {
+	d.show("clear")
+}
+
+func (d *FakeDisplay) DisplayText(text []byte) {
+	d.show("text:" + string(text))
+}
+
+func (d *FakeDisplay) DisplayNumber(num int16) {
+	d.show(fmt.Sprintf("number:%v", num))
+}
+
+func (d *FakeDisplay) DisplayClock(num1 uint8, num2 uint8, colon bool) {
+	sep := " "
+	if colon {
+		sep = ":"
+	}
+	d.show(fmt.Sprintf("clock:%02d%v%02d", num1, sep, num2))
+}
+
+func (d *FakeDisplay) Brightness(brightness uint8) {
+	d.BrightnessLevel = brightness
+}
+
+func (d *FakeDisplay) show(s string) {
+	d.Shown = s
+	d.Log = append(d.Log, s)
+}
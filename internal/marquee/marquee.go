@@ -0,0 +1,216 @@
+// Package marquee cycles a set of screens on a small segment display.
+//
+// The display wakes at full brightness, shows each screen for a while, dims after
+// DimAfter and turns off after OffAfter. A wake, for example from a vibration sensor
+// interrupt, shows the first screen right away and starts the idle timers over.
+//
+// Step and Wake take the time from the caller so the screen sequence can be run
+// on the host against a fake display.
+package marquee
+
+import (
+	"sync"
+	"time"
+)
+
+// Display is the segment display the marquee writes to, it is implemented by the tm1637 driver
+type Display interface {
+	ClearDisplay()
+	DisplayText(text []byte)
+	DisplayNumber(num int16)
+	DisplayClock(num1 uint8, num2 uint8, colon bool)
+	Brightness(brightness uint8)
+}
+
+// Screen is one page of the marquee. Show writes the screen to the display,
+// it returns false if it has nothing to show and the screen is skipped.
+type Screen struct {
+	Name string
+	Show func(d Display) bool
+}
+
+// Config for a Marquee
+type Config struct {
+	Screens []Screen
+
+	// How long each screen is shown, defaults to 2 seconds
+	Dwell time.Duration
+
+	// Brightness when awake and after dimming (0-7)
+	Bright uint8
+	Dim    uint8
+
+	// Time since the last wake before the display dims and turns off, 0 never dims or turns off
+	DimAfter time.Duration
+	OffAfter time.Duration
+}
+
+// Marquee shows the screens in turn
+type Marquee struct {
+	mu      sync.Mutex
+	display Display
+	cfg     Config
+
+	on     bool
+	dimmed bool
+	woke   time.Time
+	index  int
+	next   time.Time
+
+	// poke wakes Run when the schedule changes from another goroutine
+	poke chan struct{}
+}
+
+// New creates a marquee, the display starts off
+func New(display Display, cfg Config) *Marquee {
+
+	if cfg.Dwell == 0 {
+		cfg.Dwell = time.Second * 2
+	}
+
+	display.ClearDisplay()
+
+	return &Marquee{
+		display: display,
+		cfg:     cfg,
+		index:   -1,
+		poke:    make(chan struct{}, 1),
+	}
+}
+
+// IsOn returns true while the display is on
+func (m *Marquee) IsOn() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.on
+}
+
+// Wake turns the display on at full brightness and shows the first screen
+func (m *Marquee) Wake(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.on = true
+	m.dimmed = false
+	m.woke = now
+	m.display.Brightness(m.cfg.Bright)
+
+	m.index = -1
+	m.showNext(now)
+	m.signal()
+}
+
+// Show interrupts the screens to show a message, the screens carry on after the dwell time
+func (m *Marquee) Show(text string, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.on = true
+	m.dimmed = false
+	m.woke = now
+	m.display.Brightness(m.cfg.Bright)
+
+	m.display.DisplayText([]byte(text))
+	m.next = now.Add(m.cfg.Dwell)
+	m.signal()
+}
+
+// Step moves to the next screen when it is due, dims and turns off the display when idle
+func (m *Marquee) Step(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.on {
+		return
+	}
+
+	idle := now.Sub(m.woke)
+
+	if m.cfg.OffAfter > 0 && idle >= m.cfg.OffAfter {
+		m.display.ClearDisplay()
+		m.on = false
+		return
+	}
+
+	if m.cfg.DimAfter > 0 && idle >= m.cfg.DimAfter && !m.dimmed {
+		m.display.Brightness(m.cfg.Dim)
+		m.dimmed = true
+	}
+
+	if !now.Before(m.next) {
+		m.showNext(now)
+	}
+}
+
+// Next returns when Step next needs to be called, ok is false while the display is off
+func (m *Marquee) Next() (next time.Time, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.on {
+		return next, false
+	}
+
+	next = m.next
+	if m.cfg.DimAfter > 0 && !m.dimmed {
+		if dim := m.woke.Add(m.cfg.DimAfter); dim.Before(next) {
+			next = dim
+		}
+	}
+	if m.cfg.OffAfter > 0 {
+		if off := m.woke.Add(m.cfg.OffAfter); off.Before(next) {
+			next = off
+		}
+	}
+
+	return next, true
+}
+
+// Run steps the marquee and wakes it each time a value arrives on the wake channel, it does not return
+func (m *Marquee) Run(wake <-chan string) {
+
+	for {
+		var timer <-chan time.Time
+		if next, ok := m.Next(); ok {
+			timer = time.After(next.Sub(time.Now()))
+		}
+
+		select {
+		case <-wake:
+			m.Wake(time.Now())
+		case <-timer:
+			m.Step(time.Now())
+		case <-m.poke:
+		}
+	}
+
+}
+
+// showNext shows the next screen that has something to show
+func (m *Marquee) showNext(now time.Time) {
+
+	m.next = now.Add(m.cfg.Dwell)
+
+	for range m.cfg.Screens {
+		m.index = (m.index + 1) % len(m.cfg.Screens)
+		if m.cfg.Screens[m.index].Show(m.display) {
+			return
+		}
+	}
+
+	// Nothing to show
+	m.display.ClearDisplay()
+}
+
+// signal tells Run the schedule changed
+func (m *Marquee) signal() {
+
+	// Use non-blocking send so if the channel buffer is full,
+	// the value will get dropped instead of crashing the system
+	select {
+	case m.poke <- struct{}{}:
+	default:
+	}
+
+}
@@ -0,0 +1,145 @@
+package marquee
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// text returns a screen that always shows the text
+func text(name string, s string) Screen {
+	return Screen{Name: name, Show: func(d Display) bool {
+		d.DisplayText([]byte(s))
+		return true
+	}}
+}
+
+var testConfig = Config{
+	Dwell:    time.Second * 2,
+	Bright:   7,
+	Dim:      1,
+	DimAfter: time.Second * 10,
+	OffAfter: time.Second * 30,
+}
+
+// step steps the marquee at each time it asks for until it turns off or until is reached
+func step(m *Marquee, until time.Time) {
+	for {
+		next, ok := m.Next()
+		if !ok || next.After(until) {
+			return
+		}
+		m.Step(next)
+	}
+}
+
+func TestScreensCycleAndSkip(t *testing.T) {
+
+	var hasTemp bool
+	d := &FakeDisplay{}
+	cfg := testConfig
+	cfg.Screens = []Screen{
+		text("moisture", "dry "),
+		{Name: "temperature", Show: func(d Display) bool {
+			if !hasTemp {
+				return false
+			}
+			d.DisplayNumber(72)
+			return true
+		}},
+		{Name: "clock", Show: func(d Display) bool {
+			d.DisplayClock(1, 30, true)
+			return true
+		}},
+	}
+	m := New(d, cfg)
+
+	start := time.Unix(0, 0)
+	m.Wake(start)
+	step(m, start.Add(time.Second*4))
+	if want := []string{"clear", "text:dry ", "clock:01:30", "text:dry "}; !reflect.DeepEqual(d.Log, want) {
+		t.Fatalf("shown %v, want %v, the temperature has nothing to show", d.Log, want)
+	}
+
+	hasTemp = true
+	m.Step(start.Add(time.Second * 6))
+	if d.Shown != "number:72" {
+		t.Errorf("shown %v, want the temperature once it is known", d.Shown)
+	}
+}
+
+func TestDimsAndTurnsOff(t *testing.T) {
+
+	d := &FakeDisplay{}
+	cfg := testConfig
+	cfg.Screens = []Screen{text("a", "a"), text("b", "b")}
+	m := New(d, cfg)
+
+	start := time.Unix(0, 0)
+	if m.IsOn() {
+		t.Fatal("the display should start off")
+	}
+	m.Wake(start)
+	if d.BrightnessLevel != 7 {
+		t.Errorf("brightness = %v after wake, want 7", d.BrightnessLevel)
+	}
+
+	step(m, start.Add(time.Second*10))
+	if d.BrightnessLevel != 1 || !m.IsOn() {
+		t.Errorf("brightness = %v on %v at 10s, want dimmed to 1", d.BrightnessLevel, m.IsOn())
+	}
+
+	step(m, start.Add(time.Minute))
+	if m.IsOn() || d.Shown != "clear" {
+		t.Errorf("on %v shown %v after 30s, want off and clear", m.IsOn(), d.Shown)
+	}
+	if _, ok := m.Next(); ok {
+		t.Error("Next should not be ok while the display is off")
+	}
+
+	// A wake shows the first screen at full brightness and starts the timers over
+	m.Wake(start.Add(time.Minute * 2))
+	if d.Shown != "text:a" || d.BrightnessLevel != 7 {
+		t.Errorf("shown %v brightness %v after wake", d.Shown, d.BrightnessLevel)
+	}
+	if next, _ := m.Next(); !next.Equal(start.Add(time.Minute*2 + time.Second*2)) {
+		t.Errorf("Next = %v, want the dwell after the wake", next.Sub(start))
+	}
+}
+
+func TestShowInterrupts(t *testing.T) {
+
+	d := &FakeDisplay{}
+	cfg := testConfig
+	cfg.Screens = []Screen{text("a", "a"), text("b", "b")}
+	m := New(d, cfg)
+
+	start := time.Unix(0, 0)
+	m.Show("cal ", start)
+	if !m.IsOn() || d.Shown != "text:cal " {
+		t.Fatalf("on %v shown %v, want the message", m.IsOn(), d.Shown)
+	}
+
+	// The message stays for the dwell then the screens carry on
+	m.Step(start.Add(time.Second))
+	if d.Shown != "text:cal " {
+		t.Errorf("shown %v before the dwell, want the message", d.Shown)
+	}
+	m.Step(start.Add(time.Second * 2))
+	if d.Shown != "text:a" {
+		t.Errorf("shown %v after the dwell, want the first screen", d.Shown)
+	}
+}
+
+func TestNothingToShow(t *testing.T) {
+
+	d := &FakeDisplay{}
+	cfg := testConfig
+	cfg.Screens = []Screen{{Name: "empty", Show: func(d Display) bool { return false }}}
+	m := New(d, cfg)
+
+	m.Wake(time.Unix(0, 0))
+	if d.Shown != "clear" {
+		t.Errorf("shown %v, want clear when no screen has anything to show", d.Shown)
+	}
+}
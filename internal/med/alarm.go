@@ -0,0 +1,80 @@
+package med
+
+import (
+	"log"
+	"time"
+
+	"tinygo.org/x/drivers/tone"
+)
+
+// tones is one step of a buzzer pattern, a zero note is a rest
+type tones struct {
+	note tone.Note
+	d    time.Duration
+}
+
+// alarmPatterns escalate with the overdue level, the last pattern is used for any higher level
+var alarmPatterns = [][]tones{
+	// 1 - a single chirp
+	{{tone.A5, time.Millisecond * 200}},
+
+	// 2 - three chirps
+	{
+		{tone.A5, time.Millisecond * 200}, {0, time.Millisecond * 200},
+		{tone.A5, time.Millisecond * 200}, {0, time.Millisecond * 200},
+		{tone.A5, time.Millisecond * 200},
+	},
+
+	// 3 - siren
+	{
+		{tone.B5, time.Second / 2}, {tone.A5, time.Second / 2},
+		{tone.B5, time.Second / 2}, {tone.A5, time.Second / 2},
+		{tone.B5, time.Second / 2}, {tone.A5, time.Second / 2},
+	},
+}
+
+// warnPattern is played when a dose is taken early or over the daily max
+var warnPattern = []tones{
+	{tone.C4, time.Millisecond * 150}, {0, time.Millisecond * 100},
+	{tone.C4, time.Millisecond * 150},
+}
+
+// undoPattern is played when the last action is undone
+var undoPattern = []tones{
+	{tone.E5, time.Millisecond * 100}, {tone.C5, time.Millisecond * 200},
+}
+
+// blockPattern is played when a dose is refused
+var blockPattern = []tones{
+	{tone.C3, time.Second},
+}
+
+// play sounds a pattern, it blocks until the pattern is done
+func play(buzzer tone.Speaker, pattern []tones) {
+
+	for _, t := range pattern {
+		if t.note == 0 {
+			buzzer.Stop()
+		} else {
+			buzzer.SetNote(t.note)
+		}
+		time.Sleep(t.d)
+	}
+	buzzer.Stop()
+
+}
+
+// playAlarm sounds the pattern for an overdue level
+func playAlarm(buzzer tone.Speaker, level int) {
+
+	if level < 1 {
+		level = 1
+	}
+	if level > len(alarmPatterns) {
+		level = len(alarmPatterns)
+	}
+
+	log.Printf("med.playAlarm: level %v", level)
+	play(buzzer, alarmPatterns[level-1])
+
+}
@@ -0,0 +1,210 @@
+package dose
+
+import (
+	"errors"
+	"time"
+)
+
+// EntryKind is the kind of action recorded in the dose history
+type EntryKind byte
+
+// Taken
+// A dose was taken at At
+//
+// Adjusted
+// The last dose time was moved from Was to At, consecutive adjustments of a dose are one entry
+const (
+	Taken EntryKind = iota + 1
+	Adjusted
+)
+
+func (k EntryKind) String() string {
+	switch k {
+	case Taken:
+		return "taken"
+	case Adjusted:
+		return "adjusted"
+	default:
+		return "unknown"
+	}
+}
+
+// Entry is one action in the dose history. Edits are recorded as their own entries
+// rather than changing the dose they edit, so every action can be undone.
+type Entry struct {
+	Kind EntryKind
+
+	// At is the dose time after the action
+	At time.Time
+
+	// Was is the dose time before an adjustment
+	Was time.Time
+
+	// Recorded is when the action was taken
+	Recorded time.Time
+}
+
+// Dose is a dose time worked out from the history
+type Dose struct {
+	At       time.Time
+	Adjusted bool
+}
+
+// ErrNothingToUndo is returned by Undo when the history is empty
+var ErrNothingToUndo = errors.New("dose: nothing to undo")
+
+// ErrNoDose is returned when adjusting with no dose in the history
+var ErrNoDose = errors.New("dose: no dose to adjust")
+
+// maxEntries is the most entries kept, the oldest entries are dropped first except for the doses
+// of the last day which the daily max needs
+const maxEntries = 32
+
+// History is a bounded log of the dose actions, oldest first
+type History struct {
+	Entries []Entry
+}
+
+// Take records a dose
+func (h *History) Take(at time.Time, recorded time.Time) {
+	h.add(Entry{Kind: Taken, At: at, Recorded: recorded})
+}
+
+// Adjust moves the last dose time by d. With merge set it is added to the last entry if that is an
+// adjustment, so the repeats of one held adjust button record one entry and one undo takes it all back.
+// Separate presses are separate entries.
+func (h *History) Adjust(d time.Duration, recorded time.Time, merge bool) error {
+
+	doses := h.Doses()
+	if len(doses) == 0 {
+		return ErrNoDose
+	}
+
+	if last, ok := h.Last(); ok && merge && last.Kind == Adjusted {
+		last.At = last.At.Add(d)
+		last.Recorded = recorded
+		h.Entries[len(h.Entries)-1] = last
+		return nil
+	}
+
+	was := doses[len(doses)-1].At
+	h.add(Entry{Kind: Adjusted, At: was.Add(d), Was: was, Recorded: recorded})
+	return nil
+}
+
+// Undo removes the last action and returns it
+func (h *History) Undo() (Entry, error) {
+
+	if len(h.Entries) == 0 {
+		return Entry{}, ErrNothingToUndo
+	}
+
+	last := h.Entries[len(h.Entries)-1]
+	h.Entries = h.Entries[:len(h.Entries)-1]
+	return last, nil
+}
+
+// Last returns the last action, ok is false if the history is empty
+func (h *History) Last() (last Entry, ok bool) {
+	if len(h.Entries) == 0 {
+		return last, false
+	}
+	return h.Entries[len(h.Entries)-1], true
+}
+
+// Doses replays the history and returns the dose times, oldest first
+func (h *History) Doses() []Dose {
+	doses, _ := h.replay()
+	return doses
+}
+
+// replay works out the doses and, for each dose, the index of the entries that make it
+func (h *History) replay() (doses []Dose, entries [][]int) {
+
+	for i, e := range h.Entries {
+		switch {
+		case e.Kind == Taken:
+			doses = append(doses, Dose{At: e.At})
+			entries = append(entries, []int{i})
+		case e.Kind == Adjusted && len(doses) > 0 && doses[len(doses)-1].At.Equal(e.Was):
+			doses[len(doses)-1] = Dose{At: e.At, Adjusted: true}
+			entries[len(entries)-1] = append(entries[len(entries)-1], i)
+		case e.Kind == Adjusted:
+			// The dose it adjusted has been dropped from the history
+			doses = append(doses, Dose{At: e.At, Adjusted: true})
+			entries = append(entries, []int{i})
+		}
+	}
+	return doses, entries
+}
+
+// DoseTimes returns just the times of Doses
+func (h *History) DoseTimes() []time.Time {
+
+	var times []time.Time
+	for _, d := range h.Doses() {
+		times = append(times, d.At)
+	}
+	return times
+}
+
+// Since returns the doses after since, oldest first
+func (h *History) Since(since time.Time) []Dose {
+
+	var doses []Dose
+	for _, d := range h.Doses() {
+		if d.At.After(since) {
+			doses = append(doses, d)
+		}
+	}
+	return doses
+}
+
+// Shift moves the entries recorded from from to to by d. When the clock is set after boot it keeps
+// the entries recorded with the unset clock right, the entries restored from flash are outside the range.
+func (h *History) Shift(from time.Time, to time.Time, d time.Duration) {
+
+	for i, e := range h.Entries {
+		if e.Recorded.Before(from) || e.Recorded.After(to) {
+			continue
+		}
+		h.Entries[i].At = e.At.Add(d)
+		if !e.Was.IsZero() {
+			h.Entries[i].Was = e.Was.Add(d)
+		}
+		h.Entries[i].Recorded = e.Recorded.Add(d)
+	}
+}
+
+func (h *History) add(e Entry) {
+	h.Entries = append(h.Entries, e)
+	for len(h.Entries) > maxEntries {
+		h.drop(h.oldest(e.Recorded))
+	}
+}
+
+// oldest returns the index of the oldest entry that can be dropped as of now. The entries of the
+// doses in the last day are kept, if there is nothing else the oldest entry goes anyway.
+func (h *History) oldest(now time.Time) int {
+
+	keep := make([]bool, len(h.Entries))
+	doses, entries := h.replay()
+	for j, d := range doses {
+		if d.At.After(now.Add(-day)) {
+			for _, i := range entries[j] {
+				keep[i] = true
+			}
+		}
+	}
+
+	for i := range h.Entries {
+		if !keep[i] {
+			return i
+		}
+	}
+	return 0
+}
+
+func (h *History) drop(i int) {
+	h.Entries = append(h.Entries[:i], h.Entries[i+1:]...)
+}
@@ -0,0 +1,115 @@
+package dose
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistoryShift(t *testing.T) {
+
+	// A dose restored from flash and one taken since boot with the clock not set yet
+	restored := time.Unix(1_700_000_000, 0)
+	boot := time.Unix(0, 0)
+
+	var h History
+	h.Take(restored, restored)
+	h.Take(boot.Add(time.Hour), boot.Add(time.Hour))
+	h.Adjust(-time.Minute*30, boot.Add(time.Hour+time.Minute), false)
+
+	// The gateway time arrives, the clock jumps forward
+	jump := restored.Add(time.Hour * 8).Sub(boot)
+	h.Shift(boot, boot.Add(time.Hour*2), jump)
+
+	doses := h.Doses()
+	if len(doses) != 2 || !doses[0].At.Equal(restored) {
+		t.Fatalf("doses = %+v, the restored dose should not move", doses)
+	}
+	if want := restored.Add(time.Hour*8 + time.Minute*30); !doses[1].At.Equal(want) || !doses[1].Adjusted {
+		t.Errorf("dose since boot = %+v, want the adjusted dose at %v", doses[1], want)
+	}
+}
+
+func TestHistoryAdjustAndUndo(t *testing.T) {
+
+	var h History
+	if err := h.Adjust(time.Hour, start, false); err != ErrNoDose {
+		t.Errorf("Adjust with no dose err = %v, want ErrNoDose", err)
+	}
+
+	h.Take(start, start)
+
+	// Two separate presses are two entries
+	h.Adjust(-time.Hour, start.Add(time.Minute), false)
+	h.Adjust(time.Minute*30, start.Add(time.Minute*2), false)
+
+	doses := h.Doses()
+	if len(doses) != 1 || !doses[0].At.Equal(start.Add(-time.Minute*30)) || !doses[0].Adjusted {
+		t.Fatalf("doses = %+v, want one adjusted dose 30m before start", doses)
+	}
+	if len(h.Entries) != 3 {
+		t.Fatalf("entries = %+v, want the dose and two adjustments", h.Entries)
+	}
+	if e, err := h.Undo(); err != nil || e.Kind != Adjusted {
+		t.Fatalf("Undo = %+v, %v", e, err)
+	}
+	if last := h.DoseTimes(); !last[0].Equal(start.Add(-time.Hour)) {
+		t.Errorf("after undo dose = %v, want the first adjustment", last[0].Sub(start))
+	}
+
+	// The repeats of one held button are merged into its first adjustment, one undo takes them back
+	h.Adjust(time.Hour, start.Add(time.Minute*3), true)
+	h.Adjust(time.Hour, start.Add(time.Minute*4), true)
+	if len(h.Entries) != 2 {
+		t.Fatalf("entries = %+v, want the dose and one merged adjustment", h.Entries)
+	}
+	if last := h.DoseTimes(); !last[0].Equal(start.Add(time.Hour)) {
+		t.Errorf("after the hold dose = %v, want 1h after start", last[0].Sub(start))
+	}
+	if e, err := h.Undo(); err != nil || e.Kind != Adjusted {
+		t.Fatalf("Undo = %+v, %v", e, err)
+	}
+	if last := h.DoseTimes(); !last[0].Equal(start) {
+		t.Errorf("after undo dose = %v, want start", last[0].Sub(start))
+	}
+
+	// An adjustment after a new dose is its own entry
+	h.Take(start.Add(time.Hour*6), start.Add(time.Hour*6))
+	h.Adjust(time.Minute*30, start.Add(time.Hour*7), false)
+	if len(h.Entries) != 3 {
+		t.Errorf("entries = %+v, want 2 doses and an adjustment", h.Entries)
+	}
+
+	h.Undo()
+	h.Undo()
+	h.Undo()
+	if _, err := h.Undo(); err != ErrNothingToUndo {
+		t.Errorf("Undo of an empty history err = %v, want ErrNothingToUndo", err)
+	}
+}
+
+func TestHistoryKeepsLastDay(t *testing.T) {
+
+	now := start
+	var h History
+	h.Take(now.Add(-time.Hour*20), now.Add(-time.Hour*20))
+	h.Adjust(time.Hour, now.Add(-time.Hour*20), false)
+
+	// Old doses entered later fill the history past maxEntries
+	old := now.Add(-time.Hour * 24 * 10)
+	for i := 0; i < maxEntries+8; i++ {
+		h.Take(old.Add(time.Duration(i)*time.Minute), now)
+	}
+
+	if len(h.Entries) != maxEntries {
+		t.Fatalf("len(Entries) = %v, want %v", len(h.Entries), maxEntries)
+	}
+	if h.Entries[0].Kind != Taken || h.Entries[1].Kind != Adjusted {
+		t.Fatalf("first entries = %+v, want the dose of the last day and its adjustment", h.Entries[:2])
+	}
+	if got := h.Since(now.Add(-day)); len(got) != 1 || !got[0].At.Equal(now.Add(-time.Hour*19)) {
+		t.Errorf("Since a day ago = %+v, want the adjusted dose", got)
+	}
+	if last := h.Entries[len(h.Entries)-1]; !last.At.Equal(old.Add(time.Duration(maxEntries+7) * time.Minute)) {
+		t.Errorf("last entry = %+v, want the newest old dose", last)
+	}
+}
@@ -0,0 +1,195 @@
+// Package dose keeps the dose history and the dosing rules of the med tracker.
+//
+// It has no hardware dependencies, the times come from the caller so the rules can be tested on the host.
+package dose
+
+import (
+	"errors"
+	"time"
+)
+
+const day = time.Hour * 24
+
+// Schedule is the dosing rules
+type Schedule struct {
+	// Interval is the time between doses, the next dose is due Interval after the last one
+	Interval time.Duration
+
+	// OverdueAfter is how long past due before the dose is overdue and the alarm sounds
+	OverdueAfter time.Duration
+
+	// MaxDoses is the most doses allowed in any 24 hours, 0 is no limit
+	MaxDoses int
+
+	// BlockOverMax refuses a dose over MaxDoses, otherwise the dose is recorded with a warning
+	BlockOverMax bool
+
+	// EscalateEvery raises the alarm level each time the dose has been overdue this much longer
+	EscalateEvery time.Duration
+}
+
+var (
+	// ErrTooSoon is returned for a dose taken before the next one is due
+	ErrTooSoon = errors.New("dose: dose taken early")
+
+	// ErrDailyMax is returned for a dose that would be over the max doses in 24 hours
+	ErrDailyMax = errors.New("dose: over the daily max doses")
+)
+
+// DoseState is where the tracker is in the schedule
+type DoseState int
+
+// Unknown
+// The last dose time is unknown, it could not be restored after a reboot
+//
+// Waiting
+// The next dose is not due yet
+//
+// Due
+// The next dose is due
+//
+// Overdue
+// The next dose is due and has been for longer than OverdueAfter
+const (
+	Unknown DoseState = iota
+	Waiting
+	Due
+	Overdue
+)
+
+func (s DoseState) String() string {
+	switch s {
+	case Waiting:
+		return "waiting"
+	case Due:
+		return "due"
+	case Overdue:
+		return "overdue"
+	default:
+		return "unknown"
+	}
+}
+
+// DoseStatus is the schedule as of a point in time
+type DoseStatus struct {
+	State DoseState
+
+	// When the next dose is due
+	NextDue time.Time
+
+	// How long the dose has been overdue and the alarm level, level 1 is the first level
+	Overdue time.Duration
+	Level   int
+
+	// Doses taken in the last 24 hours
+	DosesToday int
+}
+
+// Status returns the schedule status given the dose times, oldest first
+func (s Schedule) Status(doses []time.Time, known bool, now time.Time) DoseStatus {
+
+	if !known || len(doses) == 0 {
+		return DoseStatus{State: Unknown}
+	}
+
+	status := DoseStatus{
+		State:      Waiting,
+		NextDue:    doses[len(doses)-1].Add(s.Interval),
+		DosesToday: dosesSince(doses, now.Add(-day)),
+	}
+
+	// With the daily max the next dose can't be due before the oldest of the last MaxDoses doses is
+	// 24 hours old, the next dose is due from then and not from when it was last due by the interval
+	if s.MaxDoses > 0 && len(doses) >= s.MaxDoses {
+		if free := doses[len(doses)-s.MaxDoses].Add(day); free.After(status.NextDue) {
+			status.NextDue = free
+		}
+	}
+
+	if now.Before(status.NextDue) {
+		return status
+	}
+
+	status.State = Due
+	late := now.Sub(status.NextDue)
+	if late < s.OverdueAfter {
+		return status
+	}
+
+	status.State = Overdue
+	status.Overdue = late - s.OverdueAfter
+	status.Level = 1
+	if s.EscalateEvery > 0 {
+		status.Level += int(status.Overdue / s.EscalateEvery)
+	}
+
+	return status
+}
+
+// Check returns an error if a dose taken now breaks the rules. ErrDailyMax is always returned
+// over the max, the caller refuses the dose if BlockOverMax is set and warns otherwise.
+func (s Schedule) Check(doses []time.Time, known bool, now time.Time) error {
+
+	// Without a known history there is nothing to check against
+	if !known {
+		return nil
+	}
+
+	if s.MaxDoses > 0 && dosesSince(doses, now.Add(-day)) >= s.MaxDoses {
+		return ErrDailyMax
+	}
+
+	if len(doses) > 0 && now.Before(doses[len(doses)-1].Add(s.Interval)) {
+		return ErrTooSoon
+	}
+
+	return nil
+}
+
+// dosesSince counts the doses after since
+func dosesSince(doses []time.Time, since time.Time) int {
+
+	var n int
+	for _, d := range doses {
+		if d.After(since) {
+			n++
+		}
+	}
+	return n
+}
+
+// Alarm decides when to sound the overdue alarm. It sounds once every Repeat while the
+// dose is overdue, Snooze puts it off for a Repeat.
+type Alarm struct {
+	Repeat time.Duration
+
+	next time.Time
+}
+
+// Sound returns true when the alarm should sound now for the status
+func (a *Alarm) Sound(status DoseStatus, now time.Time) bool {
+
+	if status.State != Overdue {
+		a.next = time.Time{}
+		return false
+	}
+
+	if now.Before(a.next) {
+		return false
+	}
+
+	a.next = now.Add(a.Repeat)
+	return true
+}
+
+// Snooze silences the alarm until the next repeat
+func (a *Alarm) Snooze(now time.Time) {
+	a.next = now.Add(a.Repeat)
+}
+
+// Shift moves the next alarm by d when the clock is set
+func (a *Alarm) Shift(d time.Duration) {
+	if !a.next.IsZero() {
+		a.next = a.next.Add(d)
+	}
+}
@@ -0,0 +1,167 @@
+package dose
+
+import (
+	"testing"
+	"time"
+)
+
+var testSchedule = Schedule{
+	Interval:      time.Hour * 6,
+	OverdueAfter:  time.Minute * 15,
+	MaxDoses:      3,
+	BlockOverMax:  true,
+	EscalateEvery: time.Minute * 10,
+}
+
+var start = time.Unix(1_700_000_000, 0)
+
+// at returns times after start
+func at(d ...time.Duration) []time.Time {
+	var times []time.Time
+	for _, x := range d {
+		times = append(times, start.Add(x))
+	}
+	return times
+}
+
+func TestStatus(t *testing.T) {
+
+	h := time.Hour
+	m := time.Minute
+
+	tests := []struct {
+		name    string
+		doses   []time.Time
+		known   bool
+		now     time.Duration
+		state   DoseState
+		nextDue time.Duration
+		overdue time.Duration
+		level   int
+		today   int
+	}{
+		{name: "unknown", doses: at(0), known: false, now: h, state: Unknown},
+		{name: "no doses", known: true, now: h, state: Unknown},
+		{name: "waiting", doses: at(0), known: true, now: h, state: Waiting, nextDue: 6 * h, today: 1},
+		{name: "due", doses: at(0), known: true, now: 6*h + 10*m, state: Due, nextDue: 6 * h, today: 1},
+		{name: "overdue", doses: at(0), known: true, now: 6*h + 15*m, state: Overdue, nextDue: 6 * h, level: 1, today: 1},
+		{name: "escalated", doses: at(0), known: true, now: 6*h + 36*m, state: Overdue, nextDue: 6 * h, overdue: 21 * m, level: 3, today: 1},
+		{name: "day old dose not counted", doses: at(0, 25*h), known: true, now: 26 * h, state: Waiting, nextDue: 31 * h, today: 1},
+
+		// With the daily max used up the next dose waits for the oldest of the day to be 24 hours old
+		{name: "daily max", doses: at(0, 6*h, 12*h), known: true, now: 13 * h, state: Waiting, nextDue: 24 * h, today: 3},
+		{name: "daily max due", doses: at(0, 6*h, 12*h), known: true, now: 24*h + 5*m, state: Due, nextDue: 24 * h, today: 2},
+		{name: "daily max later than interval", doses: at(0, 2*h, 4*h), known: true, now: 11 * h, state: Waiting, nextDue: 24 * h, today: 3},
+	}
+
+	for _, tt := range tests {
+		got := testSchedule.Status(tt.doses, tt.known, start.Add(tt.now))
+
+		if got.State != tt.state {
+			t.Errorf("%v: state = %v, want %v", tt.name, got.State, tt.state)
+			continue
+		}
+		if tt.state == Unknown {
+			continue
+		}
+		if !got.NextDue.Equal(start.Add(tt.nextDue)) {
+			t.Errorf("%v: next due = %v, want %v", tt.name, got.NextDue.Sub(start), tt.nextDue)
+		}
+		if got.Overdue != tt.overdue || got.Level != tt.level {
+			t.Errorf("%v: overdue %v level %v, want %v level %v", tt.name, got.Overdue, got.Level, tt.overdue, tt.level)
+		}
+		if got.DosesToday != tt.today {
+			t.Errorf("%v: doses today = %v, want %v", tt.name, got.DosesToday, tt.today)
+		}
+	}
+}
+
+func TestEscalationLevels(t *testing.T) {
+
+	due := start.Add(time.Hour * 6)
+	want := []struct {
+		late  time.Duration
+		level int
+	}{
+		{late: time.Minute * 15, level: 1},
+		{late: time.Minute * 24, level: 1},
+		{late: time.Minute * 25, level: 2},
+		{late: time.Minute * 35, level: 3},
+		{late: time.Hour * 2, level: 11},
+	}
+
+	for _, w := range want {
+		if got := testSchedule.Status(at(0), true, due.Add(w.late)); got.Level != w.level {
+			t.Errorf("%v late: level = %v, want %v", w.late, got.Level, w.level)
+		}
+	}
+
+	// Without EscalateEvery the level stays at 1
+	s := testSchedule
+	s.EscalateEvery = 0
+	if got := s.Status(at(0), true, due.Add(time.Hour*5)); got.Level != 1 {
+		t.Errorf("level without escalation = %v, want 1", got.Level)
+	}
+}
+
+func TestCheck(t *testing.T) {
+
+	h := time.Hour
+
+	tests := []struct {
+		name  string
+		doses []time.Time
+		known bool
+		now   time.Duration
+		err   error
+	}{
+		{name: "first dose", known: true, now: 0},
+		{name: "unknown history", doses: at(0), known: false, now: h},
+		{name: "on time", doses: at(0), known: true, now: 6 * h},
+		{name: "too soon", doses: at(0), known: true, now: 5 * h, err: ErrTooSoon},
+		{name: "daily max", doses: at(0, 6*h, 12*h), known: true, now: 18 * h, err: ErrDailyMax},
+		{name: "max checked before too soon", doses: at(0, 6*h, 12*h), known: true, now: 13 * h, err: ErrDailyMax},
+		{name: "oldest dose aged out", doses: at(0, 6*h, 12*h), known: true, now: 24*h + time.Second},
+	}
+
+	for _, tt := range tests {
+		if err := testSchedule.Check(tt.doses, tt.known, start.Add(tt.now)); err != tt.err {
+			t.Errorf("%v: err = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	// No limit
+	s := testSchedule
+	s.MaxDoses = 0
+	if err := s.Check(at(0, 6*h, 12*h), true, start.Add(18*h)); err != nil {
+		t.Errorf("no daily max: err = %v", err)
+	}
+}
+
+func TestAlarm(t *testing.T) {
+
+	a := Alarm{Repeat: time.Minute * 10}
+	overdue := DoseStatus{State: Overdue}
+	now := start
+
+	if !a.Sound(overdue, now) {
+		t.Fatal("the alarm should sound when the dose first becomes overdue")
+	}
+	if a.Sound(overdue, now.Add(time.Minute*9)) {
+		t.Error("the alarm should wait for the repeat")
+	}
+	if !a.Sound(overdue, now.Add(time.Minute*10)) {
+		t.Error("the alarm should sound again after the repeat")
+	}
+
+	a.Snooze(now.Add(time.Minute * 12))
+	if a.Sound(overdue, now.Add(time.Minute*21)) {
+		t.Error("a snooze puts off the alarm for a repeat")
+	}
+
+	// Taking the dose resets it
+	a.Sound(DoseStatus{State: Waiting}, now.Add(time.Minute*22))
+	if !a.Sound(overdue, now.Add(time.Minute*23)) {
+		t.Error("the alarm should sound right away the next time the dose is overdue")
+	}
+}
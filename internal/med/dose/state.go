@@ -0,0 +1,113 @@
+package dose
+
+import (
+	"encoding/binary"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/tonygilkerson/mbx-iot/internal/store"
+)
+
+// State is the med tracker state saved to flash
+type State struct {
+	History History
+
+	// Known is false when the dose history could not be restored, the tracker shows "unknown"
+	// until the took meds button is pressed rather than guessing
+	Known bool
+}
+
+// LastTaken returns the last dose time, ok is false if it is not known
+func (s State) LastTaken() (lastTaken time.Time, ok bool) {
+
+	doses := s.History.Doses()
+	if !s.Known || len(doses) == 0 {
+		return lastTaken, false
+	}
+	return doses[len(doses)-1].At, true
+}
+
+// ErrBadState is returned when the saved state can't be decoded
+var ErrBadState = errors.New("dose: bad saved state")
+
+// entrySize is kind + unix seconds of at, was and recorded
+const entrySize = 1 + 8*3
+
+// encodeState packs the state as known, count, then each entry
+func encodeState(s State) []byte {
+
+	entries := s.History.Entries
+	buf := make([]byte, 2+entrySize*len(entries))
+	if s.Known {
+		buf[0] = 1
+	}
+	buf[1] = byte(len(entries))
+	for i, e := range entries {
+		b := buf[2+i*entrySize:]
+		b[0] = byte(e.Kind)
+		binary.LittleEndian.PutUint64(b[1:], uint64(e.At.Unix()))
+		binary.LittleEndian.PutUint64(b[9:], uint64(e.Was.Unix()))
+		binary.LittleEndian.PutUint64(b[17:], uint64(e.Recorded.Unix()))
+	}
+	return buf
+}
+
+func decodeState(buf []byte) (State, error) {
+
+	if len(buf) < 2 || len(buf) != 2+entrySize*int(buf[1]) {
+		return State{}, ErrBadState
+	}
+
+	s := State{Known: buf[0] == 1}
+	for i := 0; i < int(buf[1]); i++ {
+		b := buf[2+i*entrySize:]
+		s.History.Entries = append(s.History.Entries, Entry{
+			Kind:     EntryKind(b[0]),
+			At:       time.Unix(int64(binary.LittleEndian.Uint64(b[1:])), 0),
+			Was:      time.Unix(int64(binary.LittleEndian.Uint64(b[9:])), 0),
+			Recorded: time.Unix(int64(binary.LittleEndian.Uint64(b[17:])), 0),
+		})
+	}
+	return s, nil
+}
+
+// RestoreState loads the saved state.
+//
+// The saved times are only trusted when clockValid is true, meaning the clock has been set from a
+// real time source since boot (see clock.Synced.IsSet), and they are not in the future. Otherwise the pico
+// clock restarted at boot and the age of the saved times is meaningless so the state is unknown.
+func RestoreState(record *store.Record, now time.Time, clockValid bool) State {
+
+	buf := make([]byte, 2+entrySize*maxEntries)
+	n, err := record.Load(buf)
+	if err != nil {
+		log.Printf("dose.RestoreState: no saved state: %v", err)
+		return State{}
+	}
+
+	s, err := decodeState(buf[:n])
+	if err != nil {
+		log.Printf("dose.RestoreState: %v", err)
+		return State{}
+	}
+
+	last, _ := s.LastTaken()
+	switch {
+	case !s.Known:
+		log.Println("dose.RestoreState: saved state is unknown")
+	case !clockValid:
+		log.Printf("dose.RestoreState: clock not set, can't trust saved time %v", last)
+		s.Known = false
+	case last.After(now):
+		log.Printf("dose.RestoreState: saved time %v is in the future", last)
+		s.Known = false
+	}
+
+	return s
+}
+
+// SaveState saves the state
+func SaveState(record *store.Record, s State) error {
+	return record.Save(encodeState(s))
+}
@@ -0,0 +1,94 @@
+package dose
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tonygilkerson/mbx-iot/internal/store"
+)
+
+// record returns a record on a file backed device
+func record(t *testing.T) *store.Record {
+
+	dev, err := store.OpenFile(filepath.Join(t.TempDir(), "flash"), 4096, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dev.Close() })
+
+	r, err := store.NewRecord(dev, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRestoreState(t *testing.T) {
+
+	now := time.Unix(1_700_000_000, 0)
+	taken := now.Add(-time.Hour * 3)
+
+	var saved State
+	saved.Known = true
+	saved.History.Take(taken, taken)
+	saved.History.Adjust(-time.Minute*30, taken.Add(time.Minute), false)
+
+	tests := []struct {
+		name       string
+		save       *State
+		now        time.Time
+		clockValid bool
+		wantKnown  bool
+	}{
+		{name: "restored", save: &saved, now: now, clockValid: true, wantKnown: true},
+		{name: "nothing saved", now: now, clockValid: true},
+		{name: "saved unknown", save: &State{}, now: now, clockValid: true},
+		{name: "clock not set", save: &saved, now: now, clockValid: false},
+		{name: "saved time in the future", save: &saved, now: taken.Add(-time.Hour), clockValid: true},
+	}
+
+	for _, tt := range tests {
+		r := record(t)
+		if tt.save != nil {
+			if err := SaveState(r, *tt.save); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		s := RestoreState(r, tt.now, tt.clockValid)
+		if s.Known != tt.wantKnown {
+			t.Errorf("%v: Known = %v, want %v", tt.name, s.Known, tt.wantKnown)
+		}
+		if _, ok := s.LastTaken(); ok != tt.wantKnown {
+			t.Errorf("%v: LastTaken ok = %v, want %v", tt.name, ok, tt.wantKnown)
+		}
+	}
+
+	// The restored history is the saved one, adjustment included
+	s := RestoreState(saveTo(t, saved), now, true)
+	if last, _ := s.LastTaken(); !last.Equal(taken.Add(-time.Minute * 30)) {
+		t.Errorf("LastTaken = %v, want the adjusted dose %v", last, taken.Add(-time.Minute*30))
+	}
+	if len(s.History.Entries) != 2 || s.History.Entries[1].Kind != Adjusted || !s.History.Entries[1].Was.Equal(taken) {
+		t.Errorf("entries = %+v", s.History.Entries)
+	}
+}
+
+func TestDecodeBadState(t *testing.T) {
+
+	for _, buf := range [][]byte{nil, {1}, {1, 2, 0}} {
+		if _, err := decodeState(buf); err != ErrBadState {
+			t.Errorf("decodeState(%v) err = %v, want ErrBadState", buf, err)
+		}
+	}
+}
+
+// saveTo saves the state to a new record
+func saveTo(t *testing.T, s State) *store.Record {
+	r := record(t)
+	if err := SaveState(r, s); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
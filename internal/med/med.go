@@ -3,128 +3,407 @@ package med
 import (
 	"log"
 	"machine"
-	"runtime"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/tonygilkerson/mbx-iot/internal/button"
+	"github.com/tonygilkerson/mbx-iot/internal/clock"
+	"github.com/tonygilkerson/mbx-iot/internal/med/dose"
+	"github.com/tonygilkerson/mbx-iot/internal/store"
+	"github.com/tonygilkerson/mbx-iot/pkg/iot"
 	"tinygo.org/x/drivers/tone"
 )
 
+// Button names used in the button events
+const (
+	add1HrButton   = "add1HrButton"
+	sub1HrButton   = "sub1HrButton"
+	add30MButton   = "add30MButton"
+	tookMedsButton = "tookMedsButton"
+)
+
+// Hold the took meds button to undo the last dose or adjustment
+var tookMedsConfig = button.Config{
+	Debounce:  time.Millisecond * 30,
+	LongPress: time.Millisecond * 1500,
+}
+
+// Hold an adjust button to keep adjusting
+var adjustConfig = button.Config{
+	Debounce:    time.Millisecond * 30,
+	RepeatDelay: time.Second,
+	RepeatEvery: time.Millisecond * 700,
+}
+
+// MedTracker keeps the dose history and handles the buttons.
+// The button events are handled on the KeyPressChannelConsumer goroutine while the main loop
+// reads the status, the state is guarded by mu so it is safe to use from both.
 type MedTracker struct {
-	lastTakenMedsAt time.Time
-	add1HrButton    machine.Pin
-	sub1HrButton    machine.Pin
-	add30MButton    machine.Pin
-	tookMedsButton  machine.Pin
-	buzzerPin       machine.Pin
-	buzzer          tone.Speaker
-	chKeyPress      chan string
-	keyPressed      bool
+	mu       sync.Mutex
+	state    dose.State
+	screen   Waker
+	alarm    dose.Alarm
+	warning  string
+	record   *store.Record
+	clock    *clock.Synced
+	booted   time.Time // when the tracker started by the clock, moved with the clock when it is set
+	schedule dose.Schedule
+	txQ      *chan string
+
+	// adjusting is true while an adjust button is held, the adjustment is saved when it is released
+	adjusting bool
+
+	// sound keeps the alarm and the button feedback from playing over each other
+	sound  sync.Mutex
+	buzzer tone.Speaker
+
+	events chan button.Event
 }
 
 func New(
-	add1HrButton machine.Pin,
-	sub1HrButton machine.Pin,
-	add30MButton machine.Pin,
-	tookMedsButton machine.Pin,
+	add1HrPin machine.Pin,
+	sub1HrPin machine.Pin,
+	add30MPin machine.Pin,
+	tookMedsPin machine.Pin,
 	buzzer tone.Speaker,
+	record *store.Record,
+	clk *clock.Synced,
+	schedule dose.Schedule,
 
 ) *MedTracker {
 
 	var mt MedTracker
 
-	mt.lastTakenMedsAt = time.Now()
-	mt.keyPressed = true
+	// Restore the last taken time saved before the power went out, never assume it was just now
+	mt.record = record
+	mt.clock = clk
+	mt.booted = clk.Now()
+	mt.state = dose.RestoreState(record, clk.Now(), clk.IsSet())
+	mt.schedule = schedule
+	mt.alarm = dose.Alarm{Repeat: schedule.EscalateEvery}
+	if mt.alarm.Repeat == 0 {
+		mt.alarm.Repeat = time.Minute * 5
+	}
+	mt.buzzer = buzzer
 
-	// assign channel
-	mt.chKeyPress = make(chan string, 1)
+	// Watch the buttons, the events are handled by KeyPressChannelConsumer
+	mt.events = make(chan button.Event, 4)
+	now := time.Now()
+	button.Watch(add1HrPin, button.New(add1HrButton, adjustConfig, now), mt.events)
+	button.Watch(sub1HrPin, button.New(sub1HrButton, adjustConfig, now), mt.events)
+	button.Watch(add30MPin, button.New(add30MButton, adjustConfig, now), mt.events)
+	button.Watch(tookMedsPin, button.New(tookMedsButton, tookMedsConfig, now), mt.events)
 
-	// assign buttons
-	mt.add1HrButton = add1HrButton
-	mt.sub1HrButton = sub1HrButton
-	mt.add30MButton = add30MButton
-	mt.tookMedsButton = tookMedsButton
-	mt.buzzer = buzzer
+	// return it
+	return &mt
+}
 
-	// configure buttons
-	mt.add1HrButton.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
-	mt.add30MButton.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
-	mt.tookMedsButton.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
-	mt.sub1HrButton.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
-
-	mt.add1HrButton.SetInterrupt(machine.PinFalling, func(p machine.Pin) {
-		// Use non-blocking send so if the channel buffer is full,
-		// the value will get dropped instead of crashing the system
-		select {
-		case mt.chKeyPress <- "add1HrButton":
-		default:
-		}
-	})
+// ClockJumped keeps the doses recorded since boot right when the clock is set, call it with the jump
+// returned by clock.Synced.SetFromMessage. The doses restored from flash were recorded with a set clock.
+func (mt *MedTracker) ClockJumped(jump time.Duration) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
 
-	mt.add30MButton.SetInterrupt(machine.PinFalling, func(p machine.Pin) {
-		select {
-		case mt.chKeyPress <- "add30MButton":
-		default:
-		}
-	})
+	if jump == 0 {
+		return
+	}
 
-	mt.tookMedsButton.SetInterrupt(machine.PinFalling, func(p machine.Pin) {
-		select {
-		case mt.chKeyPress <- "tookMedsButton":
-		default:
-		}
-	})
+	// The clock has already been set, the entries since boot were recorded before the jump
+	now := mt.clock.Now()
+	mt.state.History.Shift(mt.booted, now.Add(-jump), jump)
+	mt.booted = mt.booted.Add(jump)
+	mt.alarm.Shift(jump)
+	mt.save()
+}
 
-	mt.sub1HrButton.SetInterrupt(machine.PinFalling, func(p machine.Pin) {
-		select {
-		case mt.chKeyPress <- "sub1HrButton":
-		default:
-		}
-	})
+// Waker wakes the screen, it is implemented by ui.Backlight
+type Waker interface {
+	// Wake returns true if the screen was off
+	Wake(now time.Time) (wasOff bool)
+}
 
-	// return it
-	return &mt
+// WakeOnKey wakes the screen on each key press, a key press that only wakes the screen is otherwise ignored
+func (mt *MedTracker) WakeOnKey(screen Waker) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	mt.screen = screen
 }
 
-func (mt *MedTracker) CheckIfKeyPressed() bool {
-	keyPressed := mt.keyPressed
-	mt.keyPressed = false
-	return keyPressed
+// GetLastTakenMedsAt returns when the meds were last taken, known is false if that is unknown
+func (mt *MedTracker) GetLastTakenMedsAt() (lastTaken time.Time, known bool) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	return mt.state.LastTaken()
 }
 
-func (mt *MedTracker) GetLastTakenMedsAt() time.Time {
-	return mt.lastTakenMedsAt
+// PublishTo sends the dose events to the txQ so they go out over LoRa, the tracker works without it
+func (mt *MedTracker) PublishTo(txQ *chan string) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	mt.txQ = txQ
+}
+
+// LastDoseMessage returns the message sent with the heartbeat so the gateway can catch up after a restart
+func (mt *MedTracker) LastDoseMessage() string {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	return iot.MedsLastDose + ":" + mt.lastDoseSeconds()
+}
+
+// OverdueMessage returns the overdue state sent with the heartbeat so a restarted gateway gets it back
+func (mt *MedTracker) OverdueMessage() string {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	return iot.MedsOverdue + ":" + mt.overdueMinutes(mt.status())
+}
+
+// overdueMinutes returns the minutes past due, at least 1 when the dose is overdue and 0 when it is not
+func (mt *MedTracker) overdueMinutes(status dose.DoseStatus) string {
+
+	if status.State != dose.Overdue {
+		return "0"
+	}
+	minutes := int(mt.clock.Now().Sub(status.NextDue).Minutes())
+	if minutes < 1 {
+		minutes = 1
+	}
+	return strconv.Itoa(minutes)
+}
+
+// lastDoseSeconds returns the seconds since the last dose, -1 if there is none or it is unknown
+func (mt *MedTracker) lastDoseSeconds() string {
+
+	lastTaken, known := mt.state.LastTaken()
+	if !known {
+		return "-1"
+	}
+	return strconv.Itoa(int(mt.clock.Now().Sub(lastTaken).Seconds()))
+}
+
+// publish puts a message on the txQ if there is one
+func (mt *MedTracker) publish(msg string) {
+
+	if mt.txQ == nil {
+		return
+	}
+
+	// Use non-blocking send so a full queue drops the event instead of holding up the buttons
+	select {
+	case *mt.txQ <- msg:
+	default:
+		log.Printf("med.publish: txQ full, dropped [%v]", msg)
+	}
+
+}
+
+// Status returns where the tracker is in the dose schedule
+func (mt *MedTracker) Status() dose.DoseStatus {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	return mt.status()
+}
+
+func (mt *MedTracker) status() dose.DoseStatus {
+	return mt.schedule.Status(mt.state.History.DoseTimes(), mt.state.Known, mt.clock.Now())
+}
+
+// Schedule returns the dose schedule the tracker was created with
+func (mt *MedTracker) Schedule() dose.Schedule {
+	return mt.schedule
+}
+
+// Warning returns the warning from the last took meds press, it is empty if there was no problem
+func (mt *MedTracker) Warning() string {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	return mt.warning
 }
 
+// SoundAlarm sounds the buzzer when the dose is overdue, the pattern escalates the longer it is overdue.
+// Call it from the main loop, it blocks while the buzzer sounds.
+func (mt *MedTracker) SoundAlarm() {
+
+	mt.mu.Lock()
+	status := mt.status()
+	sound := mt.alarm.Sound(status, mt.clock.Now())
+	if sound {
+		log.Printf("med.SoundAlarm: overdue by %v", status.Overdue)
+		mt.publish(iot.MedsOverdue + ":" + mt.overdueMinutes(status))
+	}
+	mt.mu.Unlock()
+
+	if sound {
+		mt.sound.Lock()
+		playAlarm(mt.buzzer, status.Level)
+		mt.sound.Unlock()
+	}
+
+}
+
+// tookMeds records a dose, it is refused or recorded with a warning if it breaks the schedule.
+// It returns the pattern to play for the press, nil if there is nothing to play.
+func (mt *MedTracker) tookMeds(now time.Time) []tones {
+
+	var pattern []tones
+
+	mt.warning = ""
+	err := mt.schedule.Check(mt.state.History.DoseTimes(), mt.state.Known, now)
+
+	switch {
+	case err == dose.ErrDailyMax && mt.schedule.BlockOverMax:
+		log.Printf("med.tookMeds: refused, %v", err)
+		mt.warning = "Max doses reached"
+		return blockPattern
+	case err == dose.ErrDailyMax:
+		mt.warning = "Over max doses"
+		pattern = warnPattern
+	case err == dose.ErrTooSoon:
+		mt.warning = "Taken early"
+		pattern = warnPattern
+	}
+
+	if !mt.state.Known {
+		// The old times can't be trusted, start over
+		mt.state = dose.State{Known: true}
+	}
+	mt.state.History.Take(now, now)
+	mt.save()
+	mt.publish(iot.MedsTaken + ":0")
+
+	return pattern
+}
+
+// adjustLastTaken moves the last dose time, the adjustment is recorded in the history.
+// A Repeat is only saved once the button is released so holding a button writes the flash once,
+// the repeats after the first are merged into one entry so one undo takes back the whole hold.
+func (mt *MedTracker) adjustLastTaken(d time.Duration, kind button.EventKind) {
+
+	merge := kind == button.Repeat && mt.adjusting
+	if err := mt.state.History.Adjust(d, mt.clock.Now(), merge); err != nil {
+		log.Printf("med.adjustLastTaken: %v", err)
+		return
+	}
+
+	mt.adjusting = true
+	if kind != button.Repeat {
+		mt.adjusted()
+	}
+
+}
+
+// adjusted saves and publishes the adjustment once it is done
+func (mt *MedTracker) adjusted() {
+
+	if !mt.adjusting {
+		return
+	}
+
+	mt.adjusting = false
+	mt.save()
+	mt.publish(iot.MedsAdjusted + ":" + mt.lastDoseSeconds())
+
+}
+
+// undo removes the last dose or adjustment from the history, it returns the pattern to play
+func (mt *MedTracker) undo() []tones {
+
+	e, err := mt.state.History.Undo()
+	if err != nil {
+		log.Printf("med.undo: %v", err)
+		return warnPattern
+	}
+
+	log.Printf("med.undo: undid %v at %v", e.Kind, e.At)
+	mt.warning = "Undid " + e.Kind.String()
+	mt.save()
+	mt.publish(iot.MedsAdjusted + ":" + mt.lastDoseSeconds())
+
+	return undoPattern
+}
+
+// GetHistory returns a copy of the dose history, known is false if the history can't be trusted
+func (mt *MedTracker) GetHistory() (history dose.History, known bool) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	return dose.History{Entries: append([]dose.Entry(nil), mt.state.History.Entries...)}, mt.state.Known
+}
+
+// save writes the state to flash
+func (mt *MedTracker) save() {
+
+	if err := dose.SaveState(mt.record, mt.state); err != nil {
+		log.Printf("med.save: save error: %v", err)
+	}
+
+}
+
+// KeyPressChannelConsumer handles the button events, it blocks waiting for the next one and does not return
 func (mt *MedTracker) KeyPressChannelConsumer() {
 
-	for {
-		select {
-		case key := <-mt.chKeyPress:
-			// The first key press just wakes the screen
-			if !mt.keyPressed {
-				mt.keyPressed = true
-				break
-			}
-
-			switch key {
-			case "add1HrButton":
-				log.Println("med.chKeyPressConsumer: add1HrButton - Add 1hr")
-				mt.lastTakenMedsAt = mt.lastTakenMedsAt.Add(time.Hour)
-			case "add30MButton":
-				log.Println("med.chKeyPressConsumer: add30MButton - Add 30min")
-				mt.lastTakenMedsAt = mt.lastTakenMedsAt.Add(time.Minute * 30)
-			case "tookMedsButton":
-				log.Println("med.chKeyPressConsumer: tookMedsButton - took meds")
-				mt.lastTakenMedsAt = time.Now()
-			case "sub1HrButton":
-				log.Println("med.chKeyPressConsumer: sub1HrButton pressed - Subtract 1hr")
-				mt.lastTakenMedsAt = mt.lastTakenMedsAt.Add(time.Hour * -1)
-			}
-
-		default:
-			runtime.Gosched()
-			time.Sleep(time.Millisecond * 250)
-			log.Println(".")
+	for e := range mt.events {
+
+		pattern := mt.handleButton(e)
+
+		if pattern != nil {
+			mt.sound.Lock()
+			play(mt.buzzer, pattern)
+			mt.sound.Unlock()
 		}
 	}
+
+}
+
+// handleButton updates the state for a button event, it returns the pattern to play, nil if there is nothing to play
+func (mt *MedTracker) handleButton(e button.Event) []tones {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	// The end of a hold, save what it changed
+	if e.Kind == button.Release {
+		mt.adjusted()
+		return nil
+	}
+
+	// Any key silences the alarm for a while
+	mt.alarm.Snooze(mt.clock.Now())
+
+	// The first key press just wakes the screen
+	if mt.screen != nil && mt.screen.Wake(time.Now()) {
+		return nil
+	}
+
+	// Adjusting an unknown time makes no sense, wait for took meds
+	if !mt.state.Known && e.Button != tookMedsButton {
+		log.Printf("med.handleButton: %v ignored, last taken is unknown", e.Button)
+		return nil
+	}
+
+	switch {
+	case e.Button == add1HrButton:
+		log.Printf("med.handleButton: add1HrButton %v - Add 1hr", e.Kind)
+		mt.adjustLastTaken(time.Hour, e.Kind)
+	case e.Button == add30MButton:
+		log.Printf("med.handleButton: add30MButton %v - Add 30min", e.Kind)
+		mt.adjustLastTaken(time.Minute*30, e.Kind)
+	case e.Button == sub1HrButton:
+		log.Printf("med.handleButton: sub1HrButton %v - Subtract 1hr", e.Kind)
+		mt.adjustLastTaken(time.Hour*-1, e.Kind)
+	case e.Button == tookMedsButton && e.Kind == button.LongPress:
+		log.Println("med.handleButton: tookMedsButton long press - undo")
+		return mt.undo()
+	case e.Button == tookMedsButton && e.Kind == button.Press:
+		log.Println("med.handleButton: tookMedsButton - took meds")
+		return mt.tookMeds(mt.clock.Now())
+	}
+
+	return nil
 }
@@ -0,0 +1,191 @@
+// Package power keeps a battery node asleep between scheduled work.
+//
+// The node's periodic work (heartbeat, sensor readings, ...) is added to a Schedule.
+// The main loop runs whatever is due, then calls Manager.Sleep which shuts down
+// peripherals through the OnSleep hook and parks the main goroutine until the next
+// task is due or a wake signal arrives, typically from a GPIO interrupt.
+//
+// While every goroutine is parked the TinyGo scheduler has nothing to run and the
+// core waits for an event until the timer alarm or a pin interrupt fires. Set Manager.LowPower
+// so that wait is a deep sleep with the unused clocks stopped, see RP2040.
+package power
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/tonygilkerson/mbx-iot/internal/clock"
+)
+
+type task struct {
+	name     string
+	interval time.Duration
+	next     time.Time
+}
+
+// Schedule tracks when each periodic task is next due
+type Schedule struct {
+	tasks []*task
+}
+
+// ErrInterval is returned when a task is added without a positive interval
+var ErrInterval = errors.New("power: task interval must be more than 0")
+
+// Add a task that runs every interval, it is first due at now
+func (s *Schedule) Add(name string, interval time.Duration, now time.Time) error {
+
+	if interval <= 0 {
+		return ErrInterval
+	}
+
+	s.tasks = append(s.tasks, &task{name: name, interval: interval, next: now})
+	return nil
+}
+
+// Due returns the names of the tasks that are due and moves each one to its next slot.
+// If a task was missed more than once, for example after a long sleep, it is returned once
+// and the following slots that are already in the past are skipped.
+func (s *Schedule) Due(now time.Time) []string {
+
+	var names []string
+
+	for _, t := range s.tasks {
+		if now.Before(t.next) {
+			continue
+		}
+
+		names = append(names, t.name)
+		missed := now.Sub(t.next) / t.interval
+		t.next = t.next.Add((missed + 1) * t.interval)
+	}
+
+	return names
+}
+
+// Next returns the time the next task is due, ok is false if there are no tasks
+func (s *Schedule) Next() (next time.Time, ok bool) {
+
+	for _, t := range s.tasks {
+		if !ok || t.next.Before(next) {
+			next = t.next
+			ok = true
+		}
+	}
+
+	return next, ok
+}
+
+// NextSource is anything that knows when it next needs the node awake, such as a Schedule or sensor.Scheduler
+type NextSource interface {
+	Next() (time.Time, bool)
+}
+
+// WakeReason is why the manager woke up
+type WakeReason string
+
+const (
+	WakeSchedule  WakeReason = "schedule"
+	WakeInterrupt WakeReason = "interrupt"
+)
+
+// LowPower puts the chip in a low power state while the node sleeps
+type LowPower interface {
+	// Enter is called once the node has nothing to do, the chip stays in the low power state
+	// each time the core waits until Exit is called
+	Enter()
+	Exit()
+}
+
+// Manager puts the node to sleep until there is work to do
+type Manager struct {
+	clock   clock.Clock
+	sources []NextSource
+	wake    chan string
+
+	// OnSleep is called before going to sleep, use it to power down the radio and other peripherals
+	OnSleep func()
+
+	// OnWake is called after waking, use it to restore what OnSleep shut down
+	OnWake func(reason WakeReason)
+
+	// LowPower is entered after OnSleep and exited before OnWake, nil leaves the chip as is
+	LowPower LowPower
+}
+
+// NewManager creates a power manager for the schedule
+func NewManager(clk clock.Clock, schedule *Schedule) *Manager {
+	return &Manager{
+		clock:   clk,
+		sources: []NextSource{schedule},
+		wake:    make(chan string, 1),
+	}
+}
+
+// AddSource adds another source of wake up times, the manager sleeps until the earliest one
+func (m *Manager) AddSource(source NextSource) {
+	m.sources = append(m.sources, source)
+}
+
+// next returns the earliest time any source needs the node awake
+func (m *Manager) next() (next time.Time, ok bool) {
+
+	for _, source := range m.sources {
+		t, found := source.Next()
+		if found && (!ok || t.Before(next)) {
+			next = t
+			ok = true
+		}
+	}
+
+	return next, ok
+}
+
+// Wake asks the manager to wake up, it is safe to call from an interrupt handler.
+// Wake signals that arrive while the manager is awake are held until the next Sleep.
+func (m *Manager) Wake(source string) {
+
+	// Use non-blocking send so if the channel buffer is full,
+	// the value will get dropped instead of crashing the system
+	select {
+	case m.wake <- source:
+	default:
+	}
+
+}
+
+// Sleep blocks until the next scheduled task or source is due or Wake is called
+func (m *Manager) Sleep() WakeReason {
+
+	var timer <-chan time.Time
+	if next, ok := m.next(); ok {
+		d := next.Sub(m.clock.Now())
+		log.Printf("power.Sleep: sleep for up to %v", d)
+		timer = m.clock.After(d)
+	}
+
+	if m.OnSleep != nil {
+		m.OnSleep()
+	}
+	if m.LowPower != nil {
+		m.LowPower.Enter()
+	}
+
+	var reason WakeReason
+	select {
+	case source := <-m.wake:
+		log.Printf("power.Sleep: woken by %v", source)
+		reason = WakeInterrupt
+	case <-timer:
+		reason = WakeSchedule
+	}
+
+	if m.LowPower != nil {
+		m.LowPower.Exit()
+	}
+	if m.OnWake != nil {
+		m.OnWake(reason)
+	}
+
+	return reason
+}
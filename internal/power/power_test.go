@@ -0,0 +1,129 @@
+package power
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tonygilkerson/mbx-iot/internal/clock"
+)
+
+func TestScheduleDue(t *testing.T) {
+
+	start := time.Unix(0, 0)
+	s := &Schedule{}
+	if err := s.Add("heartbeat", time.Minute*5, start); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("sensor", time.Minute, start); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		at   time.Duration
+		want []string
+		next time.Duration
+	}{
+		{at: 0, want: []string{"heartbeat", "sensor"}, next: time.Minute},
+		{at: time.Second * 30, want: nil, next: time.Minute},
+		{at: time.Minute, want: []string{"sensor"}, next: time.Minute * 2},
+		// A long sleep runs each task once and skips the slots already in the past
+		{at: time.Minute*12 + time.Second, want: []string{"heartbeat", "sensor"}, next: time.Minute * 13},
+		{at: time.Minute * 13, want: []string{"sensor"}, next: time.Minute * 14},
+		{at: time.Minute * 15, want: []string{"heartbeat", "sensor"}, next: time.Minute * 16},
+	}
+
+	for _, tt := range tests {
+		got := s.Due(start.Add(tt.at))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Due(%v) = %v, want %v", tt.at, got, tt.want)
+		}
+		next, ok := s.Next()
+		if !ok || !next.Equal(start.Add(tt.next)) {
+			t.Errorf("after Due(%v) Next = %v, want %v", tt.at, next.Sub(start), tt.next)
+		}
+	}
+}
+
+func TestScheduleRejectsZeroInterval(t *testing.T) {
+
+	s := &Schedule{}
+	if err := s.Add("spin", 0, time.Unix(0, 0)); err != ErrInterval {
+		t.Fatalf("Add with 0 interval err = %v, want ErrInterval", err)
+	}
+	if _, ok := s.Next(); ok {
+		t.Error("a rejected task should not be scheduled")
+	}
+}
+
+type fakeLowPower struct {
+	calls []string
+}
+
+func (f *fakeLowPower) Enter() { f.calls = append(f.calls, "enter") }
+func (f *fakeLowPower) Exit()  { f.calls = append(f.calls, "exit") }
+
+// sleep runs Sleep in the background and returns once the manager is asleep
+func sleep(m *Manager) <-chan WakeReason {
+
+	asleep := make(chan bool)
+	m.OnSleep = func() { asleep <- true }
+
+	reason := make(chan WakeReason, 1)
+	go func() { reason <- m.Sleep() }()
+	<-asleep
+
+	return reason
+}
+
+func TestManagerSleepsUntilNextTask(t *testing.T) {
+
+	clk := clock.NewFake(time.Unix(0, 0))
+	s := &Schedule{}
+	s.Add("heartbeat", time.Minute*5, clk.Now())
+	s.Due(clk.Now())
+
+	lp := &fakeLowPower{}
+	m := NewManager(clk, s)
+	m.LowPower = lp
+	m.OnWake = func(WakeReason) { lp.calls = append(lp.calls, "wake") }
+
+	reason := sleep(m)
+
+	clk.Advance(time.Minute*5 - time.Second)
+	select {
+	case r := <-reason:
+		t.Fatalf("woke early, reason %v", r)
+	default:
+	}
+
+	clk.Advance(time.Second)
+	if r := <-reason; r != WakeSchedule {
+		t.Errorf("reason = %v, want %v", r, WakeSchedule)
+	}
+	if want := []string{"enter", "exit", "wake"}; !reflect.DeepEqual(lp.calls, want) {
+		t.Errorf("calls = %v, want %v", lp.calls, want)
+	}
+}
+
+func TestManagerWakesOnInterrupt(t *testing.T) {
+
+	clk := clock.NewFake(time.Unix(0, 0))
+	s := &Schedule{}
+	s.Add("heartbeat", time.Minute*5, clk.Now())
+	s.Due(clk.Now())
+
+	m := NewManager(clk, s)
+	reason := sleep(m)
+
+	m.Wake("mail")
+	if r := <-reason; r != WakeInterrupt {
+		t.Errorf("reason = %v, want %v", r, WakeInterrupt)
+	}
+
+	// A wake that arrives while awake is held for the next sleep
+	m.Wake("mule")
+	if r := <-sleep(m); r != WakeInterrupt {
+		t.Errorf("held wake reason = %v, want %v", r, WakeInterrupt)
+	}
+}
@@ -0,0 +1,54 @@
+//go:build rp2040
+
+package power
+
+import (
+	"device/rp"
+)
+
+// Clocks kept running while the core is in deep sleep, see CLOCKS SLEEP_EN0/SLEEP_EN1 in the RP2040 datasheet.
+// The timer keeps counting so the runtime's timer alarm wakes the core when the next task is due, IO and pads
+// keep the GPIO edge interrupts that wake it for the door and the mule. The radio, ADC, USB, UARTs and the
+// other peripherals are not clocked until the core wakes.
+const (
+	sleepEn0 = 1<<0 | // clk_sys_clocks
+		1<<3 | // clk_sys_busctrl
+		1<<4 | // clk_sys_busfabric
+		1<<8 | // clk_sys_io
+		1<<10 | // clk_sys_vreg_and_chip_reset
+		1<<11 | // clk_sys_pads
+		1<<14 | // clk_sys_pll_sys
+		1<<16 | // clk_sys_psm
+		1<<18 | // clk_sys_resets
+		1<<23 | // clk_sys_sio
+		1<<28 | 1<<29 | 1<<30 | 1<<31 // clk_sys_sram0 to sram3
+
+	sleepEn1 = 1<<0 | 1<<1 | // clk_sys_sram4, sram5
+		1<<5 | // clk_sys_timer
+		1<<12 | // clk_sys_watchdog, it makes the timer tick
+		1<<13 | // clk_sys_xip
+		1<<14 // clk_sys_xosc
+
+	allClocks = 0xffffffff
+)
+
+// RP2040 is the RP2040 deep sleep.
+//
+// While it is entered each wait for an event in the runtime scheduler is a deep sleep: the clocks not
+// listed in sleepEn0/sleepEn1 stop and the hardware starts them again as soon as an interrupt wakes the core.
+// Dormant mode is not used because it stops the crystal and with it the timer, the node would lose track of time.
+type RP2040 struct{}
+
+// Enter stops the unused clocks whenever the core sleeps
+func (RP2040) Enter() {
+	rp.CLOCKS.SLEEP_EN0.Set(sleepEn0)
+	rp.CLOCKS.SLEEP_EN1.Set(sleepEn1)
+	rp.PPB.SCR.SetBits(rp.PPB_SCR_SLEEPDEEP)
+}
+
+// Exit leaves every clock running while the core sleeps, as it is after reset
+func (RP2040) Exit() {
+	rp.PPB.SCR.ClearBits(rp.PPB_SCR_SLEEPDEEP)
+	rp.CLOCKS.SLEEP_EN0.Set(allClocks)
+	rp.CLOCKS.SLEEP_EN1.Set(allClocks)
+}
@@ -43,8 +43,16 @@ type Radio struct {
 	TxTimeoutMs       uint32
 	TxRxLoopTickerSec uint32
 	CommunicationMode CommunicationMode
+	LoraConf          lora.Config
+
+	// carry is a message taken from the txQ that did not fit in the last packet
+	carry string
 }
 
+// MaxPacketBytes is the largest LoRa payload, a batch is never longer than this.
+// A single message that is longer is sent on its own and will be cut off by the radio.
+const MaxPacketBytes = 255
+
 //
 // DEVTODO - Not sure if/how this is used. I am going to comment out and see what happens
 //           If it is needed then I will need to move it to main
@@ -143,6 +151,7 @@ func SetupLora(
 		LoraTxPowerDBm: 20,
 	}
 
+	radio.LoraConf = loraConf
 	radio.SxDevice.LoraConfig(loraConf)
 
 	radio.TxQ = txQ
@@ -150,6 +159,34 @@ func SetupLora(
 	return radio
 }
 
+// nextBatch takes the messages from the txQ that fit in one packet and joins them with "|".
+// The first message that does not fit is kept for the next batch so messages are never lost or reordered.
+func (radio *Radio) nextBatch() string {
+
+	batchMsg := radio.carry
+	radio.carry = ""
+
+	for {
+		var msg string
+		select {
+		case msg = <-*radio.TxQ:
+		default:
+			return batchMsg
+		}
+
+		switch {
+		case len(batchMsg) == 0:
+			batchMsg = msg
+		case len(batchMsg)+1+len(msg) <= MaxPacketBytes:
+			batchMsg = batchMsg + "|" + msg
+		default:
+			radio.carry = msg
+			return batchMsg
+		}
+	}
+
+}
+
 // SplitMessageBatch will split a batch of messages and return a slice of messages
 // All messages sent over the radio are batched for efficiency
 // Messages on the queue are stored as strings separated by a pipe character "|"
@@ -172,17 +209,28 @@ func (radio *Radio) LoraRxTxRunner() {
 
 
 
+// Sleep powers down the radio
+func (radio *Radio) Sleep() {
+	log.Println("road.Sleep: disable radio")
+	radio.EN.Low()
+}
+
+// Wake powers up the radio after Sleep and restores its configuration
+// which is lost while the radio is powered down
+func (radio *Radio) Wake() {
+	log.Println("road.Wake: enable radio")
+	radio.EN.High()
+	radio.SxDevice.Reset()
+	radio.SxDevice.LoraConfig(radio.LoraConf)
+}
+
 func (radio *Radio) LoraRxTx() (rxData bool) {
 	txQ := radio.TxQ
-	rxQ := radio.RxQ
-
-	// Did we get any data from the Rx?
-	rxData = false
 
 	//
 	// If there are no messages in the channel then get out quick
 	//
-	if radio.CommunicationMode == TxOnly && len(*txQ) == 0 {
+	if radio.CommunicationMode == TxOnly && len(*txQ) == 0 && radio.carry == "" {
 		log.Println("road.LoraRxTx: txQ is empty, mode=TxOnly so getting out early...")
 		return rxData
 	}
@@ -193,68 +241,61 @@ func (radio *Radio) LoraRxTx() (rxData bool) {
 	//
 	// RX - Receive
 	//
-	log.Println("road.LoraRxTx: RX Start - Receiving")
+	rxData = radio.Rx()
+
+	//
+	// TX - Send the messages in txQ that fit in one packet
+	//
+	radio.Tx()
+
+	// Disable the radio to save power...
+	radio.EN.Low()
+
+	return rxData
+}
+
+// Tx sends the messages on the txQ that fit in one packet without listening first, it returns true if
+// anything was sent. The radio must be enabled, a TxOnly node calls it until it returns false.
+func (radio *Radio) Tx() (sent bool) {
+
+	batchMsg := radio.nextBatch()
+	if len(batchMsg) == 0 {
+		log.Println("road.Tx: TX nothing to send, skipping TX")
+		return false
+	}
+
+	log.Printf("road.Tx: TX [%v]", batchMsg)
+	if err := radio.SxDevice.Tx([]byte(batchMsg), radio.TxTimeoutMs); err != nil {
+		log.Printf("road.Tx: TX Error [%v]", err)
+	}
+	return true
+}
+
+// Rx listens for up to RxTimeoutMs and puts a received packet on the rxQ, it returns true if a packet was received.
+// The radio must be enabled, use it after a send to hear the reply.
+func (radio *Radio) Rx() (rxData bool) {
+
+	log.Println("road.Rx: RX Start - Receiving")
 	buf, err := radio.SxDevice.Rx(radio.RxTimeoutMs)
 
 	if err != nil {
-		log.Println("road.LoraRxTx: RX Error: ", err)
+		log.Println("road.Rx: RX Error: ", err)
 
 	} else if buf != nil {
 
-		log.Printf("road.LoraRxTx: RX Packet Received: [%v]", string(buf))
+		log.Printf("road.Rx: RX Packet Received: [%v]", string(buf))
 		rxData = true
 
 		// Use non-blocking send so if the channel buffer is full,
 		// the value will get dropped instead of crashing the system
 		select {
-		case *rxQ <- string(buf):
-		default:
-		}
-
-	} else {
-		log.Println("road.LoraRxTx: RX nothing to receive")
-	}
-
-	//
-	// Batch - batch all message in txQ
-	//
-	var batchMsg string
-
-	// Concatenate all messages separated by \n
-	eom := false //end of messages
-	for {
-		select {
-		case msg := <-*txQ:
-			if len(batchMsg) > 0 {
-				batchMsg = batchMsg + "|" + msg
-			} else {
-				batchMsg = msg
-			}
+		case *radio.RxQ <- string(buf):
 		default:
-			eom = true
 		}
 
-		// break out if end of messages
-		if eom {
-			break
-		}
-	}
-
-	//
-	// TX - Send batch
-	//
-	if len(batchMsg) > 0 {
-		log.Printf("road.LoraRxTx: TX [%v]", batchMsg)
-		err := radio.SxDevice.Tx([]byte(batchMsg), radio.TxTimeoutMs)
-		if err != nil {
-			log.Printf("road.LoraRxTx: TX Error [%v]", err)
-		}
 	} else {
-		log.Println("road.LoraRxTx: TX nothing to send, skipping TX")
+		log.Println("road.Rx: RX nothing to receive")
 	}
 
-	// Disable the radio to save power...
-	radio.EN.Low()
-
 	return rxData
 }
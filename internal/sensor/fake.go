@@ -0,0 +1,44 @@
+package sensor
+
+import (
+	"time"
+)
+
+// Fake is a sensor that returns canned values, it is used to test sensor consumers on the host
+type Fake struct {
+	SensorName     string
+	SensorKey      string
+	SensorUnit     string
+	SensorInterval time.Duration
+
+	// Values are returned in order, the last value repeats once the list is used up
+	Values []float64
+	// Err, if set, is returned instead of a value
+	Err error
+
+	// Reads counts the calls to Read
+	Reads int
+}
+
+func (f *Fake) Name() string            { return f.SensorName }
+func (f *Fake) Key() string             { return f.SensorKey }
+func (f *Fake) Unit() string            { return f.SensorUnit }
+func (f *Fake) Interval() time.Duration { return f.SensorInterval }
+
+func (f *Fake) Read() (float64, error) {
+	f.Reads++
+
+	if f.Err != nil {
+		return 0, f.Err
+	}
+
+	if len(f.Values) == 0 {
+		return 0, nil
+	}
+
+	i := f.Reads - 1
+	if i >= len(f.Values) {
+		i = len(f.Values) - 1
+	}
+	return f.Values[i], nil
+}
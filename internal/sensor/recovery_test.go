@@ -0,0 +1,133 @@
+package sensor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tonygilkerson/mbx-iot/internal/clock"
+	"github.com/tonygilkerson/mbx-iot/internal/soil"
+)
+
+// probeSensor reads the moisture of a seesaw soil sensor on a fake bus
+func probeSensor(t *testing.T, bus *soil.FakeBus) Sensor {
+
+	probe, err := soil.NewWithAddress(bus, soil.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return New("soil-moisture", "SoilMoisture", "", time.Minute, func() (float64, error) {
+		moisture, err := probe.ReadMoisture()
+		return float64(moisture), err
+	})
+}
+
+// poll polls once the next sensor is due
+func poll(s *Scheduler, clk *clock.Fake) []Reading {
+	next, _ := s.Next()
+	clk.Set(next)
+	return s.Poll()
+}
+
+func TestRecoveryReinitsTheBus(t *testing.T) {
+
+	clk := clock.NewFake(time.Unix(0, 0))
+	bus := soil.NewFakeBus(soil.Address)
+	bus.Probes[soil.Address].Moisture = 612
+
+	var reinits int
+	s := NewScheduler(clk, nil)
+	s.Recovery = Recovery{
+		ReinitAfter: 3,
+		Reinit: func() error {
+			// Reconfiguring the i2c peripheral clears the wedged bus
+			reinits++
+			bus.Fail = 0
+			return nil
+		},
+	}
+	s.Register(probeSensor(t, bus))
+
+	// The bus fails every transaction, each read gives up after its retries
+	bus.Fail = 1000
+	for i := 0; i < 3; i++ {
+		if r := poll(s, clk); r[0].Err != soil.ErrFakeBus {
+			t.Fatalf("read %d err = %v, want the bus error", i, r[0].Err)
+		}
+	}
+	if reinits != 1 {
+		t.Fatalf("reinits = %v after 3 failures, want 1", reinits)
+	}
+
+	if r := poll(s, clk); r[0].Err != nil || r[0].Value != 612 {
+		t.Fatalf("read after the reinit = %v, %v", r[0].Value, r[0].Err)
+	}
+	if s.ErrorCount() != 3 {
+		t.Errorf("ErrorCount = %v, want 3", s.ErrorCount())
+	}
+}
+
+func TestRecoveryEscalatesToReset(t *testing.T) {
+
+	start := time.Unix(0, 0)
+	clk := clock.NewFake(start)
+	bus := soil.NewFakeBus(soil.Address)
+	bus.Fail = 1000
+
+	var reinits, resets int
+	s := NewScheduler(clk, nil)
+	s.Recovery = Recovery{
+		ReinitAfter: 2,
+		Reinit: func() error {
+			// The bus stays wedged
+			reinits++
+			return nil
+		},
+		ResetAfter: 6,
+		Reset: func() {
+			// The watchdog would reset the node here
+			resets++
+		},
+	}
+	s.Register(probeSensor(t, bus))
+
+	var at []time.Duration
+	for i := 0; i < 6; i++ {
+		poll(s, clk)
+		at = append(at, clk.Now().Sub(start))
+	}
+
+	if reinits != 2 || resets != 1 {
+		t.Errorf("reinits = %v resets = %v, want 2 reinits before the reset", reinits, resets)
+	}
+
+	// The reads backed off 5s, 10s, 20s, 40s then the 1m interval
+	want := []time.Duration{0, time.Second * 5, time.Second * 15, time.Second * 35, time.Second * 75, time.Second * 135}
+	for i := range want {
+		if at[i] != want[i] {
+			t.Errorf("read %d at %v, want %v", i, at[i], want[i])
+		}
+	}
+}
+
+func TestRecoveryCountResetByGoodRead(t *testing.T) {
+
+	clk := clock.NewFake(time.Unix(0, 0))
+	bus := soil.NewFakeBus(soil.Address)
+
+	var reinits int
+	s := NewScheduler(clk, nil)
+	s.Recovery = Recovery{ReinitAfter: 2, Reinit: func() error { reinits++; return nil }}
+	s.Register(probeSensor(t, bus))
+
+	// One failed read, at most 5 tries, then a good one, then another failed read
+	bus.Fail = 5
+	poll(s, clk)
+	poll(s, clk)
+	bus.Fail = 5
+	poll(s, clk)
+
+	if reinits != 0 {
+		t.Errorf("reinits = %v, a good read should reset the failures in a row", reinits)
+	}
+}
@@ -0,0 +1,293 @@
+// Package sensor polls the sensors on a node and sends their readings over the radio.
+//
+// Each sensor knows its pkg/iot key, unit and how often it should be read. The Scheduler
+// reads every registered sensor when it is due and puts the reading on the node's txQ
+// as a key:value message.
+package sensor
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tonygilkerson/mbx-iot/internal/clock"
+)
+
+// Sensor is anything that produces a numeric reading
+type Sensor interface {
+	// Name used in logs
+	Name() string
+	// Key is the pkg/iot key the reading is sent with
+	Key() string
+	Read() (float64, error)
+	Unit() string
+	// Interval is how often the sensor should be read
+	Interval() time.Duration
+}
+
+// Formatter is implemented by sensors that send more than the bare value, for example "612,43.5"
+type Formatter interface {
+	Format(value float64) string
+}
+
+// Reading is the result of reading a sensor
+type Reading struct {
+	Sensor string
+	Key    string
+	Value  float64
+	Unit   string
+	At     time.Time
+	Err    error
+
+	// Text is the value as sent, it is set when the sensor is a Formatter
+	Text string
+}
+
+// Message returns the reading as a key:value message, the value has at most two decimal places
+func (r Reading) Message() string {
+	if r.Text != "" {
+		return r.Key + ":" + r.Text
+	}
+	return r.Key + ":" + FormatValue(r.Value)
+}
+
+// FormatValue formats a reading value with at most two decimal places and no trailing zeros
+func FormatValue(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	return s
+}
+
+// Func is a Sensor backed by a read function
+type Func struct {
+	name     string
+	key      string
+	unit     string
+	interval time.Duration
+	read     func() (float64, error)
+	format   func(float64) string
+}
+
+// New creates a sensor that calls read to get a value
+func New(name string, key string, unit string, interval time.Duration, read func() (float64, error)) *Func {
+	return &Func{name: name, key: key, unit: unit, interval: interval, read: read}
+}
+
+func (f *Func) Name() string            { return f.name }
+func (f *Func) Key() string             { return f.key }
+func (f *Func) Unit() string            { return f.unit }
+func (f *Func) Interval() time.Duration { return f.interval }
+func (f *Func) Read() (float64, error)  { return f.read() }
+
+// WithFormat sets the function used to format the value when it is sent
+func (f *Func) WithFormat(format func(float64) string) *Func {
+	f.format = format
+	return f
+}
+
+func (f *Func) Format(value float64) string {
+	if f.format == nil {
+		return FormatValue(value)
+	}
+	return f.format(value)
+}
+
+type entry struct {
+	sensor  Sensor
+	next    time.Time
+	last    Reading
+	backoff time.Duration
+}
+
+// Recovery escalates when the sensors keep failing, for example when a shared bus is wedged.
+// Failures are counted in a row across all sensors and reset by any good reading.
+type Recovery struct {
+	// Call Reinit after this many failures in a row, 0 disables
+	ReinitAfter int
+	Reinit      func() error
+
+	// Call Reset after this many failures in a row, 0 disables. This is the last resort
+	// and is expected to reset the node so it typically does not return.
+	ResetAfter int
+	Reset      func()
+}
+
+// Scheduler reads registered sensors at their intervals.
+//
+// A sensor that fails to read is retried after Backoff, doubling on each failure
+// up to the sensor's interval.
+type Scheduler struct {
+	clock   clock.Clock
+	txQ     *chan string
+	entries []*entry
+
+	// If set, each failed read is sent as ErrorKey:count,last error
+	ErrorKey string
+
+	// Delay before the first retry of a failed read, 0 defaults to 5 seconds
+	Backoff time.Duration
+
+	Recovery Recovery
+
+	errorCount int
+	failures   int
+}
+
+// NewScheduler creates a scheduler that sends readings to txQ, txQ may be nil
+func NewScheduler(clk clock.Clock, txQ *chan string) *Scheduler {
+	return &Scheduler{
+		clock:   clk,
+		txQ:     txQ,
+		Backoff: time.Second * 5,
+	}
+}
+
+// ErrorCount returns the total number of failed reads
+func (s *Scheduler) ErrorCount() int {
+	return s.errorCount
+}
+
+// ErrInterval is returned when a sensor is registered without a positive interval
+var ErrInterval = errors.New("sensor: interval must be more than 0")
+
+// Register adds a sensor, it is first read on the next Poll.
+// Sensors that are due at the same time are read in the order they were registered.
+func (s *Scheduler) Register(sensor Sensor) error {
+
+	if sensor.Interval() <= 0 {
+		return ErrInterval
+	}
+
+	s.entries = append(s.entries, &entry{sensor: sensor, next: s.clock.Now()})
+	return nil
+}
+
+// Poll reads the sensors that are due, sends their readings to the txQ and returns them
+func (s *Scheduler) Poll() []Reading {
+
+	var readings []Reading
+	now := s.clock.Now()
+
+	for _, e := range s.entries {
+		if now.Before(e.next) {
+			continue
+		}
+
+		value, err := e.sensor.Read()
+		r := Reading{
+			Sensor: e.sensor.Name(),
+			Key:    e.sensor.Key(),
+			Value:  value,
+			Unit:   e.sensor.Unit(),
+			At:     now,
+			Err:    err,
+		}
+		if f, ok := e.sensor.(Formatter); ok && err == nil {
+			r.Text = f.Format(value)
+		}
+		e.last = r
+		readings = append(readings, r)
+
+		if err != nil {
+			s.failed(e, r, now)
+			continue
+		}
+
+		s.failures = 0
+		e.backoff = 0
+		interval := e.sensor.Interval()
+		e.next = e.next.Add((now.Sub(e.next)/interval + 1) * interval)
+
+		log.Printf("sensor.Poll: %v %v%v", r.Sensor, FormatValue(r.Value), r.Unit)
+		if s.txQ != nil {
+			*s.txQ <- r.Message()
+		}
+	}
+
+	return readings
+}
+
+// failed schedules a retry with backoff, reports the error and escalates to the recovery actions
+func (s *Scheduler) failed(e *entry, r Reading, now time.Time) {
+
+	s.errorCount++
+	s.failures++
+
+	if e.backoff == 0 {
+		e.backoff = s.Backoff
+	} else {
+		e.backoff *= 2
+	}
+	if e.backoff > e.sensor.Interval() {
+		e.backoff = e.sensor.Interval()
+	}
+	e.next = now.Add(e.backoff)
+
+	log.Printf("sensor.Poll: %v read error: %v, failures in a row: %v, retry in %v", r.Sensor, r.Err, s.failures, e.backoff)
+
+	if s.ErrorKey != "" && s.txQ != nil {
+		*s.txQ <- s.ErrorKey + ":" + strconv.Itoa(s.errorCount) + "," + sanitize(r.Sensor+" "+r.Err.Error())
+	}
+
+	rec := s.Recovery
+	switch {
+	case rec.ResetAfter > 0 && s.failures >= rec.ResetAfter && rec.Reset != nil:
+		log.Printf("sensor.Poll: %v failures in a row, reset", s.failures)
+		rec.Reset()
+
+	case rec.ReinitAfter > 0 && s.failures%rec.ReinitAfter == 0 && rec.Reinit != nil:
+		log.Printf("sensor.Poll: %v failures in a row, reinitialize", s.failures)
+		if err := rec.Reinit(); err != nil {
+			log.Printf("sensor.Poll: reinitialize failed: %v", err)
+		}
+	}
+
+}
+
+// sanitize removes the characters used to separate messages and fields so an error can be sent as a value
+func sanitize(s string) string {
+	return strings.NewReplacer("|", " ", ":", " ", ",", " ", "\n", " ").Replace(s)
+}
+
+// Last returns the last reading of the named sensor
+func (s *Scheduler) Last(name string) (last Reading, ok bool) {
+
+	for _, e := range s.entries {
+		if e.sensor.Name() == name && !e.last.At.IsZero() {
+			return e.last, true
+		}
+	}
+
+	return last, false
+}
+
+// Next returns when the next sensor is due, ok is false if no sensors are registered
+func (s *Scheduler) Next() (next time.Time, ok bool) {
+
+	for _, e := range s.entries {
+		if !ok || e.next.Before(next) {
+			next = e.next
+			ok = true
+		}
+	}
+
+	return next, ok
+}
+
+// Run polls the sensors forever, sleeping until the next one is due
+func (s *Scheduler) Run() {
+
+	for {
+		s.Poll()
+
+		next, ok := s.Next()
+		if !ok {
+			return
+		}
+		<-s.clock.After(next.Sub(s.clock.Now()))
+	}
+
+}
@@ -0,0 +1,129 @@
+package sensor
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tonygilkerson/mbx-iot/internal/clock"
+)
+
+// drain returns the messages waiting on the txQ
+func drain(txQ chan string) []string {
+
+	var messages []string
+	for {
+		select {
+		case msg := <-txQ:
+			messages = append(messages, msg)
+		default:
+			return messages
+		}
+	}
+}
+
+func TestPollSendsReadingsWhenDue(t *testing.T) {
+
+	clk := clock.NewFake(time.Unix(0, 0))
+	txQ := make(chan string, 10)
+	s := NewScheduler(clk, &txQ)
+
+	temp := &Fake{SensorName: "temp", SensorKey: "Temp", SensorUnit: "F", SensorInterval: time.Minute, Values: []float64{70.5, 71.25}}
+	soil := &Fake{SensorName: "soil", SensorKey: "Soil", SensorInterval: time.Minute * 5, Values: []float64{600}}
+	s.Register(temp)
+	s.Register(soil)
+
+	s.Poll()
+	if got, want := drain(txQ), []string{"Temp:70.5", "Soil:600"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("first poll sent %v, want %v", got, want)
+	}
+
+	clk.Advance(time.Second * 30)
+	if readings := s.Poll(); len(readings) != 0 {
+		t.Fatalf("nothing is due yet, got %v", readings)
+	}
+
+	// A long sleep reads each sensor once and keeps it on its slots
+	clk.Advance(time.Minute*5 + time.Second*30)
+	s.Poll()
+	if got, want := drain(txQ), []string{"Temp:71.25", "Soil:600"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("poll after a long sleep sent %v, want %v", got, want)
+	}
+	if next, _ := s.Next(); !next.Equal(time.Unix(0, 0).Add(time.Minute * 7)) {
+		t.Errorf("Next = %v, want the temp slot at 7m", next.Sub(time.Unix(0, 0)))
+	}
+
+	if last, ok := s.Last("temp"); !ok || last.Value != 71.25 {
+		t.Errorf("Last(temp) = %v, %v", last, ok)
+	}
+}
+
+func TestRegisterRejectsZeroInterval(t *testing.T) {
+
+	s := NewScheduler(clock.NewFake(time.Unix(0, 0)), nil)
+	if err := s.Register(&Fake{SensorName: "spin"}); err != ErrInterval {
+		t.Fatalf("Register with 0 interval err = %v, want ErrInterval", err)
+	}
+	if _, ok := s.Next(); ok {
+		t.Error("a rejected sensor should not be scheduled")
+	}
+}
+
+func TestFormatter(t *testing.T) {
+
+	clk := clock.NewFake(time.Unix(0, 0))
+	txQ := make(chan string, 10)
+	s := NewScheduler(clk, &txQ)
+
+	s.Register(New("soil", "SoilMoisture", "", time.Minute, func() (float64, error) {
+		return 612, nil
+	}).WithFormat(func(raw float64) string {
+		return FormatValue(raw) + ",43.5"
+	}))
+
+	s.Poll()
+	if got, want := drain(txQ), []string{"SoilMoisture:612,43.5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+}
+
+func TestFailedReadBacksOff(t *testing.T) {
+
+	start := time.Unix(0, 0)
+	clk := clock.NewFake(start)
+	txQ := make(chan string, 10)
+	s := NewScheduler(clk, &txQ)
+	s.ErrorKey = "SensorError"
+
+	f := &Fake{SensorName: "temp", SensorKey: "Temp", SensorInterval: time.Second * 30, Err: errors.New("no ack: bus, stuck")}
+	s.Register(f)
+
+	// The retry waits 5s, 10s, 20s then is capped at the interval
+	var waits []time.Duration
+	for i := 0; i < 4; i++ {
+		s.Poll()
+		next, _ := s.Next()
+		waits = append(waits, next.Sub(clk.Now()))
+		clk.Set(next)
+	}
+	if want := []time.Duration{time.Second * 5, time.Second * 10, time.Second * 20, time.Second * 30}; !reflect.DeepEqual(waits, want) {
+		t.Errorf("retry waits = %v, want %v", waits, want)
+	}
+
+	// The error is sent with the count and without the separators
+	if got := drain(txQ); len(got) != 4 || got[0] != "SensorError:1,temp no ack  bus  stuck" {
+		t.Errorf("error messages = %v", got)
+	}
+
+	// A good read goes back to the interval
+	f.Err = nil
+	s.Poll()
+	next, _ := s.Next()
+	if wait := next.Sub(clk.Now()); wait != time.Second*30 {
+		t.Errorf("wait after a good read = %v, want the 30s interval", wait)
+	}
+	if s.ErrorCount() != 4 {
+		t.Errorf("ErrorCount = %v, want 4", s.ErrorCount())
+	}
+}
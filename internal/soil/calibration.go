@@ -0,0 +1,153 @@
+package soil
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// Calibration converts raw moisture readings to a percent between a dry and a wet reference.
+// The references are raw readings taken with the probe in dry soil and in saturated soil.
+type Calibration struct {
+	Address uint16
+	Dry     uint16
+	Wet     uint16
+}
+
+// IsValid returns true if the wet reference reads higher than the dry reference
+func (c Calibration) IsValid() bool {
+	return c.Wet > c.Dry
+}
+
+// Percent converts a raw reading to a percent, 0 at the dry reference and 100 at the wet reference
+func (c Calibration) Percent(raw uint16) float64 {
+
+	if !c.IsValid() {
+		return 0
+	}
+
+	p := float64(int(raw)-int(c.Dry)) / float64(c.Wet-c.Dry) * 100
+	switch {
+	case p < 0:
+		return 0
+	case p > 100:
+		return 100
+	}
+	return p
+}
+
+// ErrBadCalibrations is returned when saved calibrations can't be decoded
+var ErrBadCalibrations = errors.New("soil: bad calibration data")
+
+// EncodeCalibrations packs calibrations for saving in a store.Record
+func EncodeCalibrations(cals []Calibration) []byte {
+
+	buf := make([]byte, len(cals)*6)
+	for i, c := range cals {
+		binary.LittleEndian.PutUint16(buf[i*6:], c.Address)
+		binary.LittleEndian.PutUint16(buf[i*6+2:], c.Dry)
+		binary.LittleEndian.PutUint16(buf[i*6+4:], c.Wet)
+	}
+	return buf
+}
+
+// DecodeCalibrations unpacks calibrations packed by EncodeCalibrations
+func DecodeCalibrations(buf []byte) ([]Calibration, error) {
+
+	if len(buf)%6 != 0 {
+		return nil, ErrBadCalibrations
+	}
+
+	var cals []Calibration
+	for i := 0; i < len(buf); i += 6 {
+		cals = append(cals, Calibration{
+			Address: binary.LittleEndian.Uint16(buf[i:]),
+			Dry:     binary.LittleEndian.Uint16(buf[i+2:]),
+			Wet:     binary.LittleEndian.Uint16(buf[i+4:]),
+		})
+	}
+	return cals, nil
+}
+
+// ReadMoistureAverage takes several moisture readings and returns the average,
+// this smooths out the noise of a single reading
+func (d *Device) ReadMoistureAverage(samples int) (moisture uint16, err error) {
+
+	if samples < 1 {
+		samples = 1
+	}
+
+	var sum int
+	for i := 0; i < samples; i++ {
+		m, err := d.ReadMoisture()
+		if err != nil {
+			return 0, err
+		}
+		sum += int(m)
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	return uint16(sum / samples), nil
+}
+
+// CalibrationStep is where a Calibrator is in the calibration sequence
+type CalibrationStep int
+
+// CalibrationIdle
+// Not calibrating
+//
+// CalibrationDry
+// Waiting for the probes to be put in dry soil, the next press captures the dry reference
+//
+// CalibrationWet
+// Waiting for the probes to be put in wet soil, the next press captures the wet reference
+const (
+	CalibrationIdle CalibrationStep = iota
+	CalibrationDry
+	CalibrationWet
+)
+
+// Calibrator walks through calibrating all the probes with a single button.
+// Each press moves to the next step, the probes are calibrated together.
+type Calibrator struct {
+	step CalibrationStep
+	dry  []uint16
+}
+
+// Step returns the current step
+func (c *Calibrator) Step() CalibrationStep {
+	return c.step
+}
+
+// Press advances the calibration. The readings are the current raw reading of each probe,
+// they are captured as the dry or wet reference depending on the step. When the wet reference
+// is captured the new calibrations are returned with done set to true.
+func (c *Calibrator) Press(probes []*Device, readings []uint16) (cals []Calibration, done bool) {
+
+	switch c.step {
+	case CalibrationIdle:
+		c.step = CalibrationDry
+
+	case CalibrationDry:
+		c.dry = append([]uint16(nil), readings...)
+		c.step = CalibrationWet
+
+	case CalibrationWet:
+		for i, p := range probes {
+			if i >= len(readings) || i >= len(c.dry) {
+				break
+			}
+			cals = append(cals, Calibration{Address: p.Address, Dry: c.dry[i], Wet: readings[i]})
+		}
+		c.step = CalibrationIdle
+		done = true
+	}
+
+	return cals, done
+}
+
+// Cancel abandons the calibration
+func (c *Calibrator) Cancel() {
+	c.step = CalibrationIdle
+	c.dry = nil
+}
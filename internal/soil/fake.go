@@ -0,0 +1,117 @@
+package soil
+
+import (
+	"errors"
+)
+
+var (
+	// ErrFakeBus is returned by FakeBus when it is told to fail
+	ErrFakeBus = errors.New("soil: fake i2c bus failure")
+
+	// ErrFakeNoDevice is returned by FakeBus when there is no probe at the address
+	ErrFakeNoDevice = errors.New("soil: fake i2c bus, no device at address")
+)
+
+// FakeProbe is the register state of one emulated seesaw soil sensor
+type FakeProbe struct {
+	Moisture       uint16
+	TemperatureRaw uint32 // 16.16 fixed point degrees Celsius
+	HardwareID     uint8
+	Version        uint32 // product code << 16 | date code
+
+	// Resets counts the software resets received
+	Resets int
+
+	lastCommand uint16
+}
+
+// SetTemperature sets the temperature in degrees Celsius
+func (p *FakeProbe) SetTemperature(celsius float64) {
+	p.TemperatureRaw = uint32(celsius * 65536)
+}
+
+// response returns the register value for the last command written
+func (p *FakeProbe) response() []byte {
+	switch p.lastCommand {
+	case command(readMoistureCommand):
+		return []byte{byte(p.Moisture >> 8), byte(p.Moisture)}
+	case command(readTemperatureCommand):
+		return be32(p.TemperatureRaw)
+	case command(readHardwareIDCommand):
+		return []byte{p.HardwareID}
+	case command(readVersionCommand):
+		return be32(p.Version)
+	default:
+		return nil
+	}
+}
+
+// FakeBus is a drivers.I2C that emulates seesaw soil sensors so the soil package
+// and its callers can run on the host
+type FakeBus struct {
+	Probes map[uint16]*FakeProbe
+
+	// Fail makes the next Fail transactions return ErrFakeBus
+	Fail int
+
+	// Transactions counts all transactions including the failed ones
+	Transactions int
+}
+
+// NewFakeBus creates a fake bus with a probe at each address, the probes report the soil
+// sensor hardware id and a room temperature of 20C
+func NewFakeBus(addresses ...uint16) *FakeBus {
+
+	b := &FakeBus{Probes: make(map[uint16]*FakeProbe)}
+
+	for _, a := range addresses {
+		p := &FakeProbe{HardwareID: HardwareID, Version: 4026<<16 | 0x1234}
+		p.SetTemperature(20)
+		b.Probes[a] = p
+	}
+
+	return b
+}
+
+func (b *FakeBus) Tx(addr uint16, w, r []byte) error {
+
+	b.Transactions++
+	if b.Fail > 0 {
+		b.Fail--
+		return ErrFakeBus
+	}
+
+	p, found := b.Probes[addr]
+	if !found {
+		return ErrFakeNoDevice
+	}
+
+	if len(w) >= 2 {
+		p.lastCommand = command(w)
+		if p.lastCommand == command(softwareResetCommand) {
+			p.Resets++
+		}
+	}
+
+	if len(r) > 0 {
+		copy(r, p.response())
+	}
+
+	return nil
+}
+
+func (b *FakeBus) ReadRegister(addr uint8, reg uint8, buf []byte) error {
+	return b.Tx(uint16(addr), []byte{reg}, buf)
+}
+
+func (b *FakeBus) WriteRegister(addr uint8, reg uint8, buf []byte) error {
+	return b.Tx(uint16(addr), append([]byte{reg}, buf...), nil)
+}
+
+func command(w []byte) uint16 {
+	return uint16(w[0])<<8 | uint16(w[1])
+}
+
+func be32(v uint32) []byte {
+	return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+}
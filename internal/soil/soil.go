@@ -23,73 +23,203 @@ package soil
 // In soil, you'll see this range from about 0 to 1023 at the extremes
 // Note that it does depend on how packed/loose the soil is!
 
+//
+// Up to four sensors can share a bus, the address is selected with the AD0/AD1 jumpers
+// on the back of the board, 0x36 (default) through 0x39.
+
 import (
-	
+	"errors"
+	"fmt"
 	"time"
+
 	"tinygo.org/x/drivers"
 )
 
-const Address = 0x36
+const (
+	// Address is the default I2C address
+	Address = 0x36
+
+	// MinAddress and MaxAddress are the range of addresses selectable with the AD0/AD1 jumpers
+	MinAddress = 0x36
+	MaxAddress = 0x39
+
+	// HardwareID is the value of the status HW_ID register for the SAMD09 used on the soil sensor
+	HardwareID = 0x55
+)
+
+// Seesaw module base and function registers
+const (
+	statusBase    = 0x00
+	statusHwID    = 0x01
+	statusVersion = 0x02
+	statusTemp    = 0x04
+	statusSwrst   = 0x7F
+
+	touchBase     = 0x0F
+	touchChannel0 = 0x10
+)
 
 // BASE = 0x0F and OFFSET = 0x10
-var readMoistureCommand = []uint8{0x0F, 0x10}
+var readMoistureCommand = []uint8{touchBase, touchChannel0}
+
+// BASE = 0x00 and OFFSET = 0x04
+var readTemperatureCommand = []uint8{statusBase, statusTemp}
+
+var readHardwareIDCommand = []uint8{statusBase, statusHwID}
+var readVersionCommand = []uint8{statusBase, statusVersion}
+var softwareResetCommand = []uint8{statusBase, statusSwrst, 0xFF}
+
+var (
+	ErrInvalidAddress  = errors.New("soil: address must be 0x36 to 0x39")
+	ErrWrongHardwareID = errors.New("soil: unexpected hardware id, is this a seesaw soil sensor?")
+)
+
+// TemperatureUnit selects the unit returned by ReadTemperature
+type TemperatureUnit int
 
-// BASE = 0x00 and OFFSET = 0x10
-var readTemperatureCommand = []uint8{0x00, 0x04}
+const (
+	Celsius TemperatureUnit = iota
+	Fahrenheit
+)
 
 // Device wraps an I2C connection to a Soil device.
 type Device struct {
-	bus                drivers.I2C
-	Address            uint16
-	moistureBuf        [2]uint8 // DEVTODO not sure I need this in the struct
-	temperatureBuf     [4]uint8 // DEVTODO not sure I need this in the struct
+	bus     drivers.I2C
+	Address uint16
+	Unit    TemperatureUnit
+	buf     [4]uint8
 }
 
-// New creates a new SeeSaw Soil Sensor connection. The I2C bus must already be configured.
+// New creates a new SeeSaw Soil Sensor connection at the default address. The I2C bus must already be configured.
 //
 // This function only creates the Device object, it does not touch the device.
 func New(bus drivers.I2C) *Device {
 	return &Device{
 		bus:     bus,
 		Address: Address,
+		Unit:    Celsius,
+	}
+}
+
+// NewWithAddress creates a new SeeSaw Soil Sensor connection at one of the jumper selectable addresses
+func NewWithAddress(bus drivers.I2C, address uint16) (*Device, error) {
+
+	if address < MinAddress || address > MaxAddress {
+		return nil, ErrInvalidAddress
+	}
+
+	d := New(bus)
+	d.Address = address
+	return d, nil
+}
+
+// Key returns the pkg/iot key to use for a reading from this sensor. The sensor at the default
+// address uses the key as is, other sensors get the address appended, for example SoilMoisture-0x37
+func (d *Device) Key(key string) string {
+	if d.Address == Address {
+		return key
+	}
+	return fmt.Sprintf("%v-0x%02x", key, d.Address)
+}
+
+// Configure resets the sensor and checks that it is a seesaw soil sensor
+func (d *Device) Configure() error {
+
+	err := d.SoftwareReset()
+	if err != nil {
+		return err
+	}
+
+	// The seesaw needs a moment to come back after a reset
+	time.Sleep(time.Millisecond * 500)
+
+	id, err := d.ReadHardwareID()
+	if err != nil {
+		return err
+	}
+	if id != HardwareID {
+		return ErrWrongHardwareID
 	}
+
+	return nil
+}
+
+// SoftwareReset resets the seesaw, all settings go back to their defaults
+func (d *Device) SoftwareReset() error {
+	return d.bus.Tx(d.Address, softwareResetCommand, nil)
+}
+
+// ReadHardwareID returns the seesaw hardware id, HardwareID for the soil sensor
+func (d *Device) ReadHardwareID() (id uint8, err error) {
+
+	err = d.read(readHardwareIDCommand, d.buf[:1])
+	if err != nil {
+		return 0, err
+	}
+
+	return d.buf[0], nil
 }
 
-// Read returns the moisture reading in range 0 to 1023
+// ReadVersion returns the seesaw firmware product code and date code
+func (d *Device) ReadVersion() (product uint16, date uint16, err error) {
+
+	err = d.read(readVersionCommand, d.buf[:4])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	product = uint16(d.buf[0])<<8 | uint16(d.buf[1])
+	date = uint16(d.buf[2])<<8 | uint16(d.buf[3])
+	return product, date, nil
+}
+
+// ReadMoisture returns the moisture reading in range 0 to 1023
 func (d *Device) ReadMoisture() (moisture uint16, err error) {
 
-	for retry := 0; retry < 5; retry++ {
-		err = d.bus.Tx(d.Address, readMoistureCommand, nil)
-		if err != nil {
-			continue
-		}
-		time.Sleep(time.Duration(3000+retry*1000) * time.Microsecond)
-		err = d.bus.Tx(d.Address, nil, d.moistureBuf[:])
-		if err == nil {
-			moisture = (uint16(d.moistureBuf[0]) << 8) | uint16(d.moistureBuf[1])
-			return moisture, nil
-		}
+	err = d.read(readMoistureCommand, d.buf[:2])
+	if err != nil {
+		return 0, err
 	}
-	return // returns last error
+
+	moisture = (uint16(d.buf[0]) << 8) | uint16(d.buf[1])
+	return moisture, nil
 
 }
 
-// Read Temperature in degrees Celsius
+// ReadTemperature returns the temperature in the device's Unit, degrees Celsius by default.
+// Note the sensor is not very precise, +/- 2 degrees Celsius.
 func (d *Device) ReadTemperature() (temperature float64, err error) {
+
+	err = d.read(readTemperatureCommand, d.buf[:4])
+	if err != nil {
+		return 0, err
+	}
+
+	// The register is a 16.16 fixed point value
+	tempRaw := (uint32(d.buf[0]) << 24) | uint32(d.buf[1])<<16 | uint32(d.buf[2])<<8 | uint32(d.buf[3])
+	celsius := float64(tempRaw) / 65536
+
+	if d.Unit == Fahrenheit {
+		return celsius*1.8 + 32, nil
+	}
+	return celsius, nil
+}
+
+// read writes a command then reads the response, the seesaw needs a short delay between the two.
+// The read is retried a few times with a longer delay each time, the last error is returned.
+func (d *Device) read(command []uint8, buf []uint8) (err error) {
+
 	for retry := 0; retry < 5; retry++ {
-		err = d.bus.Tx(d.Address, readTemperatureCommand, nil)
+		err = d.bus.Tx(d.Address, command, nil)
 		if err != nil {
 			continue
 		}
 		time.Sleep(time.Duration(3000+retry*1000) * time.Microsecond)
-		err = d.bus.Tx(d.Address, nil, d.temperatureBuf[:])
+		err = d.bus.Tx(d.Address, nil, buf)
 		if err == nil {
-			tempRaw := (uint32(d.temperatureBuf[0]) << 24) | uint32(d.temperatureBuf[1])<<16 | uint32(d.temperatureBuf[2])<<8 | uint32(d.temperatureBuf[3])
-			// tempC := (float64(tempRaw) / 100_000)
-			temperature = float64(tempRaw)/float64(100_000)*1.8 + float64(32)
-			return temperature, nil
+			return nil
 		}
 	}
-	return // returns last error
-}
+	return err
 
+}
@@ -0,0 +1,111 @@
+package soil
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConfigureChecksHardwareID(t *testing.T) {
+
+	bus := NewFakeBus(Address, 0x37)
+	bus.Probes[0x37].HardwareID = 0x87
+
+	d := New(bus)
+	if err := d.Configure(); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if resets := bus.Probes[Address].Resets; resets != 1 {
+		t.Errorf("resets = %v, Configure should reset the seesaw once", resets)
+	}
+
+	other, err := NewWithAddress(bus, 0x37)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Configure(); err != ErrWrongHardwareID {
+		t.Errorf("Configure with hardware id 0x87 err = %v, want ErrWrongHardwareID", err)
+	}
+
+	if _, err := NewWithAddress(bus, 0x40); err != ErrInvalidAddress {
+		t.Errorf("NewWithAddress(0x40) err = %v, want ErrInvalidAddress", err)
+	}
+}
+
+func TestReadHardwareIDAndVersion(t *testing.T) {
+
+	d := New(NewFakeBus(Address))
+
+	id, err := d.ReadHardwareID()
+	if err != nil || id != 0x55 {
+		t.Errorf("ReadHardwareID = %#x, %v, want 0x55", id, err)
+	}
+
+	product, date, err := d.ReadVersion()
+	if err != nil || product != 4026 || date != 0x1234 {
+		t.Errorf("ReadVersion = %v, %#x, %v, want product 4026", product, date, err)
+	}
+}
+
+func TestSoftwareReset(t *testing.T) {
+
+	bus := NewFakeBus(Address)
+	d := New(bus)
+
+	for i := 0; i < 2; i++ {
+		if err := d.SoftwareReset(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if resets := bus.Probes[Address].Resets; resets != 2 {
+		t.Errorf("resets = %v, want 2", resets)
+	}
+}
+
+func TestReadTemperature(t *testing.T) {
+
+	tests := []struct {
+		raw     uint32
+		unit    TemperatureUnit
+		want    float64
+		comment string
+	}{
+		{raw: 20 << 16, unit: Celsius, want: 20, comment: "whole degrees"},
+		{raw: 21<<16 | 0x8000, unit: Celsius, want: 21.5, comment: "the low 16 bits are the fraction"},
+		{raw: 25<<16 | 0x4000, unit: Celsius, want: 25.25},
+		{raw: 100 << 16, unit: Fahrenheit, want: 212},
+	}
+
+	for _, tt := range tests {
+		bus := NewFakeBus(Address)
+		bus.Probes[Address].TemperatureRaw = tt.raw
+		d := New(bus)
+		d.Unit = tt.unit
+
+		got, err := d.ReadTemperature()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(got-tt.want) > 0.0001 {
+			t.Errorf("raw %#x: temperature = %v, want %v %v", tt.raw, got, tt.want, tt.comment)
+		}
+	}
+}
+
+func TestReadRetriesOnBusErrors(t *testing.T) {
+
+	bus := NewFakeBus(Address)
+	bus.Probes[Address].Moisture = 700
+	d := New(bus)
+
+	// Two failed tries then a good one
+	bus.Fail = 2
+	if m, err := d.ReadMoisture(); err != nil || m != 700 {
+		t.Errorf("ReadMoisture = %v, %v, want 700", m, err)
+	}
+
+	// Every try fails
+	bus.Fail = 100
+	if _, err := d.ReadMoisture(); err != ErrFakeBus {
+		t.Errorf("ReadMoisture err = %v, want ErrFakeBus", err)
+	}
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrOutOfRange is returned when an erase falls outside the device
+var ErrOutOfRange = errors.New("store: erase outside the device")
+
+// File is a Device backed by a file, it behaves like flash: erased bytes read as 0xFF.
+// Use it to run the code that saves records on the host.
+type File struct {
+	f         *os.File
+	size      int64
+	blockSize int64
+}
+
+// OpenFile opens or creates a file backed device of size bytes, a new or short file is padded with erased blocks
+func OpenFile(path string, size int64, blockSize int64) (*File, error) {
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if pad := size - info.Size(); pad > 0 {
+		if _, err := f.WriteAt(bytes.Repeat([]byte{0xFF}, int(pad)), info.Size()); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	return &File{f: f, size: size, blockSize: blockSize}, nil
+}
+
+func (d *File) ReadAt(p []byte, off int64) (n int, err error) {
+	n, err = d.f.ReadAt(p, off)
+	if err == io.EOF && n == len(p) {
+		err = nil
+	}
+	return n, err
+}
+
+func (d *File) WriteAt(p []byte, off int64) (n int, err error) {
+	if off+int64(len(p)) > d.size {
+		return 0, io.ErrShortWrite
+	}
+	return d.f.WriteAt(p, off)
+}
+
+// EraseBlocks sets len blocks starting at block start to 0xFF
+func (d *File) EraseBlocks(start, len int64) error {
+
+	if start < 0 || (start+len)*d.blockSize > d.size {
+		return ErrOutOfRange
+	}
+
+	_, err := d.f.WriteAt(bytes.Repeat([]byte{0xFF}, int(len*d.blockSize)), start*d.blockSize)
+	return err
+}
+
+func (d *File) EraseBlockSize() int64 {
+	return d.blockSize
+}
+
+// Close closes the file
+func (d *File) Close() error {
+	return d.f.Close()
+}
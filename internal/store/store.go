@@ -0,0 +1,104 @@
+// Package store saves small records to flash so they survive a power loss.
+//
+// Each record lives at its own erase block aligned offset in the device. A record is written
+// with a magic number, length and checksum so a blank or half written block reads as ErrNotFound
+// rather than garbage.
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+// Device is block storage that must be erased before it is written, it is notably implemented by machine.Flash
+type Device interface {
+	ReadAt(p []byte, off int64) (n int, err error)
+	WriteAt(p []byte, off int64) (n int, err error)
+	EraseBlocks(start, len int64) error
+	EraseBlockSize() int64
+}
+
+var (
+	ErrNotFound  = errors.New("store: record not found")
+	ErrTooLarge  = errors.New("store: record does not fit in an erase block")
+	ErrAlignment = errors.New("store: record offset must be erase block aligned")
+)
+
+const (
+	magic      = 0x4D425831 // MBX1
+	headerSize = 4 + 2      // magic + length
+	crcSize    = 4
+)
+
+// Record is one saved value
+type Record struct {
+	dev    Device
+	offset int64
+}
+
+// NewRecord creates a record at offset, which must be a multiple of the device erase block size
+func NewRecord(dev Device, offset int64) (*Record, error) {
+
+	if offset%dev.EraseBlockSize() != 0 {
+		return nil, ErrAlignment
+	}
+
+	return &Record{dev: dev, offset: offset}, nil
+}
+
+// Load reads the saved value into p and returns its length
+func (r *Record) Load(p []byte) (n int, err error) {
+
+	header := make([]byte, headerSize)
+	if _, err := r.dev.ReadAt(header, r.offset); err != nil {
+		return 0, err
+	}
+
+	if binary.LittleEndian.Uint32(header[0:4]) != magic {
+		return 0, ErrNotFound
+	}
+
+	length := int(binary.LittleEndian.Uint16(header[4:6]))
+	if int64(headerSize+length+crcSize) > r.dev.EraseBlockSize() {
+		return 0, ErrNotFound
+	}
+
+	body := make([]byte, length+crcSize)
+	if _, err := r.dev.ReadAt(body, r.offset+headerSize); err != nil {
+		return 0, err
+	}
+
+	value := body[:length]
+	if crc32.ChecksumIEEE(value) != binary.LittleEndian.Uint32(body[length:]) {
+		return 0, ErrNotFound
+	}
+
+	if len(p) < length {
+		return 0, ErrTooLarge
+	}
+
+	return copy(p, value), nil
+}
+
+// Save erases the record's block and writes p to it
+func (r *Record) Save(p []byte) error {
+
+	size := headerSize + len(p) + crcSize
+	if int64(size) > r.dev.EraseBlockSize() || len(p) > 0xFFFF {
+		return ErrTooLarge
+	}
+
+	buf := make([]byte, size)
+	binary.LittleEndian.PutUint32(buf[0:4], magic)
+	binary.LittleEndian.PutUint16(buf[4:6], uint16(len(p)))
+	copy(buf[headerSize:], p)
+	binary.LittleEndian.PutUint32(buf[headerSize+len(p):], crc32.ChecksumIEEE(p))
+
+	if err := r.dev.EraseBlocks(r.offset/r.dev.EraseBlockSize(), 1); err != nil {
+		return err
+	}
+
+	_, err := r.dev.WriteAt(buf, r.offset)
+	return err
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+const blockSize = 64
+
+// device returns a file backed device of 4 erase blocks
+func device(t *testing.T) *File {
+
+	dev, err := OpenFile(filepath.Join(t.TempDir(), "flash"), blockSize*4, blockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dev.Close() })
+
+	return dev
+}
+
+func TestSaveLoad(t *testing.T) {
+
+	dev := device(t)
+	r, err := NewRecord(dev, blockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, value := range [][]byte{[]byte("first value"), []byte("2nd"), {}} {
+		if err := r.Save(value); err != nil {
+			t.Fatalf("Save(%q): %v", value, err)
+		}
+
+		buf := make([]byte, blockSize)
+		n, err := r.Load(buf)
+		if err != nil || !bytes.Equal(buf[:n], value) {
+			t.Errorf("Load = %q, %v, want %q", buf[:n], err, value)
+		}
+	}
+
+	// Another record at the same offset reads the same value
+	other, _ := NewRecord(dev, blockSize)
+	buf := make([]byte, blockSize)
+	if _, err := other.Load(buf); err != nil {
+		t.Errorf("Load from a new record: %v", err)
+	}
+}
+
+func TestBlankBlockNotFound(t *testing.T) {
+
+	r, _ := NewRecord(device(t), 0)
+
+	if _, err := r.Load(make([]byte, blockSize)); err != ErrNotFound {
+		t.Errorf("Load of an erased block err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestCorruptedRecordNotFound(t *testing.T) {
+
+	dev := device(t)
+	r, _ := NewRecord(dev, 0)
+	if err := r.Save([]byte("saved value")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip a bit of the value, as a write cut short by a power loss might
+	b := make([]byte, 1)
+	dev.ReadAt(b, headerSize+2)
+	b[0] ^= 0x01
+	dev.WriteAt(b, headerSize+2)
+
+	if _, err := r.Load(make([]byte, blockSize)); err != ErrNotFound {
+		t.Errorf("Load with a bad checksum err = %v, want ErrNotFound", err)
+	}
+
+	// A length past the block is not trusted either
+	dev.WriteAt([]byte{0xFF, 0x7F}, 4)
+	if _, err := r.Load(make([]byte, blockSize)); err != ErrNotFound {
+		t.Errorf("Load with a bad length err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSizeAndAlignment(t *testing.T) {
+
+	dev := device(t)
+
+	if _, err := NewRecord(dev, blockSize/2); err != ErrAlignment {
+		t.Errorf("NewRecord off a block err = %v, want ErrAlignment", err)
+	}
+
+	r, _ := NewRecord(dev, 0)
+	if err := r.Save(make([]byte, blockSize-headerSize-crcSize+1)); err != ErrTooLarge {
+		t.Errorf("Save larger than a block err = %v, want ErrTooLarge", err)
+	}
+
+	if err := r.Save([]byte("a longer value")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Load(make([]byte, 4)); err != ErrTooLarge {
+		t.Errorf("Load into a short buffer err = %v, want ErrTooLarge", err)
+	}
+}
@@ -0,0 +1,116 @@
+package tm1637
+
+// Encode returns the segments for a character, characters that can't be shown are blank
+//
+//	0-9 A-Z a-z, space, - and * for the degree sign
+func Encode(c byte) byte {
+	switch {
+	case c == ' ':
+		return segments[segmentBlank]
+	case c == '*':
+		return segments[segmentStar] // star/degrees
+	case c == '-':
+		return segments[segmentDash]
+	case c >= 'A' && c <= 'Z':
+		return segments[c-55]
+	case c >= 'a' && c <= 'z':
+		return segments[c-87]
+	case c >= '0' && c <= '9':
+		return segments[c-48]
+	default:
+		return 0
+	}
+}
+
+// EncodeText returns the segments for a text. A '.' lights the decimal point of the
+// character before it instead of taking a digit of its own, so "12.5" uses 3 digits.
+func EncodeText(text []byte) []byte {
+
+	var encoded []byte
+	for i, c := range text {
+		if c == '.' && i > 0 && text[i-1] != '.' {
+			encoded[len(encoded)-1] |= segmentDP
+			continue
+		}
+		if c == '.' {
+			encoded = append(encoded, segmentDP)
+			continue
+		}
+		encoded = append(encoded, Encode(c))
+	}
+	return encoded
+}
+
+// EncodeNumber returns the segments for a number right aligned in the digits, a negative number
+// has the sign just before its first digit. A number that does not fit is shown as dashes.
+func EncodeNumber(num int, digits int) []byte {
+
+	encoded := make([]byte, digits)
+
+	negative := num < 0
+	if negative {
+		num = -num
+	}
+
+	i := digits - 1
+	for {
+		if i < 0 {
+			return dashes(digits)
+		}
+		encoded[i] = segments[num%10]
+		num /= 10
+		i--
+		if num == 0 {
+			break
+		}
+	}
+
+	if negative {
+		if i < 0 {
+			return dashes(digits)
+		}
+		encoded[i] = segments[segmentDash]
+	}
+
+	return encoded
+}
+
+// EncodeClock returns the segments for two 2 digit numbers, colon lights the point
+// between them which is the colon on 4-digit clock modules
+func EncodeClock(num1 uint8, num2 uint8, colon bool) []byte {
+
+	encoded := []byte{
+		segments[num1/10%10], segments[num1%10],
+		segments[num2/10%10], segments[num2%10],
+	}
+	if colon {
+		encoded[1] |= segmentDP
+	}
+	return encoded
+}
+
+// ScrollFrames returns the frames that scroll a text across the digits from right to left,
+// the text enters on a blank display and leaves it blank again
+func ScrollFrames(text []byte, digits int) [][]byte {
+
+	encoded := EncodeText(text)
+
+	// Pad both sides with a display width of blanks
+	padded := make([]byte, digits, len(encoded)+digits*2)
+	padded = append(padded, encoded...)
+	padded = append(padded, make([]byte, digits)...)
+
+	var frames [][]byte
+	for i := 0; i+digits <= len(padded); i++ {
+		frames = append(frames, padded[i:i+digits])
+	}
+	return frames
+}
+
+func dashes(digits int) []byte {
+	encoded := make([]byte, digits)
+	for i := range encoded {
+		encoded[i] = segments[segmentDash]
+	}
+	return encoded
+}
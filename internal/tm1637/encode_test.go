@@ -0,0 +1,117 @@
+package tm1637
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+
+	tests := []struct {
+		c    byte
+		want byte
+	}{
+		{c: '0', want: 0x3F},
+		{c: '1', want: 0x06},
+		{c: '8', want: 0x7F},
+		{c: '9', want: 0x6F},
+		{c: 'A', want: 0x77},
+		{c: 'a', want: 0x77},
+		{c: 'b', want: 0x7C},
+		{c: 'L', want: 0x38},
+		{c: 'z', want: 0x5B},
+		{c: ' ', want: 0x00},
+		{c: '-', want: 0x40},
+		{c: '*', want: 0x63},
+		{c: '?', want: 0x00},
+	}
+
+	for _, tt := range tests {
+		if got := Encode(tt.c); got != tt.want {
+			t.Errorf("Encode(%q) = %#x, want %#x", tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeText(t *testing.T) {
+
+	tests := []struct {
+		text string
+		want []byte
+	}{
+		{text: "dry ", want: []byte{0x5E, 0x50, 0x6E, 0x00}},
+		{text: "72*", want: []byte{0x07, 0x5B, 0x63}},
+
+		// The dot lights the point of the digit before it
+		{text: "12.5", want: []byte{0x06, 0x5B | segmentDP, 0x6D}},
+
+		// A leading dot or a second dot takes a digit of its own
+		{text: ".5", want: []byte{segmentDP, 0x6D}},
+		{text: "1..", want: []byte{0x06 | segmentDP, segmentDP}},
+	}
+
+	for _, tt := range tests {
+		if got := EncodeText([]byte(tt.text)); !bytes.Equal(got, tt.want) {
+			t.Errorf("EncodeText(%q) = %#v, want %#v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeNumber(t *testing.T) {
+
+	blank := byte(0)
+	dash := byte(0x40)
+
+	tests := []struct {
+		num    int
+		digits int
+		want   []byte
+	}{
+		{num: 0, digits: 4, want: []byte{blank, blank, blank, 0x3F}},
+		{num: 42, digits: 4, want: []byte{blank, blank, 0x66, 0x5B}},
+		{num: -5, digits: 4, want: []byte{blank, blank, dash, 0x6D}},
+		{num: 9999, digits: 4, want: []byte{0x6F, 0x6F, 0x6F, 0x6F}},
+		{num: 12345, digits: 4, want: []byte{dash, dash, dash, dash}},
+		{num: -999, digits: 4, want: []byte{dash, 0x6F, 0x6F, 0x6F}},
+		{num: -1000, digits: 4, want: []byte{dash, dash, dash, dash}},
+		{num: 123456, digits: 6, want: []byte{0x06, 0x5B, 0x4F, 0x66, 0x6D, 0x7D}},
+	}
+
+	for _, tt := range tests {
+		if got := EncodeNumber(tt.num, tt.digits); !bytes.Equal(got, tt.want) {
+			t.Errorf("EncodeNumber(%v, %v) = %#v, want %#v", tt.num, tt.digits, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeClock(t *testing.T) {
+
+	if got, want := EncodeClock(12, 5, true), []byte{0x06, 0x5B | segmentDP, 0x3F, 0x6D}; !bytes.Equal(got, want) {
+		t.Errorf("EncodeClock(12, 5, colon) = %#v, want %#v", got, want)
+	}
+	if got, want := EncodeClock(7, 30, false), []byte{0x3F, 0x07, 0x4F, 0x3F}; !bytes.Equal(got, want) {
+		t.Errorf("EncodeClock(7, 30) = %#v, want %#v", got, want)
+	}
+}
+
+func TestScrollFrames(t *testing.T) {
+
+	frames := ScrollFrames([]byte("ab"), 2)
+
+	// Enters on a blank display and leaves it blank
+	want := [][]byte{
+		{0x00, 0x00},
+		{0x00, 0x77},
+		{0x77, 0x7C},
+		{0x7C, 0x00},
+		{0x00, 0x00},
+	}
+	if len(frames) != len(want) {
+		t.Fatalf("frames = %#v, want %#v", frames, want)
+	}
+	for i := range want {
+		if !bytes.Equal(frames[i], want[i]) {
+			t.Errorf("frame %d = %#v, want %#v", i, frames[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package tm1637
+
+// Recorder is a SegmentDisplay that records every frame, use it to check the exact bytes a Device writes
+type Recorder struct {
+	Frames [][]byte
+}
+
+func (r *Recorder) Transfer(data []byte) {
+	r.Frames = append(r.Frames, append([]byte(nil), data...))
+}
+
+// Reset forgets the recorded frames
+func (r *Recorder) Reset() {
+	r.Frames = nil
+}
+
+// Grids returns the segments of the last data frame by grid, ok is false if no data frame was recorded
+func (r *Recorder) Grids() (grids []byte, ok bool) {
+	for i := len(r.Frames) - 1; i >= 0; i-- {
+		f := r.Frames[i]
+		if len(f) > 0 && f[0]&0xC0 == TM1637_CMD2 {
+			return f[1:], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,90 @@
+//go:build tinygo
+
+package tm1637
+
+import (
+	"machine"
+	"time"
+)
+
+// PinLink bit-bangs the TM1637 two wire protocol on a pair of pins
+type PinLink struct {
+	clk   machine.Pin
+	dio   machine.Pin
+	delay time.Duration
+}
+
+// NewPinLink configures the pins, delay is the bit transition delay, 0 uses DefaultDelay
+func NewPinLink(clk machine.Pin, dio machine.Pin, delay time.Duration) *PinLink {
+
+	if delay == 0 {
+		delay = DefaultDelay
+	}
+
+	l := &PinLink{clk: clk, dio: dio, delay: delay}
+	pinMode(l.clk, false)
+	pinMode(l.dio, false)
+	l.clk.Low() // required for future pull-down
+	l.dio.Low() // required for future pull-down
+
+	return l
+}
+
+// Transfer writes one start, bytes, stop frame
+func (l *PinLink) Transfer(data []byte) {
+	l.start()
+	for _, b := range data {
+		l.writeByte(b)
+	}
+	l.stop()
+}
+
+func (l *PinLink) delaytm() {
+	time.Sleep(l.delay)
+}
+
+func pinMode(pin machine.Pin, mode bool) {
+	// TM1637 has internal pull-up resistors for both CLK and DIO pins.
+	// Set them to input mode will pull them high,
+	// and set them to output mode will pull them down
+	// (since we did so in the beginning.)
+	// The High()/Low() method don't work on some boards.
+	if mode {
+		pin.Configure(machine.PinConfig{Mode: machine.PinInput})
+	} else {
+		pin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	}
+}
+
+func (l *PinLink) start() {
+	pinMode(l.dio, false)
+	l.delaytm()
+	pinMode(l.clk, false)
+	l.delaytm()
+}
+
+func (l *PinLink) stop() {
+	pinMode(l.dio, false)
+	l.delaytm()
+	pinMode(l.clk, true)
+	l.delaytm()
+	pinMode(l.dio, true)
+	l.delaytm()
+}
+
+func (l *PinLink) writeByte(data uint8) {
+	for i := 0; i < 8; i++ {
+		pinMode(l.dio, data&(1<<i) > 0) // send bits from LSB to MSB
+		l.delaytm()
+		pinMode(l.clk, true)
+		l.delaytm()
+		pinMode(l.clk, false)
+		l.delaytm()
+	}
+	pinMode(l.clk, false)
+	l.delaytm()
+	pinMode(l.clk, true)
+	l.delaytm()
+	pinMode(l.clk, false)
+	l.delaytm()
+}
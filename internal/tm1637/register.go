@@ -1,9 +1,11 @@
 package tm1637
 
+import "time"
+
 const (
-	TM1637_CMD1   = 0x40
-	TM1637_CMD2   = 0xC0
-	TM1637_CMD3   = 0x80
+	TM1637_CMD1   = 0x40 // data command, write with auto increment address
+	TM1637_CMD2   = 0xC0 // address command, or'ed with the first grid
+	TM1637_CMD3   = 0x80 // display control, or'ed with TM1637_DSP_ON and the brightness
 	TM1637_DSP_ON = 0x08
 
 	// The black 4-digit and 6-digit TM1637 modules on Amazon and eBay (from
@@ -16,8 +18,11 @@ const (
 	// picofareds?), so the bit transition delay can be as low as 3-5
 	// microseconds.
 	//
-	// TODO: This should be an adjustable parameter.
-	TM1637_DELAY  = uint8(100)
+	// Use the delay argument of NewPinLink to change it.
+	DefaultDelay = time.Microsecond * 100
+
+	// Segment bit for the decimal point, on 4-digit clock modules the second digit's point is the colon
+	segmentDP = 0x80
 )
 
 // 7-segment characters encoding for 0-9, A-Z, a-z, blank, dash, star
@@ -25,4 +30,10 @@ var segments []byte = []byte{
 	0x3F, 0x06, 0x5B, 0x4F, 0x66, 0x6D, 0x7D, 0x07, 0x7F, 0x6F,
 	0x77, 0x7C, 0x39, 0x5E, 0x79, 0x71, 0x3D, 0x76, 0x06, 0x1E,
 	0x76, 0x38, 0x55, 0x54, 0x3F, 0x73, 0x67, 0x50, 0x6D, 0x78,
-	0x3E, 0x1C, 0x2A, 0x76, 0x6E, 0x5B, 0x00, 0x40, 0x63}
\ No newline at end of file
+	0x3E, 0x1C, 0x2A, 0x76, 0x6E, 0x5B, 0x00, 0x40, 0x63}
+
+const (
+	segmentBlank = 36
+	segmentDash  = 37
+	segmentStar  = 38
+)
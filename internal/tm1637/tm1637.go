@@ -0,0 +1,160 @@
+// Package tm1637 provides a driver for the TM1637 4-digit and 6-digit 7-segment LED displays.
+//
+// Datasheet: https://www.mcielectronics.cl/website_MCI/static/documents/Datasheet_TM1637.pdf
+//
+// The Device encodes text and numbers and writes them through a SegmentDisplay, the two
+// wire link to the chip. On the pico that is a PinLink, on the host a Recorder captures
+// the exact bytes that would be written.
+package tm1637
+
+import (
+	"time"
+)
+
+// SegmentDisplay is the two wire link to a TM1637, each Transfer is one start, bytes, stop frame
+type SegmentDisplay interface {
+	Transfer(data []byte)
+}
+
+// Config for a Device
+type Config struct {
+	// Number of digits, 4 or 6, defaults to 4
+	Digits int
+
+	// Order maps each digit, left to right, to the TM1637 grid that drives it.
+	// The common 6-digit modules are wired 2,1,0,5,4,3 which is the default for 6 digits.
+	Order []uint8
+
+	// Brightness of the display (0-7)
+	Brightness uint8
+}
+
+// Device is a TM1637 display
+type Device struct {
+	link       SegmentDisplay
+	digits     int
+	order      []uint8
+	brightness uint8
+}
+
+// New creates a new TM1637 device.
+func New(link SegmentDisplay, cfg Config) *Device {
+
+	if cfg.Digits == 0 {
+		cfg.Digits = 4
+	}
+
+	if cfg.Order == nil {
+		if cfg.Digits == 6 {
+			cfg.Order = []uint8{2, 1, 0, 5, 4, 3}
+		} else {
+			for i := 0; i < cfg.Digits; i++ {
+				cfg.Order = append(cfg.Order, uint8(i))
+			}
+		}
+	}
+
+	if cfg.Brightness > 7 {
+		cfg.Brightness = 7
+	}
+
+	return &Device{link: link, digits: cfg.Digits, order: cfg.Order, brightness: cfg.Brightness}
+}
+
+// Digits returns the number of digits on the display
+func (d *Device) Digits() int {
+	return d.digits
+}
+
+// Brightness sets the brightness of the display (0-7).
+func (d *Device) Brightness(brightness uint8) {
+	if brightness > 7 {
+		brightness = 7
+	}
+	d.brightness = brightness
+	d.writeCmd()
+	d.writeDsp()
+}
+
+// ClearDisplay clears the display.
+func (d *Device) ClearDisplay() {
+	d.DisplaySegments(nil)
+}
+
+// DisplaySegments shows raw segments from the left, missing digits are blank
+// and segments past the last digit are ignored.
+func (d *Device) DisplaySegments(segs []byte) {
+
+	grids := make([]byte, d.digits)
+	for i, pos := range d.order {
+		if i < len(segs) {
+			grids[pos] = segs[i]
+		}
+	}
+
+	d.writeData(grids, 0)
+}
+
+// DisplayText shows a text on the display from the left, a '.' lights the decimal point of the character before it.
+//
+// Only as many characters as there are digits are shown, use Scroll for longer text.
+func (d *Device) DisplayText(text []byte) {
+	d.DisplaySegments(EncodeText(text))
+}
+
+// DisplayChr shows a single character (A-Z, a-z)
+// on the display at position 0-3 (0-5 on 6 digits).
+func (d *Device) DisplayChr(chr byte, pos uint8) {
+	d.displayAt(Encode(chr), pos)
+}
+
+// DisplayNumber shows a number right aligned on the display.
+//
+// Negative numbers have the sign just before the first digit, for example "  -5".
+// A number with more digits than the display shows as dashes.
+func (d *Device) DisplayNumber(num int16) {
+	d.DisplaySegments(EncodeNumber(int(num), d.digits))
+}
+
+// DisplayDigit shows a single-digit number (0-9)
+// at position 0-3 (0-5 on 6 digits).
+func (d *Device) DisplayDigit(digit uint8, pos uint8) {
+	d.displayAt(segments[digit%10], pos)
+}
+
+// DisplayClock allows you to display hour and minute numbers
+// together with the colon on/off.
+func (d *Device) DisplayClock(num1 uint8, num2 uint8, colon bool) {
+	d.DisplaySegments(EncodeClock(num1, num2, colon))
+}
+
+// Scroll moves a text across the display from right to left, one character per step.
+// It blocks until the text has scrolled off the display.
+func (d *Device) Scroll(text []byte, step time.Duration) {
+	for _, frame := range ScrollFrames(text, d.digits) {
+		d.DisplaySegments(frame)
+		time.Sleep(step)
+	}
+}
+
+// displayAt writes the segments of one digit
+func (d *Device) displayAt(seg byte, pos uint8) {
+	if int(pos) >= d.digits {
+		pos = uint8(d.digits - 1)
+	}
+	d.writeData([]byte{seg}, d.order[pos])
+}
+
+func (d *Device) writeCmd() {
+	d.link.Transfer([]byte{TM1637_CMD1})
+}
+
+func (d *Device) writeDsp() {
+	d.link.Transfer([]byte{TM1637_CMD3 | TM1637_DSP_ON | d.brightness})
+}
+
+func (d *Device) writeData(segments []byte, position uint8) {
+	d.writeCmd()
+	d.link.Transfer(append([]byte{TM1637_CMD2 | position}, segments...))
+	d.writeDsp()
+}
@@ -0,0 +1,103 @@
+package tm1637
+
+import (
+	"bytes"
+	"testing"
+)
+
+// expectFrames checks each start, bytes, stop frame the device wrote
+func expectFrames(t *testing.T, what string, r *Recorder, want ...[]byte) {
+	t.Helper()
+
+	if len(r.Frames) != len(want) {
+		t.Fatalf("%v: frames = %#v, want %#v", what, r.Frames, want)
+	}
+	for i := range want {
+		if !bytes.Equal(r.Frames[i], want[i]) {
+			t.Errorf("%v: frame %d = %#v, want %#v", what, i, r.Frames[i], want[i])
+		}
+	}
+}
+
+func TestWriteSequence(t *testing.T) {
+
+	r := &Recorder{}
+	d := New(r, Config{Brightness: 3})
+
+	// Data command, address command with the grids from grid 0, then display control
+	d.DisplayText([]byte("12"))
+	expectFrames(t, "text", r,
+		[]byte{0x40},
+		[]byte{0xC0, 0x06, 0x5B, 0x00, 0x00},
+		[]byte{0x8B},
+	)
+
+	r.Reset()
+	d.Brightness(9)
+	expectFrames(t, "brightness is capped at 7", r,
+		[]byte{0x40},
+		[]byte{0x8F},
+	)
+
+	r.Reset()
+	d.ClearDisplay()
+	if grids, ok := r.Grids(); !ok || !bytes.Equal(grids, []byte{0, 0, 0, 0}) {
+		t.Errorf("grids after clear = %#v, %v", grids, ok)
+	}
+}
+
+func TestSingleDigitAddress(t *testing.T) {
+
+	r := &Recorder{}
+	d := New(r, Config{Brightness: 7})
+
+	// One digit is written at its own grid address
+	d.DisplayDigit(7, 2)
+	expectFrames(t, "digit", r,
+		[]byte{0x40},
+		[]byte{0xC2, 0x07},
+		[]byte{0x8F},
+	)
+
+	// A position past the last digit writes the last digit
+	r.Reset()
+	d.DisplayChr('A', 9)
+	if f := r.Frames[1]; !bytes.Equal(f, []byte{0xC3, 0x77}) {
+		t.Errorf("chr frame = %#v, want the last grid", f)
+	}
+}
+
+func TestSixDigitOrder(t *testing.T) {
+
+	r := &Recorder{}
+	d := New(r, Config{Digits: 6})
+
+	// The 6-digit modules drive the digits left to right from grids 2,1,0,5,4,3
+	d.DisplayText([]byte("123456"))
+	grids, ok := r.Grids()
+	want := []byte{0x4F, 0x5B, 0x06, 0x7D, 0x6D, 0x66}
+	if !ok || !bytes.Equal(grids, want) {
+		t.Errorf("grids = %#v, want %#v", grids, want)
+	}
+	if d.Digits() != 6 {
+		t.Errorf("Digits = %v, want 6", d.Digits())
+	}
+}
+
+func TestClockColon(t *testing.T) {
+
+	r := &Recorder{}
+	d := New(r, Config{})
+
+	d.DisplayClock(9, 45, true)
+	grids, _ := r.Grids()
+	if grids[1]&segmentDP == 0 {
+		t.Errorf("grids = %#v, the colon should light the point of the second digit", grids)
+	}
+
+	d.DisplayClock(9, 45, false)
+	grids, _ = r.Grids()
+	if grids[1]&segmentDP != 0 {
+		t.Errorf("grids = %#v, the colon should be off", grids)
+	}
+}
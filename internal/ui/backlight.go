@@ -0,0 +1,157 @@
+package ui
+
+import (
+	"sync"
+	"time"
+)
+
+// Light is the screen backlight, level is a percent where 0 is off
+type Light interface {
+	Set(level uint8)
+}
+
+// BacklightState is how lit the screen is
+type BacklightState int
+
+// LightOff
+// The backlight is off and the panel is asleep
+//
+// LightDim
+// The screen has been idle for DimAfter
+//
+// LightOn
+// The screen was woken less than DimAfter ago
+const (
+	LightOff BacklightState = iota
+	LightDim
+	LightOn
+)
+
+func (s BacklightState) String() string {
+	switch s {
+	case LightOff:
+		return "Off"
+	case LightDim:
+		return "Dim"
+	case LightOn:
+		return "On"
+	default:
+		return "Unknown"
+	}
+}
+
+// BacklightConfig for a Backlight
+type BacklightConfig struct {
+	// Backlight percent when on and when dimmed
+	Bright uint8
+	Dim    uint8
+
+	// Time since the last wake before the screen dims and turns off, 0 never dims or turns off
+	DimAfter time.Duration
+	OffAfter time.Duration
+}
+
+// Backlight wakes the screen on a key press, dims it and turns it off when it is idle.
+// It is safe to call Wake from the goroutine handling the keys while the main loop calls Step.
+// It only drives the light, the main loop puts the panel to sleep when IsOn turns false so
+// the panel is only ever talked to from one goroutine.
+type Backlight struct {
+	mu    sync.Mutex
+	light Light
+	cfg   BacklightConfig
+
+	state BacklightState
+	woke  time.Time
+}
+
+// NewBacklight creates a backlight, the screen starts on as of now
+func NewBacklight(light Light, cfg BacklightConfig, now time.Time) *Backlight {
+
+	if cfg.Bright == 0 {
+		cfg.Bright = 100
+	}
+
+	b := &Backlight{light: light, cfg: cfg, state: LightOn, woke: now}
+	b.light.Set(cfg.Bright)
+
+	return b
+}
+
+// State returns how lit the screen is
+func (b *Backlight) State() BacklightState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// IsOn returns true unless the backlight is off
+func (b *Backlight) IsOn() bool {
+	return b.State() != LightOff
+}
+
+// Wake turns the screen on at full brightness and starts the idle timers over.
+// It returns true if the screen was off, a key press that only woke the screen should be ignored.
+func (b *Backlight) Wake(now time.Time) (wasOff bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasOff = b.state == LightOff
+	b.woke = now
+	b.set(LightOn)
+
+	return wasOff
+}
+
+// Step dims and turns off the screen when it has been idle long enough, it returns true if the state changed
+func (b *Backlight) Step(now time.Time) (changed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	idle := now.Sub(b.woke)
+
+	switch {
+	case b.cfg.OffAfter > 0 && idle >= b.cfg.OffAfter:
+		return b.set(LightOff)
+	case b.cfg.DimAfter > 0 && idle >= b.cfg.DimAfter && b.state == LightOn:
+		return b.set(LightDim)
+	}
+
+	return false
+}
+
+// Next returns when Step next needs to be called, ok is false if the backlight is off or never times out
+func (b *Backlight) Next() (next time.Time, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.state == LightOn && b.cfg.DimAfter > 0:
+		return b.woke.Add(b.cfg.DimAfter), true
+	case b.state != LightOff && b.cfg.OffAfter > 0:
+		return b.woke.Add(b.cfg.OffAfter), true
+	}
+
+	return next, false
+}
+
+// set moves to a state and drives the light, it returns true if the state changed
+func (b *Backlight) set(state BacklightState) bool {
+
+	if state == b.state {
+		return false
+	}
+
+	b.state = state
+
+	switch state {
+	case LightOff:
+		b.light.Set(0)
+	case LightDim:
+		b.light.Set(b.cfg.Dim)
+	case LightOn:
+		b.light.Set(b.cfg.Bright)
+	}
+
+	return true
+}
@@ -0,0 +1,70 @@
+package ui
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBacklightTimeouts(t *testing.T) {
+
+	light := &FakeLight{}
+	b := NewBacklight(light, BacklightConfig{
+		Bright:   100,
+		Dim:      20,
+		DimAfter: time.Second * 20,
+		OffAfter: time.Second * 60,
+	}, start)
+
+	at := func(s int) time.Time { return start.Add(time.Duration(s) * time.Second) }
+
+	if b.State() != LightOn || light.Level != 100 {
+		t.Fatalf("new backlight %v at %v, want On at 100", b.State(), light.Level)
+	}
+	if next, ok := b.Next(); !ok || !next.Equal(at(20)) {
+		t.Errorf("Next = %v %v, want the dim time", next.Sub(start), ok)
+	}
+
+	if b.Step(at(19)) {
+		t.Error("Step before DimAfter changed the state")
+	}
+	if !b.Step(at(20)) || b.State() != LightDim || light.Level != 20 {
+		t.Fatalf("Step at DimAfter %v at %v, want Dim at 20", b.State(), light.Level)
+	}
+	if next, ok := b.Next(); !ok || !next.Equal(at(60)) {
+		t.Errorf("dim Next = %v %v, want the off time", next.Sub(start), ok)
+	}
+
+	if !b.Step(at(60)) || b.IsOn() || light.Level != 0 {
+		t.Fatalf("Step at OffAfter %v at %v, want Off at 0", b.State(), light.Level)
+	}
+	if _, ok := b.Next(); ok {
+		t.Error("Next ok while off, want nothing waiting")
+	}
+
+	// A key wakes it, the key is reported as only waking the screen
+	if !b.Wake(at(100)) || b.State() != LightOn || light.Level != 100 {
+		t.Fatalf("Wake from off %v at %v, want On at 100 and wasOff", b.State(), light.Level)
+	}
+	if b.Wake(at(110)) {
+		t.Error("Wake while on reported wasOff")
+	}
+
+	// The idle time starts over on each wake
+	if b.Step(at(129)) {
+		t.Error("Step 19s after the last wake changed the state")
+	}
+	b.Step(at(130))
+	if b.Wake(at(131)) || b.State() != LightOn || light.Level != 100 {
+		t.Errorf("Wake from dim %v at %v, want On at 100 and not wasOff", b.State(), light.Level)
+	}
+
+	want := []uint8{100, 20, 0, 100, 20, 100}
+	if len(light.Log) != len(want) {
+		t.Fatalf("levels %v, want %v", light.Log, want)
+	}
+	for i := range want {
+		if light.Log[i] != want[i] {
+			t.Fatalf("levels %v, want %v", light.Log, want)
+		}
+	}
+}
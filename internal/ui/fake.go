@@ -0,0 +1,15 @@
+package ui
+
+// FakeLight records the backlight levels, use it to run the backlight on the host
+type FakeLight struct {
+	// Level is the last level set
+	Level uint8
+
+	// Log is every level set in order
+	Log []uint8
+}
+
+func (l *FakeLight) Set(level uint8) {
+	l.Level = level
+	l.Log = append(l.Log, level)
+}
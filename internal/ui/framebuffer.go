@@ -0,0 +1,82 @@
+package ui
+
+import (
+	"image/color"
+)
+
+// Framebuffer is an in-memory Display, use it to render the screens on the host
+type Framebuffer struct {
+	width  int16
+	height int16
+	pixels []color.RGBA
+
+	// Fills counts the FillRectangle calls and Displays counts the Display calls
+	Fills    int
+	Displays int
+}
+
+// NewFramebuffer creates a framebuffer of the given size, all the pixels start black
+func NewFramebuffer(width, height int16) *Framebuffer {
+
+	fb := &Framebuffer{
+		width:  width,
+		height: height,
+		pixels: make([]color.RGBA, int(width)*int(height)),
+	}
+	fb.FillRectangle(0, 0, width, height, color.RGBA{0, 0, 0, 255})
+	fb.Fills = 0
+
+	return fb
+}
+
+func (fb *Framebuffer) Size() (x, y int16) {
+	return fb.width, fb.height
+}
+
+func (fb *Framebuffer) SetPixel(x, y int16, c color.RGBA) {
+	if x < 0 || y < 0 || x >= fb.width || y >= fb.height {
+		return
+	}
+	fb.pixels[int(y)*int(fb.width)+int(x)] = c
+}
+
+func (fb *Framebuffer) Display() error {
+	fb.Displays++
+	return nil
+}
+
+// FillRectangle fills the part of the rectangle that is inside the framebuffer
+func (fb *Framebuffer) FillRectangle(x, y, width, height int16, c color.RGBA) error {
+
+	fb.Fills++
+	for py := y; py < y+height; py++ {
+		for px := x; px < x+width; px++ {
+			fb.SetPixel(px, py, c)
+		}
+	}
+
+	return nil
+}
+
+// At returns the color of a pixel, it is the zero color outside the framebuffer
+func (fb *Framebuffer) At(x, y int16) color.RGBA {
+	if x < 0 || y < 0 || x >= fb.width || y >= fb.height {
+		return color.RGBA{}
+	}
+	return fb.pixels[int(y)*int(fb.width)+int(x)]
+}
+
+// Count returns the number of pixels in the region that are the color
+func (fb *Framebuffer) Count(r Rect, c color.RGBA) int {
+
+	var n int
+	for y := r.Y; y < r.Y+r.H; y++ {
+		for x := r.X; x < r.X+r.W; x++ {
+			if fb.At(x, y) == c {
+				n++
+			}
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,47 @@
+//go:build tinygo
+
+package ui
+
+import (
+	"machine"
+)
+
+// PWM is a PWM slice driving the backlight pin, it is implemented by machine.PWM0 ... machine.PWM7
+type PWM interface {
+	Configure(config machine.PWMConfig) error
+	Channel(pin machine.Pin) (uint8, error)
+	Top() uint32
+	Set(channel uint8, value uint32)
+}
+
+// PWMLight dims the backlight with PWM on the backlight pin
+type PWMLight struct {
+	pwm     PWM
+	channel uint8
+}
+
+// NewPWMLight configures the pin for PWM, call it after the display is configured since
+// the display driver sets the backlight pin up as a plain output
+func NewPWMLight(pwm PWM, pin machine.Pin) (*PWMLight, error) {
+
+	err := pwm.Configure(machine.PWMConfig{})
+	if err != nil {
+		return nil, err
+	}
+
+	channel, err := pwm.Channel(pin)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PWMLight{pwm: pwm, channel: channel}, nil
+}
+
+// Set the backlight percent, 0 is off
+func (l *PWMLight) Set(level uint8) {
+
+	if level > 100 {
+		level = 100
+	}
+	l.pwm.Set(l.channel, l.pwm.Top()*uint32(level)/100)
+}
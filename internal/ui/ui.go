@@ -0,0 +1,166 @@
+// Package ui draws text views on a pixel display without redrawing the whole screen.
+//
+// A Screen shows one Page of Views at a time. Each View owns a region of the screen and
+// produces its text from the time. Draw only clears and redraws the regions whose text
+// changed since they were last drawn, so a screen that is redrawn every second does not
+// flicker. A Backlight dims and turns off the screen when it is idle.
+//
+// Everything is drawn through the Display interface, it is implemented by the st7789
+// driver and by Framebuffer so the screens can be rendered on the host.
+package ui
+
+import (
+	"image/color"
+	"time"
+
+	"tinygo.org/x/drivers"
+	"tinygo.org/x/tinyfont"
+)
+
+// Display is the screen the views are drawn on, it is implemented by the st7789 driver
+type Display interface {
+	drivers.Displayer
+	FillRectangle(x, y, width, height int16, c color.RGBA) error
+}
+
+// Rect is a region of the screen
+type Rect struct {
+	X, Y int16
+	W, H int16
+}
+
+// Contains returns true if the pixel is inside the region
+func (r Rect) Contains(x, y int16) bool {
+	return x >= r.X && x < r.X+r.W && y >= r.Y && y < r.Y+r.H
+}
+
+// View is text drawn in a region of the screen, the text may have several lines.
+// Text is called on every Draw, the region is only redrawn when the text changes.
+type View struct {
+	Name   string
+	Region Rect
+	Font   tinyfont.Fonter
+	Color  color.RGBA
+	Text   func(now time.Time) string
+}
+
+// Page is a set of views shown together
+type Page struct {
+	Name  string
+	Views []View
+}
+
+// Screen shows one page at a time and keeps track of what is drawn
+type Screen struct {
+	display    Display
+	background color.RGBA
+	pages      []Page
+	page       int
+
+	// drawn is the text last drawn in each view of the page, cleared is false when the whole screen needs clearing
+	drawn   []string
+	cleared bool
+}
+
+// NewScreen creates a screen showing the first page, nothing is drawn until Draw
+func NewScreen(display Display, background color.RGBA, pages ...Page) *Screen {
+
+	s := &Screen{
+		display:    display,
+		background: background,
+		pages:      pages,
+	}
+	s.SetPage(0)
+
+	return s
+}
+
+// Page returns the index of the page shown
+func (s *Screen) Page() int {
+	return s.page
+}
+
+// SetPage switches to a page, the whole screen is redrawn by the next Draw
+func (s *Screen) SetPage(page int) {
+
+	if page < 0 || page >= len(s.pages) {
+		page = 0
+	}
+
+	s.page = page
+	s.Invalidate()
+}
+
+// NextPage switches to the next page, after the last page it goes back to the first
+func (s *Screen) NextPage() {
+	s.SetPage(s.page + 1)
+}
+
+// Invalidate makes the next Draw clear and redraw the whole screen
+func (s *Screen) Invalidate() {
+
+	s.cleared = false
+	s.drawn = nil
+	if s.page < len(s.pages) {
+		s.drawn = make([]string, len(s.pages[s.page].Views))
+	}
+
+}
+
+// Draw redraws the views whose text has changed, it returns the number of views redrawn
+func (s *Screen) Draw(now time.Time) (redrawn int) {
+
+	if len(s.pages) == 0 {
+		return 0
+	}
+
+	if !s.cleared {
+		w, h := s.display.Size()
+		s.display.FillRectangle(0, 0, w, h, s.background)
+		s.cleared = true
+	}
+
+	for i, v := range s.pages[s.page].Views {
+
+		text := v.Text(now)
+		if text == s.drawn[i] {
+			continue
+		}
+
+		s.display.FillRectangle(v.Region.X, v.Region.Y, v.Region.W, v.Region.H, s.background)
+
+		// The text y is the baseline of the first line, put the top of the tallest glyphs at the top of the region
+		clip := &clipped{display: s.display, region: v.Region}
+		baseline := v.Region.Y + int16(v.Font.GetYAdvance())*3/4
+		tinyfont.WriteLine(clip, v.Font, v.Region.X, baseline, text, v.Color)
+
+		s.drawn[i] = text
+		redrawn++
+	}
+
+	if redrawn > 0 {
+		s.display.Display()
+	}
+
+	return redrawn
+}
+
+// clipped drops the pixels outside a region so a view can't draw over its neighbours
+type clipped struct {
+	display Display
+	region  Rect
+}
+
+func (c *clipped) Size() (x, y int16) {
+	return c.display.Size()
+}
+
+func (c *clipped) SetPixel(x, y int16, col color.RGBA) {
+	if c.region.Contains(x, y) {
+		c.display.SetPixel(x, y, col)
+	}
+}
+
+func (c *clipped) Display() error {
+	return nil
+}
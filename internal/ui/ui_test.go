@@ -0,0 +1,118 @@
+package ui
+
+import (
+	"image/color"
+	"testing"
+	"time"
+
+	"tinygo.org/x/tinyfont/freemono"
+)
+
+var (
+	black = color.RGBA{0, 0, 0, 255}
+	red   = color.RGBA{255, 0, 0, 255}
+	start = time.Unix(1_700_000_000, 0)
+)
+
+func textView(name string, region Rect, text *string) View {
+	return View{
+		Name:   name,
+		Region: region,
+		Font:   &freemono.Regular9pt7b,
+		Color:  red,
+		Text:   func(time.Time) string { return *text },
+	}
+}
+
+func TestDrawOnlyChangedViews(t *testing.T) {
+
+	fb := NewFramebuffer(320, 240)
+	top, bottom := "12:00", "static"
+	topRegion := Rect{X: 10, Y: 0, W: 300, H: 26}
+	bottomRegion := Rect{X: 10, Y: 100, W: 300, H: 26}
+
+	s := NewScreen(fb, black, Page{Name: "p", Views: []View{
+		textView("top", topRegion, &top),
+		textView("bottom", bottomRegion, &bottom),
+	}})
+
+	// The first draw clears the screen and draws every view
+	if n := s.Draw(start); n != 2 {
+		t.Fatalf("first Draw = %v, want 2", n)
+	}
+	if fb.Fills != 3 || fb.Displays != 1 {
+		t.Fatalf("first Draw Fills = %v Displays = %v, want 3 and 1", fb.Fills, fb.Displays)
+	}
+	topPixels, bottomPixels := fb.Count(topRegion, red), fb.Count(bottomRegion, red)
+	if topPixels == 0 || bottomPixels == 0 {
+		t.Fatalf("text pixels top = %v bottom = %v, want both drawn", topPixels, bottomPixels)
+	}
+
+	// Nothing changed, nothing is sent to the panel
+	if n := s.Draw(start); n != 0 || fb.Fills != 3 || fb.Displays != 1 {
+		t.Errorf("unchanged Draw = %v Fills = %v Displays = %v, want 0, 3 and 1", n, fb.Fills, fb.Displays)
+	}
+
+	// Only the changed view is cleared and redrawn
+	top = "12:01"
+	if n := s.Draw(start); n != 1 || fb.Fills != 4 || fb.Displays != 2 {
+		t.Errorf("changed Draw = %v Fills = %v Displays = %v, want 1, 4 and 2", n, fb.Fills, fb.Displays)
+	}
+	if got := fb.Count(bottomRegion, red); got != bottomPixels {
+		t.Errorf("bottom pixels = %v after the top changed, want %v", got, bottomPixels)
+	}
+
+	// An empty text clears the region
+	top = ""
+	s.Draw(start)
+	if got := fb.Count(topRegion, red); got != 0 {
+		t.Errorf("top pixels = %v after clearing, want 0", got)
+	}
+}
+
+func TestViewIsClipped(t *testing.T) {
+
+	fb := NewFramebuffer(320, 240)
+	text := "a line far too long for the region\nand a second line below it"
+	region := Rect{X: 40, Y: 40, W: 30, H: 12}
+
+	s := NewScreen(fb, black, Page{Name: "p", Views: []View{textView("small", region, &text)}})
+	s.Draw(start)
+
+	inside := fb.Count(region, red)
+	if inside == 0 {
+		t.Fatal("nothing drawn in the region")
+	}
+	if all := fb.Count(Rect{W: 320, H: 240}, red); all != inside {
+		t.Errorf("%v pixels drawn outside the region", all-inside)
+	}
+}
+
+func TestPages(t *testing.T) {
+
+	fb := NewFramebuffer(320, 240)
+	first, second := "first", "second"
+	region := Rect{X: 0, Y: 0, W: 320, H: 26}
+
+	s := NewScreen(fb, black,
+		Page{Name: "one", Views: []View{textView("a", region, &first)}},
+		Page{Name: "two", Views: []View{textView("b", region, &second), textView("c", Rect{Y: 50, W: 320, H: 26}, &second)}},
+	)
+	s.Draw(start)
+
+	// A new page clears the whole screen and draws all its views
+	s.NextPage()
+	fills := fb.Fills
+	if n := s.Draw(start); n != 2 || fb.Fills != fills+3 {
+		t.Errorf("page two Draw = %v Fills = %v, want 2 and %v", n, fb.Fills, fills+3)
+	}
+
+	s.NextPage()
+	if s.Page() != 0 {
+		t.Errorf("Page after the last = %v, want 0", s.Page())
+	}
+	s.SetPage(5)
+	if s.Page() != 0 {
+		t.Errorf("SetPage(5) = %v, want 0", s.Page())
+	}
+}
@@ -0,0 +1,143 @@
+package water
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Command verbs, the args follow the verb
+//
+//	water,seconds                                          - water now for seconds
+//	skip                                                   - skip the next watering
+//	threshold,percent                                      - water when the moisture falls below percent
+//	schedule,maxRunSeconds,minIntervalMinutes,budgetMinutes - change the run time limits
+const (
+	CommandWater     = "water"
+	CommandSkip      = "skip"
+	CommandThreshold = "threshold"
+	CommandSchedule  = "schedule"
+)
+
+var (
+	// ErrBadCommand is returned for a command that can't be parsed
+	ErrBadCommand = errors.New("water: bad command")
+
+	// ErrUnknownCommand is returned for a verb the controller does not know
+	ErrUnknownCommand = errors.New("water: unknown command")
+)
+
+// Command is a downlink command sent to the soil node, the value of the message is
+//
+//	id,verb,args...
+type Command struct {
+	ID   string
+	Verb string
+	Args []string
+}
+
+// ParseCommand parses the value of a command message
+func ParseCommand(value string) (Command, error) {
+
+	parts := strings.Split(value, ",")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return Command{}, ErrBadCommand
+	}
+
+	return Command{ID: parts[0], Verb: parts[1], Args: parts[2:]}, nil
+}
+
+// Apply runs a command against the controller
+func (c *Controller) Apply(cmd Command, now time.Time) ([]Event, error) {
+
+	args, err := cmd.numbers()
+	if err != nil {
+		return nil, err
+	}
+
+	switch cmd.Verb {
+
+	case CommandWater:
+		if len(args) != 1 || args[0] <= 0 {
+			return nil, ErrBadCommand
+		}
+		return c.Water(time.Duration(args[0]*float64(time.Second)), now)
+
+	case CommandSkip:
+		c.SkipNext()
+
+	case CommandThreshold:
+		if len(args) != 1 || args[0] < 0 || args[0] > 100 {
+			return nil, ErrBadCommand
+		}
+		c.SetThreshold(args[0])
+
+	case CommandSchedule:
+		if len(args) != 3 || args[0] <= 0 || args[1] < 0 || args[2] < 0 {
+			return nil, ErrBadCommand
+		}
+		c.SetSchedule(
+			time.Duration(args[0]*float64(time.Second)),
+			time.Duration(args[1]*float64(time.Minute)),
+			time.Duration(args[2]*float64(time.Minute)),
+		)
+
+	default:
+		return nil, ErrUnknownCommand
+	}
+
+	return nil, nil
+}
+
+// Applied remembers the ids of the last few commands applied and what they were acked with.
+// The gateway resends a command until it hears the ack so the same command can arrive again
+// after newer ones, it must be acked again without applying it a second time.
+type Applied struct {
+	ids     []string
+	results []string
+	next    int
+}
+
+// NewApplied creates an Applied that remembers the last size commands, if size is 0 it defaults to 8
+func NewApplied(size int) *Applied {
+
+	if size == 0 {
+		size = 8
+	}
+
+	return &Applied{ids: make([]string, size), results: make([]string, size)}
+}
+
+// Result returns what the command with the id was acked with, ok is false if it has not been applied
+func (a *Applied) Result(id string) (result string, ok bool) {
+
+	for i, have := range a.ids {
+		if have == id && have != "" {
+			return a.results[i], true
+		}
+	}
+
+	return result, false
+}
+
+// Add records a command as applied, the oldest id is forgotten once full
+func (a *Applied) Add(id string, result string) {
+	a.ids[a.next] = id
+	a.results[a.next] = result
+	a.next = (a.next + 1) % len(a.ids)
+}
+
+// numbers parses the args as numbers
+func (cmd Command) numbers() ([]float64, error) {
+
+	var args []float64
+	for _, a := range cmd.Args {
+		f, err := strconv.ParseFloat(a, 64)
+		if err != nil {
+			return nil, ErrBadCommand
+		}
+		args = append(args, f)
+	}
+	return args, nil
+}
@@ -0,0 +1,18 @@
+package water
+
+// FakeValve records what the controller did to the valve, it is used to test the controller on the host
+type FakeValve struct {
+	IsOpen bool
+	Opens  int
+	Closes int
+}
+
+func (v *FakeValve) Open() {
+	v.IsOpen = true
+	v.Opens++
+}
+
+func (v *FakeValve) Close() {
+	v.IsOpen = false
+	v.Closes++
+}
@@ -0,0 +1,275 @@
+// Package water decides when to open and close the garden watering valve.
+//
+// The Controller is a state machine driven by moisture readings and the passage of time.
+// It does not read the clock itself, the caller passes the time to each call, so the
+// rules can be exercised on the host with a fake clock and a fake valve.
+package water
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// Valve is the watering valve, it is notably implemented by the hbridge driving a solenoid
+type Valve interface {
+	Open()
+	Close()
+}
+
+// Config for the watering Controller
+type Config struct {
+	// Start watering when the moisture percent falls below Threshold
+	Threshold float64
+
+	// Never run the valve longer than MaxRun at a time
+	MaxRun time.Duration
+
+	// Wait at least MinInterval after watering stops before starting again,
+	// this gives the water time to soak in before the next reading
+	MinInterval time.Duration
+
+	// Total run time allowed in a day, 0 is no limit
+	DailyBudget time.Duration
+}
+
+// EventKind is the type of watering event
+type EventKind int
+
+const (
+	Started EventKind = iota
+	Stopped
+)
+
+func (k EventKind) String() string {
+	if k == Started {
+		return "Started"
+	}
+	return "Stopped"
+}
+
+// Why the valve was opened or closed
+const (
+	ReasonDry     = "dry"
+	ReasonWet     = "wet"
+	ReasonManual  = "manual"
+	ReasonMaxRun  = "max-run"
+	ReasonBudget  = "budget"
+	ReasonStopped = "stopped"
+)
+
+// Event is a change of the valve, Duration is how long it ran for a Stopped event
+type Event struct {
+	Kind     EventKind
+	At       time.Time
+	Duration time.Duration
+	Reason   string
+}
+
+const day = time.Hour * 24
+
+var (
+	// ErrRunning is returned when asked to water while the valve is already open
+	ErrRunning = errors.New("water: already watering")
+
+	// ErrBudget is returned when the daily budget is used up
+	ErrBudget = errors.New("water: daily budget used")
+
+	// ErrTooLong is returned when asked to water for longer than MaxRun
+	ErrTooLong = errors.New("water: longer than max run")
+)
+
+// Controller opens and closes the valve
+type Controller struct {
+	cfg   Config
+	valve Valve
+
+	running   bool
+	startedAt time.Time
+	runFor    time.Duration
+
+	// countedFrom is where the current run starts to count against the daily budget,
+	// a run that crosses into a new day only counts the part after the day started
+	countedFrom time.Time
+	lastStop    time.Time
+	hasRun      bool
+
+	dayStart time.Time
+	usedDay  time.Duration
+
+	skipNext bool
+}
+
+// New creates a controller with the valve closed
+func New(cfg Config, valve Valve, now time.Time) *Controller {
+	valve.Close()
+	return &Controller{cfg: cfg, valve: valve, dayStart: now}
+}
+
+// Config returns the current config
+func (c *Controller) Config() Config {
+	return c.cfg
+}
+
+// SetThreshold changes the moisture percent that starts watering
+func (c *Controller) SetThreshold(percent float64) {
+	c.cfg.Threshold = percent
+}
+
+// SetSchedule changes the run time limits, it takes effect on the next Step
+func (c *Controller) SetSchedule(maxRun time.Duration, minInterval time.Duration, dailyBudget time.Duration) {
+	c.cfg.MaxRun = maxRun
+	c.cfg.MinInterval = minInterval
+	c.cfg.DailyBudget = dailyBudget
+}
+
+// SkipNext skips the next watering the moisture readings would start
+func (c *Controller) SkipNext() {
+	c.skipNext = true
+}
+
+// Water opens the valve for d regardless of the moisture and the interval since the last watering,
+// the daily budget still applies
+func (c *Controller) Water(d time.Duration, now time.Time) ([]Event, error) {
+
+	c.rollDay(now)
+
+	switch {
+	case c.running:
+		return nil, ErrRunning
+	case d > c.cfg.MaxRun:
+		return nil, ErrTooLong
+	case c.cfg.DailyBudget > 0 && c.usedDay >= c.cfg.DailyBudget:
+		return nil, ErrBudget
+	}
+
+	return []Event{c.start(now, d, ReasonManual)}, nil
+}
+
+// IsRunning returns true while the valve is open
+func (c *Controller) IsRunning() bool {
+	return c.running
+}
+
+// LastWatered returns when the valve last closed, ok is false if it has not run yet
+func (c *Controller) LastWatered() (at time.Time, ok bool) {
+	return c.lastStop, c.hasRun
+}
+
+// UsedToday returns the run time counted against the daily budget
+func (c *Controller) UsedToday(now time.Time) time.Duration {
+	c.rollDay(now)
+	used := c.usedDay
+	if c.running {
+		used += now.Sub(c.countedFrom)
+	}
+	return used
+}
+
+// Moisture feeds the controller a moisture reading in percent, the valve is opened if the
+// soil is dry and the interval and budget allow it or closed if the soil is wet again
+func (c *Controller) Moisture(percent float64, now time.Time) []Event {
+
+	events := c.Step(now)
+
+	switch {
+	case c.running && percent >= c.cfg.Threshold && c.runFor == 0:
+		events = append(events, c.stop(now, ReasonWet))
+
+	case !c.running && percent < c.cfg.Threshold:
+		if c.skipNext && c.canStart(now) {
+			log.Printf("water.Moisture: %.1f%% is dry but skipping this watering", percent)
+			c.skipNext = false
+			// Count the skip as a watering so the interval starts over
+			c.lastStop = now
+			c.hasRun = true
+			break
+		}
+		if c.canStart(now) {
+			events = append(events, c.start(now, 0, ReasonDry))
+		}
+	}
+
+	return events
+}
+
+// Step checks the timers and closes the valve once it has run long enough
+func (c *Controller) Step(now time.Time) []Event {
+
+	c.rollDay(now)
+
+	if !c.running {
+		return nil
+	}
+
+	ran := now.Sub(c.startedAt)
+	switch {
+	case c.runFor > 0 && ran >= c.runFor:
+		return []Event{c.stop(now, ReasonStopped)}
+	case ran >= c.cfg.MaxRun:
+		return []Event{c.stop(now, ReasonMaxRun)}
+	case c.cfg.DailyBudget > 0 && c.usedDay+now.Sub(c.countedFrom) >= c.cfg.DailyBudget:
+		return []Event{c.stop(now, ReasonBudget)}
+	}
+
+	return nil
+}
+
+// ClockJumped keeps the run, interval and budget times right when the clock is set
+func (c *Controller) ClockJumped(jump time.Duration) {
+	c.startedAt = c.startedAt.Add(jump)
+	c.countedFrom = c.countedFrom.Add(jump)
+	c.lastStop = c.lastStop.Add(jump)
+	c.dayStart = c.dayStart.Add(jump)
+}
+
+// canStart checks the interval and daily budget
+func (c *Controller) canStart(now time.Time) bool {
+
+	if c.hasRun && now.Sub(c.lastStop) < c.cfg.MinInterval {
+		log.Printf("water.canStart: last watered %v ago, wait for %v", now.Sub(c.lastStop), c.cfg.MinInterval)
+		return false
+	}
+
+	if c.cfg.DailyBudget > 0 && c.usedDay >= c.cfg.DailyBudget {
+		log.Printf("water.canStart: daily budget of %v used", c.cfg.DailyBudget)
+		return false
+	}
+
+	return true
+}
+
+func (c *Controller) start(now time.Time, runFor time.Duration, reason string) Event {
+	c.valve.Open()
+	c.running = true
+	c.startedAt = now
+	c.countedFrom = now
+	c.runFor = runFor
+	return Event{Kind: Started, At: now, Reason: reason}
+}
+
+func (c *Controller) stop(now time.Time, reason string) Event {
+	c.valve.Close()
+	ran := now.Sub(c.startedAt)
+	c.running = false
+	c.runFor = 0
+	c.usedDay += now.Sub(c.countedFrom)
+	c.lastStop = now
+	c.hasRun = true
+	return Event{Kind: Stopped, At: now, Duration: ran, Reason: reason}
+}
+
+// rollDay starts a new budget day once 24 hours have passed.
+// A run that is going when the day changes only counts against the new day from the day start.
+func (c *Controller) rollDay(now time.Time) {
+
+	if now.Sub(c.dayStart) < day {
+		return
+	}
+
+	c.dayStart = c.dayStart.Add(now.Sub(c.dayStart) / day * day)
+	c.usedDay = 0
+	if c.running && c.countedFrom.Before(c.dayStart) {
+		c.countedFrom = c.dayStart
+	}
+}
@@ -0,0 +1,241 @@
+package water
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tonygilkerson/mbx-iot/internal/clock"
+)
+
+var testConfig = Config{
+	Threshold:   30,
+	MaxRun:      time.Minute * 10,
+	MinInterval: time.Hour,
+	DailyBudget: time.Minute * 15,
+}
+
+// kinds returns the kind and reason of each event
+func kinds(events []Event) []string {
+	var s []string
+	for _, e := range events {
+		s = append(s, e.Kind.String()+":"+e.Reason)
+	}
+	return s
+}
+
+func expect(t *testing.T, what string, events []Event, want ...string) {
+	t.Helper()
+
+	got := kinds(events)
+	if len(got) != len(want) {
+		t.Fatalf("%v: events = %v, want %v", what, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("%v: events = %v, want %v", what, got, want)
+		}
+	}
+}
+
+func TestWaterWhenDryUntilWet(t *testing.T) {
+
+	clk := clock.NewFake(time.Unix(0, 0))
+	valve := &FakeValve{}
+	c := New(testConfig, valve, clk.Now())
+
+	expect(t, "moist", c.Moisture(45, clk.Now()))
+	expect(t, "dry", c.Moisture(25, clk.Now()), "Started:dry")
+	if !valve.IsOpen {
+		t.Fatal("the valve should be open")
+	}
+
+	clk.Advance(time.Minute * 4)
+	events := c.Moisture(35, clk.Now())
+	expect(t, "wet", events, "Stopped:wet")
+	if events[0].Duration != time.Minute*4 || valve.IsOpen {
+		t.Errorf("ran %v, valve open %v, want closed after 4m", events[0].Duration, valve.IsOpen)
+	}
+
+	// Still dry too soon after the last watering
+	clk.Advance(time.Minute * 30)
+	expect(t, "within min interval", c.Moisture(20, clk.Now()))
+
+	clk.Advance(time.Minute * 30)
+	expect(t, "after min interval", c.Moisture(20, clk.Now()), "Started:dry")
+}
+
+func TestMaxRunAndBudget(t *testing.T) {
+
+	clk := clock.NewFake(time.Unix(0, 0))
+	valve := &FakeValve{}
+	c := New(testConfig, valve, clk.Now())
+
+	c.Moisture(10, clk.Now())
+	clk.Advance(time.Minute * 10)
+	expect(t, "max run", c.Step(clk.Now()), "Stopped:max-run")
+
+	// 5 minutes of budget left
+	clk.Advance(time.Hour)
+	c.Moisture(10, clk.Now())
+	clk.Advance(time.Minute * 5)
+	expect(t, "budget", c.Step(clk.Now()), "Stopped:budget")
+	if used := c.UsedToday(clk.Now()); used != time.Minute*15 {
+		t.Errorf("UsedToday = %v, want 15m", used)
+	}
+
+	clk.Advance(time.Hour * 2)
+	expect(t, "budget used", c.Moisture(10, clk.Now()))
+	if _, err := c.Water(time.Minute, clk.Now()); err != ErrBudget {
+		t.Errorf("Water err = %v, want ErrBudget", err)
+	}
+	if valve.Opens != 2 || valve.IsOpen {
+		t.Errorf("opens = %v open = %v, want 2 runs and closed", valve.Opens, valve.IsOpen)
+	}
+}
+
+func TestRunAcrossTheDayBoundary(t *testing.T) {
+
+	start := time.Unix(0, 0)
+	clk := clock.NewFake(start)
+	c := New(testConfig, &FakeValve{}, start)
+
+	// Start 6 minutes before the day ends
+	clk.Set(start.Add(day - time.Minute*6))
+	c.Moisture(10, clk.Now())
+
+	clk.Set(start.Add(day + time.Minute*4))
+	events := c.Step(clk.Now())
+	expect(t, "max run", events, "Stopped:max-run")
+	if events[0].Duration != time.Minute*10 {
+		t.Errorf("ran %v, want 10m", events[0].Duration)
+	}
+
+	// Only the 4 minutes after the day started count against the new day
+	if used := c.UsedToday(clk.Now()); used != time.Minute*4 {
+		t.Errorf("UsedToday = %v, want 4m", used)
+	}
+}
+
+func TestClockJumped(t *testing.T) {
+
+	base := clock.NewFake(time.Unix(0, 0))
+	clk := clock.NewSynced(base)
+	c := New(testConfig, &FakeValve{}, clk.Now())
+
+	// Watering started before the gateway time arrived
+	c.Moisture(10, clk.Now())
+	base.Advance(time.Minute * 2)
+	c.ClockJumped(clk.Set(time.Unix(1_700_000_000, 0)))
+
+	// The run, the interval and the budget carry on as if the clock had always been right
+	base.Advance(time.Minute * 2)
+	expect(t, "after the jump", c.Step(clk.Now()))
+	if used := c.UsedToday(clk.Now()); used != time.Minute*4 {
+		t.Errorf("UsedToday = %v, want 4m", used)
+	}
+
+	events := c.Moisture(35, clk.Now())
+	expect(t, "wet", events, "Stopped:wet")
+	if events[0].Duration != time.Minute*4 {
+		t.Errorf("ran %v, want 4m", events[0].Duration)
+	}
+
+	base.Advance(time.Minute * 30)
+	expect(t, "within min interval", c.Moisture(20, clk.Now()))
+}
+
+func TestManualWaterAndSkip(t *testing.T) {
+
+	clk := clock.NewFake(time.Unix(0, 0))
+	valve := &FakeValve{}
+	c := New(testConfig, valve, clk.Now())
+
+	if _, err := c.Water(time.Minute*11, clk.Now()); err != ErrTooLong {
+		t.Errorf("Water 11m err = %v, want ErrTooLong", err)
+	}
+
+	events, err := c.Water(time.Minute*2, clk.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "manual", events, "Started:manual")
+	if _, err := c.Water(time.Minute, clk.Now()); err != ErrRunning {
+		t.Errorf("Water while running err = %v, want ErrRunning", err)
+	}
+
+	// A manual run is not stopped by wet readings, only by its time
+	clk.Advance(time.Minute)
+	expect(t, "wet during manual", c.Moisture(80, clk.Now()))
+	clk.Advance(time.Minute)
+	expect(t, "manual done", c.Step(clk.Now()), "Stopped:stopped")
+
+	// The skipped watering restarts the interval
+	c.SkipNext()
+	clk.Advance(time.Hour)
+	expect(t, "skipped", c.Moisture(10, clk.Now()))
+	clk.Advance(time.Minute * 30)
+	expect(t, "within interval of the skip", c.Moisture(10, clk.Now()))
+	clk.Advance(time.Minute * 30)
+	expect(t, "after the skip", c.Moisture(10, clk.Now()), "Started:dry")
+}
+
+func TestApplyCommand(t *testing.T) {
+
+	now := time.Unix(0, 0)
+	c := New(testConfig, &FakeValve{}, now)
+
+	tests := []struct {
+		value string
+		err   error
+	}{
+		{value: "7,threshold,40"},
+		{value: "8,schedule,300,30,20"},
+		{value: "9,water,60"},
+		{value: "10,water,0", err: ErrBadCommand},
+		{value: "11,threshold,140", err: ErrBadCommand},
+		{value: "12,flood", err: ErrUnknownCommand},
+		{value: "13,water,abc", err: ErrBadCommand},
+	}
+
+	for _, tt := range tests {
+		cmd, err := ParseCommand(tt.value)
+		if err != nil {
+			t.Fatalf("ParseCommand(%q): %v", tt.value, err)
+		}
+		if _, err := c.Apply(cmd, now); err != tt.err {
+			t.Errorf("Apply(%q) err = %v, want %v", tt.value, err, tt.err)
+		}
+	}
+
+	if cfg := c.Config(); cfg.Threshold != 40 || cfg.MaxRun != time.Minute*5 || cfg.DailyBudget != time.Minute*20 {
+		t.Errorf("config = %+v", cfg)
+	}
+
+	if _, err := ParseCommand(",water"); err != ErrBadCommand {
+		t.Errorf("ParseCommand with an empty id err = %v, want ErrBadCommand", err)
+	}
+}
+
+func TestAppliedRemembersRecentCommands(t *testing.T) {
+
+	a := NewApplied(3)
+	a.Add("1", "ok")
+	a.Add("2", "bad command")
+	a.Add("3", "ok")
+
+	// A command resent after newer ones is still known with its ack
+	if result, ok := a.Result("2"); !ok || result != "bad command" {
+		t.Errorf("Result(2) = %q, %v, want the error it was acked with", result, ok)
+	}
+
+	a.Add("4", "ok")
+	if _, ok := a.Result("1"); ok {
+		t.Error("the oldest id should be forgotten once full")
+	}
+	if _, ok := a.Result(""); ok {
+		t.Error("an empty slot should not match")
+	}
+	if result, ok := a.Result("4"); !ok || result != "ok" {
+		t.Errorf("Result(4) = %q, %v", result, ok)
+	}
+}
@@ -1,20 +1,91 @@
 package iot
 
 const (
-	MbxTemperature            = "MailboxTemperature"
-	MbxMuleAlarm              = "MuleAlarm"
-	MbxDoorOpened             = "MailboxDoorOpened"
-	MbxChargerChargeStatusOn  = "ChargerChargeStatusOn"
-	MbxChargerChargeStatusOff = "ChargerChargeStatusOff"
-	MbxChargerPowerSourceGood = "ChargerPowerSourceGood"
-	MbxChargerPowerSourceBad  = "ChargerPowerSourceBad"
-	MbxRoadMainLoopHeartbeat  = "RoadMainLoopHeartbeat"
+	MbxTemperature           = "MailboxTemperature"
+	MbxMuleAlarm             = "MuleAlarm"
+	MbxDoorOpened            = "MailboxDoorOpened"
+	MbxDoorClosed            = "MailboxDoorClosed"          // value is seconds the door was open
+	MbxDoorLeftOpen          = "MailboxDoorLeftOpen"        // value is seconds the door has been open
+	MbxChargerState          = "ChargerState"               // charging, charged, no-source or fault, sent on change
+	MbxChargerHarvest        = "ChargerDailyHarvestMinutes" // minutes spent charging over the last day
+	MbxRoadMainLoopHeartbeat = "RoadMainLoopHeartbeat"
+	MbxBatteryVoltage        = "BatteryVoltage"      // volts
+	MbxBatteryPercent        = "BatteryPercent"      // state of charge 0-100
+	MbxBatteryRuntime        = "BatteryRuntimeHours" // estimated hours until empty, -1 if unknown
 
 	DspMainLoopHeartbeat = "DspMainLoopHeartbeat"
 
 	SoilMainLoopHeartbeat = "SoilMainLoopHeartbeat"
 	SoilTemperature       = "SoilTemperature"
-	SoilMoisture          = "SoilMoisture"
+	SoilMoisture          = "SoilMoisture"           // value is raw,percent, the percent is -1 if the probe is not calibrated
+	SoilSensorError       = "SoilSensorError"        // value is error count,last error
+	SoilWateringStarted   = "WateringStarted"        // value is why, for example dry
+	SoilWateringStopped   = "WateringStopped"        // value is seconds the valve was open,why
+	SoilValveOnSeconds    = "ValveOnSeconds"         // total seconds the valve has been open since boot
+	SoilValveCycles       = "ValveCycles"            // number of times the valve has opened and closed since boot
+	SoilValveLastOpened   = "ValveLastOpenedSeconds" // seconds since the valve last opened, -1 if never
+
+	// Downlink commands to the soil node, see water.ParseCommand for the value.
+	// The host sends the command without an id, the gateway adds one and resends it until the node acks it.
+	SoilCommand    = "SoilCommand"
+	SoilCommandAck = "SoilCommandAck" // value is id,ok or id,error
+
+	// Sent to the host over serial when the gateway queues its command, value is id,command
+	// so the host can match the SoilCommandAck to the command it sent
+	GatewayCommandQueued = "GatewayCommandQueued"
+
+	MedMainLoopHeartbeat = "MedMainLoopHeartbeat"
+	MedsTaken            = "MedsTaken"           // value is seconds since the dose, normally 0
+	MedsAdjusted         = "MedsAdjusted"        // the last dose was adjusted or undone, value is seconds since the last dose, -1 if none
+	MedsLastDose         = "MedsLastDoseSeconds" // sent with the heartbeat, seconds since the last dose, -1 if unknown
+	MedsOverdue          = "MedsOverdue"         // minutes past due, 0 if not overdue, also sent with the heartbeat
+	MedsLastDoseHours    = "MedsLastDoseHours"   // hours since the last dose worked out by the gateway, -1 if unknown
 
 	GatewayHeartbeat = "GatewayHeartbeat"
+
+	// Wall clock time in unix seconds, the host sets it on the gateway over serial and the gateway broadcasts it
+	GatewayTime = "GatewayTime"
+
+	// Node liveness, the value is the node name, for example: "NodeOffline:mbx"
+	NodeOffline = "NodeOffline"
+	NodeOnline  = "NodeOnline"
+
+	// Battery alerts raised by the gateway, the value is the node name
+	BatteryLow = "BatteryLow"
+	BatteryOk  = "BatteryOk"
+
+	// Comma separated list of the nodes the gateway currently considers offline
+	GatewayNodesOffline = "GatewayNodesOffline"
+
+	// Event history, see gateway.History.Query for the message values
+	GatewayHistoryQuery = "GatewayHistoryQuery"
+	GatewayHistoryEvent = "GatewayHistoryEvent"
+	GatewayHistoryEnd   = "GatewayHistoryEnd"
+
+	// Mailbox door openings over the last 24 hours counted from the event history, broadcast for the display
+	GatewayMailToday = "GatewayMailToday"
+
+	// A downlink command the node never acknowledged, the value is id,key,value
+	GatewayCommandExpired = "GatewayCommandExpired"
+)
+
+// Charger messages sent before the charger was reported as one MbxChargerState, kept so host code that
+// still refers to them builds. The mailbox node no longer sends them.
+const (
+	// Deprecated: use MbxChargerState, it is charging
+	MbxChargerChargeStatusOn = "ChargerChargeStatusOn"
+	// Deprecated: use MbxChargerState, it is charged or no-source
+	MbxChargerChargeStatusOff = "ChargerChargeStatusOff"
+	// Deprecated: use MbxChargerState, it is charging or charged
+	MbxChargerPowerSourceGood = "ChargerPowerSourceGood"
+	// Deprecated: use MbxChargerState, it is no-source
+	MbxChargerPowerSourceBad = "ChargerPowerSourceBad"
+)
+
+// Node names used as values in the node liveness messages
+const (
+	NodeMbx  = "mbx"
+	NodeSoil = "soil"
+	NodeDsp  = "dsp"
+	NodeMed  = "med"
 )